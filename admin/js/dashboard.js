@@ -116,7 +116,7 @@ document.getElementById("todoForm").addEventListener("submit", async (e) => {
   };
 
   const method = id ? "PUT" : "POST";
-  const url = id ? `${API_BASE}/todo/${id}` : `${API_BASE}/todo`;
+  const url = id ? `${API_BASE}/todo/${id}` : `${API_BASE}/todo/register`;
 
   await fetch(url, {
     method,
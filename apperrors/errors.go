@@ -0,0 +1,128 @@
+package apperrors
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+)
+
+// stable error codes returned in the problem "code" member
+const (
+	CodeBadRequest         = "bad_request"
+	CodeValidation         = "validation_failed"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodeDuplicate          = "duplicate"
+	CodeTooManyRequests    = "rate_limited"
+	CodeTooLarge           = "payload_too_large"
+	CodeQuotaExceeded      = "quota_exceeded"
+	CodeUnsupportedMedia   = "unsupported_media_type"
+	CodeRangeNotSatisfied  = "range_not_satisfiable"
+	CodeInternal           = "internal_error"
+	CodeInvalidToken       = "invalid_token"
+	CodeTokenExpired       = "token_expired"
+	CodeSessionRevoked     = "session_revoked"
+	CodeTokenReused        = "refresh_token_reused"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeInvalidSignature   = "invalid_signature"
+	CodeSignatureExpired   = "signature_expired"
+)
+
+// Error is an application error rendered as application/problem+json.
+// Err holds the internal cause, it is logged but never sent to clients.
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	Fields []utils.FieldError
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithCode replaces the generic code with a more specific one
+func (e *Error) WithCode(code string) *Error {
+	e.Code = code
+	return e
+}
+
+// Wrap attaches the internal cause
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+// New creates an application error
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func BadRequest(detail string) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, detail)
+}
+
+func Unauthorized(detail string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+func Forbidden(detail string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, detail)
+}
+
+func NotFound(detail string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, detail)
+}
+
+func Conflict(detail string) *Error {
+	return New(http.StatusConflict, CodeConflict, detail)
+}
+
+// Duplicate reports a unique field that is already taken with 409
+func Duplicate(field string) *Error {
+	if field == "" {
+		return Conflict("Resource already exists").WithCode(CodeDuplicate)
+	}
+	e := Conflict(field + " is already taken").WithCode(CodeDuplicate)
+	e.Fields = []utils.FieldError{{Field: field, Rule: "unique", Message: field + " is already taken"}}
+	return e
+}
+
+func TooLarge(detail string) *Error {
+	return New(http.StatusRequestEntityTooLarge, CodeTooLarge, detail)
+}
+
+func UnsupportedMediaType(detail string) *Error {
+	return New(http.StatusUnsupportedMediaType, CodeUnsupportedMedia, detail)
+}
+
+func RangeNotSatisfiable(detail string) *Error {
+	return New(http.StatusRequestedRangeNotSatisfiable, CodeRangeNotSatisfied, detail)
+}
+
+func TooManyRequests(detail string) *Error {
+	return New(http.StatusTooManyRequests, CodeTooManyRequests, detail)
+}
+
+// Validation reports every invalid field with 422
+func Validation(fields []utils.FieldError) *Error {
+	e := New(http.StatusUnprocessableEntity, CodeValidation, "Validation failed")
+	e.Fields = fields
+	return e
+}
+
+// Internal hides err behind a generic message
+func Internal(err error) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, "An unexpected error occurred").Wrap(err)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
+	"github.com/clinton-mwachia/go-fiber-api-template/migrations"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/storage"
+)
+
+// commands are subcommands of the binary, without one the API server starts
+var commands = map[string]func(ctx context.Context, args []string) error{
+	"migrate":      migrateCommand,
+	"dedupe-users": dedupeUsersCommand,
+	"gc-files":     gcFilesCommand,
+}
+
+// runCommand runs the subcommand named by args[0]
+func runCommand(args []string) error {
+	cmd, ok := commands[args[0]]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown command %q, available: %s", args[0], strings.Join(names, ", "))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	config.Load()
+	config.ConnectDB()
+	defer config.DisconnectDB()
+
+	return cmd(ctx, args[1:])
+}
+
+// migrate [up [version] | down [steps] | status]
+func migrateCommand(ctx context.Context, args []string) error {
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	m := migrations.New(config.DB)
+
+	switch action {
+	case "up":
+		var target int64
+		if len(args) > 1 {
+			v, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid target version %q", args[1])
+			}
+			target = v
+		}
+		done, err := m.Up(ctx, target)
+		for _, mig := range done {
+			fmt.Printf("applied %d %s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("nothing to apply")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		done, err := m.Down(ctx, steps)
+		for _, mig := range done {
+			fmt.Printf("rolled back %d %s\n", mig.Version, mig.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("nothing to roll back")
+		}
+		return err
+
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05Z07:00")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+
+	return errors.New("usage: migrate [up [version] | down [steps] | status]")
+}
+
+// dedupe-users [-apply]
+// reports users sharing an email or username, -apply merges or renames them
+func dedupeUsersCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("dedupe-users", flag.ContinueOnError)
+	apply := fs.Bool("apply", false, "merge accounts sharing an email and rename accounts sharing a username")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	groups, err := migrations.FindDuplicateUsers(ctx, config.DB)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		fmt.Println("no duplicate users")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FIELD\tVALUE\tID\tUSERNAME\tEMAIL\tCREATED AT")
+	for _, g := range groups {
+		for i, u := range g.Users {
+			marker := ""
+			if i == 0 {
+				marker = " (kept)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s%s\t%s\t%s\t%s\n", g.Field, g.Key, u.ID.Hex(), marker, u.Username, u.Email,
+				u.CreatedAt.Format("2006-01-02 15:04:05Z07:00"))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if !*apply {
+		fmt.Printf("%d duplicate groups, rerun with -apply to merge accounts sharing an email into the oldest one and rename the others\n", len(groups))
+		return nil
+	}
+	return migrations.ResolveDuplicateUsers(ctx, config.DB, func(format string, args ...any) {
+		fmt.Printf(format+"\n", args...)
+	})
+}
+
+// gc-files [-dry-run] [-grace 24h]
+// reports stored files no todo references and todos referencing missing files,
+// orphans older than the grace period are deleted unless -dry-run is given
+func gcFilesCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("gc-files", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only report, delete nothing")
+	grace := fs.Duration("grace", time.Duration(config.Cfg.OrphanGCGraceHours)*time.Hour, "keep orphans younger than this")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	files, err := newStorage(ctx, storage.NewSigner(config.Cfg.FileURLSecret, "/api/files"))
+	if err != nil {
+		return err
+	}
+	gc := newOrphanCollector(repositories.NewMongoRepositories(config.DB), files, *dryRun)
+	gc.Grace = *grace
+	report, err := gc.Run(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ORPHAN\tSIZE\tMODIFIED")
+	for _, obj := range report.Orphans {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", obj.Key, obj.Size, obj.LastModified.Format("2006-01-02 15:04:05Z07:00"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	for _, ref := range report.Dangling {
+		fmt.Printf("todo %s references missing file %s\n", ref.TodoID.Hex(), ref.Key)
+	}
+
+	fmt.Printf("%d files scanned, %d orphaned, %d within the %s grace period, %d dangling references\n",
+		report.Scanned, len(report.Orphans), report.Recent, *grace, len(report.Dangling))
+	if *dryRun {
+		fmt.Println("dry run, nothing deleted")
+		return nil
+	}
+	fmt.Printf("%d deleted\n", report.Deleted)
+	if len(report.Failed) > 0 {
+		return fmt.Errorf("failed to delete %s", strings.Join(report.Failed, ", "))
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/clinton-mwachia/go-fiber-api-template/media"
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -18,6 +19,48 @@ type Config struct {
 	MongoDB   string
 	JWTSecret string
 	JWTTTLMin int
+	// refresh token lifetime in hours
+	RefreshTTLHours int
+	// apply pending migrations when the server starts
+	AutoMigrate bool
+	// largest accepted upload in bytes
+	MaxUploadBytes int64
+	// total size of a user's attachments in bytes, 0 is unlimited
+	AttachmentQuotaBytes int64
+	// resized copies generated for every uploaded image
+	ImageVariants []media.Variant
+	// lifetime of the signed file URLs returned by the API
+	FileURLTTLMin int
+	// how often orphaned files are collected, 0 disables the job
+	OrphanGCIntervalMin int
+	// unreferenced files younger than this are kept
+	OrphanGCGraceHours int
+	// only report orphans, never delete them
+	OrphanGCDryRun bool
+	// how often due reminders are sent, 0 disables the scheduler
+	ReminderIntervalSec int
+	// how often lists whose ranks grew long are rebalanced, 0 disables the job
+	RankRebalanceIntervalMin int
+	// email reminders, disabled without a host
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	// webhook reminders, disabled without a URL
+	ReminderWebhookURL    string
+	ReminderWebhookSecret string
+	// file storage: "local" or "s3"
+	StorageDriver string
+	StorageDir    string
+	// signs file URLs, defaults to JWTSecret
+	FileURLSecret string
+	S3Endpoint    string
+	S3Region      string
+	S3Bucket      string
+	S3AccessKey   string
+	S3SecretKey   string
+	S3UseSSL      bool
 }
 
 var (
@@ -53,6 +96,98 @@ func Load() {
 			ttl = i
 		}
 	}
+	refreshTTL := 168
+	if v := os.Getenv("REFRESH_TTL_HOURS"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil {
+			refreshTTL = i
+		}
+	}
+
+	autoMigrate := true
+	if v := os.Getenv("AUTO_MIGRATE"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			autoMigrate = b
+		}
+	}
+
+	maxUploadMB := 5
+	if v := os.Getenv("MAX_UPLOAD_MB"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			maxUploadMB = i
+		}
+	}
+	attachmentQuotaMB := 100
+	if v := os.Getenv("ATTACHMENT_QUOTA_MB"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i >= 0 {
+			attachmentQuotaMB = i
+		}
+	}
+	imageVariants := media.DefaultVariants
+	if v := os.Getenv("IMAGE_VARIANTS"); v != "" {
+		parsed, err := media.ParseVariants(v)
+		if err != nil {
+			log.Fatal("IMAGE_VARIANTS: ", err)
+		}
+		imageVariants = parsed
+	}
+	fileURLTTL := 60
+	if v := os.Getenv("FILE_URL_TTL_MIN"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			fileURLTTL = i
+		}
+	}
+	orphanGCInterval := 360
+	if v := os.Getenv("ORPHAN_GC_INTERVAL_MIN"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i >= 0 {
+			orphanGCInterval = i
+		}
+	}
+	orphanGCGrace := 24
+	if v := os.Getenv("ORPHAN_GC_GRACE_HOURS"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i >= 0 {
+			orphanGCGrace = i
+		}
+	}
+	orphanGCDryRun := false
+	if v := os.Getenv("ORPHAN_GC_DRY_RUN"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			orphanGCDryRun = b
+		}
+	}
+	reminderInterval := 30
+	if v := os.Getenv("REMINDER_INTERVAL_SEC"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i >= 0 {
+			reminderInterval = i
+		}
+	}
+	rankInterval := 60
+	if v := os.Getenv("RANK_REBALANCE_INTERVAL_MIN"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i >= 0 {
+			rankInterval = i
+		}
+	}
+	smtpPort := 587
+	if v := os.Getenv("SMTP_PORT"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			smtpPort = i
+		}
+	}
+	storageDir := os.Getenv("STORAGE_DIR")
+	if storageDir == "" {
+		storageDir = "uploads"
+	}
+	fileURLSecret := os.Getenv("FILE_URL_SECRET")
+	if fileURLSecret == "" {
+		fileURLSecret = jwtSecret
+	}
+	s3UseSSL := true
+	if v := os.Getenv("S3_USE_SSL"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			s3UseSSL = b
+		}
+	}
+
+	loadCustomRoles()
 
 	Cfg = &Config{
 		Port:      port,
@@ -60,6 +195,38 @@ func Load() {
 		MongoDB:   mongoDB,
 		JWTSecret: jwtSecret,
 		JWTTTLMin: ttl,
+
+		RefreshTTLHours: refreshTTL,
+		AutoMigrate:     autoMigrate,
+
+		MaxUploadBytes:       int64(maxUploadMB) << 20,
+		AttachmentQuotaBytes: int64(attachmentQuotaMB) << 20,
+		ImageVariants:        imageVariants,
+		FileURLTTLMin:        fileURLTTL,
+
+		OrphanGCIntervalMin: orphanGCInterval,
+		OrphanGCGraceHours:  orphanGCGrace,
+		OrphanGCDryRun:      orphanGCDryRun,
+
+		ReminderIntervalSec:      reminderInterval,
+		RankRebalanceIntervalMin: rankInterval,
+		SMTPHost:                 os.Getenv("SMTP_HOST"),
+		SMTPPort:                 smtpPort,
+		SMTPUsername:             os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:             os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:                 os.Getenv("SMTP_FROM"),
+		ReminderWebhookURL:       os.Getenv("REMINDER_WEBHOOK_URL"),
+		ReminderWebhookSecret:    os.Getenv("REMINDER_WEBHOOK_SECRET"),
+
+		StorageDriver: os.Getenv("STORAGE_DRIVER"),
+		StorageDir:    storageDir,
+		FileURLSecret: fileURLSecret,
+		S3Endpoint:    os.Getenv("S3_ENDPOINT"),
+		S3Region:      os.Getenv("S3_REGION"),
+		S3Bucket:      os.Getenv("S3_BUCKET"),
+		S3AccessKey:   os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey:   os.Getenv("S3_SECRET_KEY"),
+		S3UseSSL:      s3UseSSL,
 	}
 }
 
@@ -80,6 +247,7 @@ func ConnectDB() {
 		log.Fatal("MongoDB ping error:", err)
 	}
 
+	Client = client
 	DB = client.Database(dbName)
 	log.Println("✅ Connected to MongoDB:", dbName)
 }
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strings"
+)
+
+// built in roles
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// permissions checked by middlewares.RequirePermission
+const (
+	PermUsersRead      = "users:read"
+	PermUsersWrite     = "users:write"
+	PermUsersDelete    = "users:delete"
+	PermPasswordsReset = "users:reset-password"
+	PermTodosRead      = "todos:read"
+	PermTodosWrite     = "todos:write"
+	PermTodosReadAll   = "todos:read-all"
+	PermTodosWriteAll  = "todos:write-all"
+)
+
+// RolePermissions maps each role to the permissions it grants
+var RolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermUsersRead,
+		PermUsersWrite,
+		PermUsersDelete,
+		PermPasswordsReset,
+		PermTodosRead,
+		PermTodosWrite,
+		PermTodosReadAll,
+		PermTodosWriteAll,
+	},
+	RoleUser: {
+		PermTodosRead,
+		PermTodosWrite,
+	},
+}
+
+// RegisterRole adds or replaces a custom role
+func RegisterRole(role string, permissions ...string) {
+	RolePermissions[strings.ToLower(role)] = permissions
+}
+
+// HasPermission reports whether the role grants the permission
+func HasPermission(role, permission string) bool {
+	for _, p := range RolePermissions[strings.ToLower(role)] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidRole reports whether the role is declared
+func IsValidRole(role string) bool {
+	_, ok := RolePermissions[strings.ToLower(role)]
+	return ok
+}
+
+// loadCustomRoles reads CUSTOM_ROLES, e.g.
+// CUSTOM_ROLES="auditor=users:read,todos:read-all;editor=todos:read,todos:write"
+func loadCustomRoles() {
+	v := os.Getenv("CUSTOM_ROLES")
+	if v == "" {
+		return
+	}
+	for _, def := range strings.Split(v, ";") {
+		name, perms, ok := strings.Cut(strings.TrimSpace(def), "=")
+		if !ok || name == "" {
+			log.Println("⚠️ Ignoring malformed custom role:", def)
+			continue
+		}
+		permissions := []string{}
+		for _, p := range strings.Split(perms, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				permissions = append(permissions, p)
+			}
+		}
+		RegisterRole(strings.TrimSpace(name), permissions...)
+	}
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
+	"github.com/clinton-mwachia/go-fiber-api-template/media"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/storage"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AttachmentController handles the attachments of a todo,
+// the routes are guarded by EnsureTodoAccess
+type AttachmentController struct {
+	attachments repositories.AttachmentRepository
+	files       storage.Storage
+}
+
+// NewAttachmentController creates an AttachmentController, attachment content is kept in files
+func NewAttachmentController(attachments repositories.AttachmentRepository, files storage.Storage) *AttachmentController {
+	return &AttachmentController{attachments: attachments, files: files}
+}
+
+// attachmentQuota is the per user limit of ATTACHMENT_QUOTA_MB, 0 is unlimited
+func attachmentQuota() int64 {
+	return config.Cfg.AttachmentQuotaBytes
+}
+
+func (ac *AttachmentController) withURL(ctx context.Context, attachment *models.Attachment) {
+	url, err := ac.files.SignedURL(ctx, attachment.Key, fileURLExpiry())
+	if err != nil {
+		log.Printf("Failed to sign attachment %s: %v", attachment.Key, err)
+		return
+	}
+	attachment.URL = url
+}
+
+// findAttachment loads the :attachmentId of the :id todo
+func (ac *AttachmentController) findAttachment(c *fiber.Ctx) (models.Attachment, error) {
+	todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return models.Attachment{}, apperrors.BadRequest("Invalid todo ID")
+	}
+	id, err := primitive.ObjectIDFromHex(c.Params("attachmentId"))
+	if err != nil {
+		return models.Attachment{}, apperrors.BadRequest("Invalid attachment ID")
+	}
+
+	attachment, err := ac.attachments.FindByID(context.Background(), id)
+	if errors.Is(err, repositories.ErrNotFound) || (err == nil && attachment.TodoID != todoID) {
+		return models.Attachment{}, apperrors.NotFound("Attachment not found")
+	} else if err != nil {
+		return models.Attachment{}, apperrors.Internal(err)
+	}
+	return attachment, nil
+}
+
+// add an attachment to a todo
+func (ac *AttachmentController) AddAttachment(c *fiber.Ctx) error {
+	todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid todo ID")
+	}
+	userID := currentUserID(c)
+	if userID == nil {
+		return apperrors.Unauthorized("Missing user")
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return apperrors.BadRequest("Missing file part \"file\"")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// cheap early rejection, Create enforces the quota atomically
+	quota := attachmentQuota()
+	used, err := ac.attachments.Usage(ctx, *userID)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	if quota > 0 && used+file.Size > quota {
+		return quotaExceeded(quota)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return apperrors.BadRequest("Invalid file upload").Wrap(err)
+	}
+	defer src.Close()
+
+	policy := media.Policy{MaxBytes: imagePolicy().MaxBytes, Allowed: media.AttachmentTypes}
+	upload, err := media.Read(src, file.Size, policy)
+	switch {
+	case errors.Is(err, media.ErrTooLarge):
+		return apperrors.TooLarge(fmt.Sprintf("File must be at most %d MB", policy.MaxBytes>>20))
+	case errors.Is(err, media.ErrUnsupportedType):
+		return apperrors.UnsupportedMediaType("Only images, PDF, plain text and zip files can be attached").Wrap(err)
+	case err != nil:
+		return apperrors.BadRequest("Invalid file upload").Wrap(err)
+	}
+
+	sum := sha256.Sum256(upload.Data)
+	attachment := models.Attachment{
+		TodoID:      todoID,
+		Key:         media.RandomKey("attachments", upload.Ext),
+		Name:        media.CleanFilename(file.Filename),
+		Size:        int64(len(upload.Data)),
+		ContentType: upload.ContentType,
+		Checksum:    hex.EncodeToString(sum[:]),
+		UploadedBy:  *userID,
+	}
+	if err := ac.files.Put(ctx, attachment.Key, bytes.NewReader(upload.Data), attachment.Size, attachment.ContentType); err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if err := ac.attachments.Create(ctx, &attachment, quota); err != nil {
+		ac.deleteFile(ctx, attachment.Key)
+		if errors.Is(err, repositories.ErrQuotaExceeded) {
+			return quotaExceeded(quota)
+		}
+		return apperrors.Internal(err)
+	}
+
+	ac.withURL(ctx, &attachment)
+	return c.Status(201).JSON(attachment)
+}
+
+func quotaExceeded(quota int64) error {
+	return apperrors.TooLarge(fmt.Sprintf("Storage quota of %d MB exceeded", quota>>20)).WithCode(apperrors.CodeQuotaExceeded)
+}
+
+// list the attachments of a todo
+func (ac *AttachmentController) GetAttachments(c *fiber.Ctx) error {
+	todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid todo ID")
+	}
+
+	req, err := pageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	page, err := ac.attachments.FindPageByTodoID(context.Background(), todoID, req)
+	if err != nil {
+		return pageError(err)
+	}
+
+	for i := range page.Items {
+		ac.withURL(c.Context(), &page.Items[i])
+	}
+	return sendPage(c, req, page)
+}
+
+// get the metadata of an attachment
+func (ac *AttachmentController) GetAttachment(c *fiber.Ctx) error {
+	attachment, err := ac.findAttachment(c)
+	if err != nil {
+		return err
+	}
+
+	ac.withURL(c.Context(), &attachment)
+	return c.JSON(attachment)
+}
+
+// download an attachment under its original name
+func (ac *AttachmentController) DownloadAttachment(c *fiber.Ctx) error {
+	attachment, err := ac.findAttachment(c)
+	if err != nil {
+		return err
+	}
+
+	return serveObject(c, ac.files, attachment.Key, "private, no-cache", attachment.Name)
+}
+
+// delete an attachment
+func (ac *AttachmentController) DeleteAttachment(c *fiber.Ctx) error {
+	attachment, err := ac.findAttachment(c)
+	if err != nil {
+		return err
+	}
+
+	if err := ac.attachments.Delete(context.Background(), attachment.ID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("Attachment not found")
+		}
+		return apperrors.Internal(err)
+	}
+	ac.deleteFile(context.Background(), attachment.Key)
+
+	return c.JSON(fiber.Map{"message": "Attachment deleted successfully"})
+}
+
+// deleteFile removes attachment content, failures are left to the orphan collector
+func (ac *AttachmentController) deleteFile(ctx context.Context, key string) {
+	if err := ac.files.Delete(ctx, key); err != nil {
+		log.Printf("Failed to delete attachment %s: %v", key, err)
+	}
+}
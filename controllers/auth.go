@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Login request body
+type LoginInput struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// Login response with expiry
+type LoginResponse struct {
+	Token            string `json:"token"`
+	ExpiresAt        int64  `json:"expires_at"` // UNIX timestamp
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresAt int64  `json:"refresh_expires_at"` // UNIX timestamp
+}
+
+// Refresh request body
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// AuthController handles login, token refresh and logout
+type AuthController struct {
+	users    repositories.UserRepository
+	sessions repositories.SessionRepository
+}
+
+// NewAuthController creates an AuthController using the given repositories
+func NewAuthController(users repositories.UserRepository, sessions repositories.SessionRepository) *AuthController {
+	return &AuthController{users: users, sessions: sessions}
+}
+
+// issueTokens signs a short lived access token bound to the session
+// and returns it together with the login response
+func issueTokens(user models.User, session models.Session, refreshToken string) (LoginResponse, error) {
+	jwtSecret := []byte(os.Getenv("JWT_SECRET"))
+
+	// Expiry time
+	expirationTime := time.Now().Add(time.Duration(config.Cfg.JWTTTLMin) * time.Minute).Unix()
+	// Create JWT token
+	claims := jwt.MapClaims{
+		"user_id": user.ID.Hex(),
+		"role":    user.Role,
+		"sid":     session.ID.Hex(),
+		"exp":     expirationTime,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signedToken, err := token.SignedString(jwtSecret)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+
+	return LoginResponse{
+		Token:            signedToken,
+		ExpiresAt:        expirationTime,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: session.ExpiresAt.Unix(),
+	}, nil
+}
+
+// createSession starts a new refresh token family for the user
+func (ac *AuthController) createSession(ctx context.Context, user models.User) (models.Session, string, error) {
+	refreshToken, err := utils.GenerateRandomToken()
+	if err != nil {
+		return models.Session{}, "", err
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:          primitive.NewObjectID(),
+		UserID:      user.ID,
+		RefreshHash: utils.HashToken(refreshToken),
+		UsedHashes:  []string{},
+		ExpiresAt:   now.Add(time.Duration(config.Cfg.RefreshTTLHours) * time.Hour),
+		CreatedAt:   now,
+		LastUsedAt:  now,
+	}
+
+	if err := ac.sessions.Create(ctx, &session); err != nil {
+		return models.Session{}, "", err
+	}
+
+	return session, refreshToken, nil
+}
+
+// Login user
+func (ac *AuthController) Login(c *fiber.Ctx) error {
+	var input LoginInput
+	if err := c.BodyParser(&input); err != nil {
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
+	}
+	if errs := utils.ValidateStruct(input); errs != nil {
+		return apperrors.Validation(errs)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Find user by email
+	// unknown email and wrong password look the same to the client
+	user, err := ac.users.FindByEmail(ctx, input.Email)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.Unauthorized("Invalid email or password").WithCode(apperrors.CodeInvalidCredentials)
+	} else if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	// Check password
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		return apperrors.Unauthorized("Invalid email or password").WithCode(apperrors.CodeInvalidCredentials)
+	}
+
+	// start a new session (refresh token family)
+	session, refreshToken, err := ac.createSession(ctx, user)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	res, err := issueTokens(user, session, refreshToken)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return c.JSON(res)
+}
+
+// exchange a refresh token for a new access/refresh token pair
+func (ac *AuthController) RefreshToken(c *fiber.Ctx) error {
+	var input RefreshInput
+	if err := c.BodyParser(&input); err != nil {
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
+	}
+	if errs := utils.ValidateStruct(input); errs != nil {
+		return apperrors.Validation(errs)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	hash := utils.HashToken(input.RefreshToken)
+
+	session, err := ac.sessions.FindByRefreshHash(ctx, hash)
+	if errors.Is(err, repositories.ErrNotFound) {
+		// an already rotated token is being replayed, kill the whole family
+		if reused, err := ac.sessions.FindByUsedHash(ctx, hash); err == nil {
+			if err := ac.sessions.Revoke(ctx, reused.ID); err != nil {
+				return apperrors.Internal(err)
+			}
+			return apperrors.Unauthorized("Refresh token reuse detected, session revoked").WithCode(apperrors.CodeTokenReused)
+		}
+		return apperrors.Unauthorized("Invalid refresh token").WithCode(apperrors.CodeInvalidToken)
+	} else if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if session.Revoked {
+		return apperrors.Unauthorized("Session has been revoked").WithCode(apperrors.CodeSessionRevoked)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return apperrors.Unauthorized("Refresh token expired").WithCode(apperrors.CodeTokenExpired)
+	}
+
+	user, err := ac.users.FindByID(ctx, session.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.Unauthorized("User no longer exists").WithCode(apperrors.CodeInvalidToken)
+		}
+		return apperrors.Internal(err)
+	}
+
+	// rotate the refresh token
+	newToken, err := utils.GenerateRandomToken()
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	if err := ac.sessions.Rotate(ctx, session.ID, hash, utils.HashToken(newToken)); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.Unauthorized("Invalid refresh token").WithCode(apperrors.CodeInvalidToken)
+		}
+		return apperrors.Internal(err)
+	}
+
+	res, err := issueTokens(user, session, newToken)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return c.JSON(res)
+}
+
+// logout revokes the session of the current access token
+func (ac *AuthController) Logout(c *fiber.Ctx) error {
+	sid, err := primitive.ObjectIDFromHex(c.Locals("session_id").(string))
+	if err != nil {
+		return apperrors.Unauthorized("Invalid session").WithCode(apperrors.CodeInvalidToken)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := ac.sessions.Revoke(ctx, sid); err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Logged out successfully"})
+}
+
+// logout from every device by revoking all sessions of the current user
+func (ac *AuthController) LogoutAll(c *fiber.Ctx) error {
+	uid, err := primitive.ObjectIDFromHex(c.Locals("user_id").(string))
+	if err != nil {
+		return apperrors.Unauthorized("Invalid user").WithCode(apperrors.CodeInvalidToken)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	revoked, err := ac.sessions.RevokeAllForUser(ctx, uid)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	return c.JSON(fiber.Map{"message": "Logged out from all sessions", "revoked": revoked})
+}
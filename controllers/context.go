@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// currentUserID returns the authenticated user set by AuthRequired,
+// nil on public routes
+func currentUserID(c *fiber.Ctx) *primitive.ObjectID {
+	userID, _ := c.Locals("user_id").(string)
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+// queryTime parses an optional RFC 3339 query param
+func queryTime(c *fiber.Ctx, key string) (*time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, apperrors.BadRequest("Invalid " + key + ", expected an RFC 3339 timestamp")
+	}
+	return &t, nil
+}
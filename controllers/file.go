@@ -0,0 +1,230 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/storage"
+	"github.com/gofiber/fiber/v2"
+)
+
+// FileController serves stored files
+type FileController struct {
+	files       storage.Storage
+	todos       repositories.TodoRepository
+	lists       repositories.ListRepository
+	attachments repositories.AttachmentRepository
+	signer      *storage.Signer
+}
+
+// NewFileController creates a FileController, signer checks signed file URLs
+func NewFileController(files storage.Storage, todos repositories.TodoRepository, lists repositories.ListRepository, attachments repositories.AttachmentRepository, signer *storage.Signer) *FileController {
+	return &FileController{files: files, todos: todos, lists: lists, attachments: attachments, signer: signer}
+}
+
+// fileKey is the storage key in the wildcard part of the path
+func fileKey(c *fiber.Ctx) (string, error) {
+	key, err := url.PathUnescape(c.Params("+"))
+	if err == nil {
+		err = storage.ValidateKey(key)
+	}
+	if err != nil {
+		return "", apperrors.BadRequest("Invalid file key")
+	}
+	return key, nil
+}
+
+// serve a file through a signed URL, requests without a signature
+// fall through to ServeFile
+func (fc *FileController) ServeSigned(c *fiber.Ctx) error {
+	if c.Query("signature") == "" {
+		return c.Next()
+	}
+	key, err := fileKey(c)
+	if err != nil {
+		return err
+	}
+
+	err = fc.signer.Verify(key, c.Query("expires"), c.Query("signature"))
+	if errors.Is(err, storage.ErrExpiredSignature) {
+		return apperrors.Forbidden("File URL has expired").WithCode(apperrors.CodeSignatureExpired)
+	} else if err != nil {
+		return apperrors.Forbidden("Invalid file signature").WithCode(apperrors.CodeInvalidSignature)
+	}
+
+	// the URL stays valid until it expires, so browsers may keep it that long
+	expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+	maxAge := int64(time.Until(time.Unix(expires, 0)) / time.Second)
+	return serveObject(c, fc.files, key, fmt.Sprintf("private, max-age=%d", max(maxAge, 0)), "")
+}
+
+// serve a todo image or attachment to anyone who can view the todo, as its
+// owner or a collaborator on the todo or its list, or who may read all todos
+func (fc *FileController) ServeFile(c *fiber.Ctx) error {
+	key, err := fileKey(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	todo, err := fc.todos.FindByImage(ctx, key)
+	if errors.Is(err, repositories.ErrNotFound) {
+		var attachment models.Attachment
+		attachment, err = fc.attachments.FindByKey(ctx, key)
+		if err == nil {
+			todo, err = fc.todos.FindByID(ctx, attachment.TodoID)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("File not found")
+		}
+		return apperrors.Internal(err)
+	}
+
+	if err := fc.canView(ctx, c, todo); err != nil {
+		return err
+	}
+
+	// access can be revoked, so caches must revalidate every time
+	return serveObject(c, fc.files, key, "private, no-cache", "")
+}
+
+// canView applies the rule of EnsureTodoAccess for viewing the todo
+func (fc *FileController) canView(ctx context.Context, c *fiber.Ctx, todo models.Todo) error {
+	role, _ := c.Locals("role").(string)
+	if config.HasPermission(role, config.PermTodosReadAll) {
+		return nil
+	}
+	userID := currentUserID(c)
+	if userID == nil {
+		return apperrors.Forbidden("You are not allowed to access this file")
+	}
+
+	// the list only matters when the todo alone doesn't grant access
+	granted := todo.Access(*userID, nil)
+	if granted == models.AccessNone && todo.ListID != nil {
+		list, err := fc.lists.FindByID(ctx, *todo.ListID)
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.Internal(err)
+		}
+		if err == nil {
+			granted = todo.Access(*userID, &list)
+		}
+	}
+	if granted == models.AccessNone {
+		return apperrors.Forbidden("You are not allowed to access this file")
+	}
+	return nil
+}
+
+// serveObject streams the object with ETag, Last-Modified and single Range
+// support, a download name makes browsers save it instead of displaying it
+func serveObject(c *fiber.Ctx, files storage.Storage, key, cacheControl, downloadName string) error {
+	// not bound to the request: the body is streamed after the handler returns
+	body, obj, err := files.Get(context.Background(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return apperrors.NotFound("File not found")
+		}
+		return apperrors.Internal(err)
+	}
+
+	etag := obj.ETag
+	if etag != "" && !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, "W/") {
+		etag = `"` + etag + `"`
+	}
+	c.Set(fiber.HeaderContentType, obj.ContentType)
+	c.Set(fiber.HeaderCacheControl, cacheControl)
+	c.Set(fiber.HeaderAcceptRanges, "bytes")
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	if downloadName != "" {
+		c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("attachment", map[string]string{"filename": downloadName}))
+	}
+	if etag != "" {
+		c.Set(fiber.HeaderETag, etag)
+	}
+	if !obj.LastModified.IsZero() {
+		c.Set(fiber.HeaderLastModified, obj.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(c, etag, obj.LastModified) {
+		body.Close()
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	if c.Get(fiber.HeaderRange) == "" || !rangeStillValid(c, etag) {
+		return c.SendStream(body, int(obj.Size))
+	}
+	rng, err := c.Range(int(obj.Size))
+	if errors.Is(err, fiber.ErrRangeUnsatisfiable) {
+		body.Close()
+		c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", obj.Size))
+		return apperrors.RangeNotSatisfiable("Requested range is outside the file")
+	}
+	// malformed and multipart ranges are answered with the whole file
+	if err != nil || rng.Type != "bytes" || len(rng.Ranges) != 1 {
+		return c.SendStream(body, int(obj.Size))
+	}
+
+	start, end := int64(rng.Ranges[0].Start), int64(rng.Ranges[0].End)
+	if seeker, ok := body.(io.Seeker); ok {
+		_, err = seeker.Seek(start, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, body, start)
+	}
+	if err != nil {
+		body.Close()
+		return apperrors.Internal(err)
+	}
+
+	c.Status(fiber.StatusPartialContent)
+	c.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", start, end, obj.Size))
+	partial := struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(body, end-start+1), body}
+	return c.SendStream(partial, int(end-start+1))
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when there is no ETag condition
+func notModified(c *fiber.Ctx, etag string, lastModified time.Time) bool {
+	if match := c.Get(fiber.HeaderIfNoneMatch); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || (etag != "" && strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/")) {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince))
+	if err != nil || lastModified.IsZero() {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// rangeStillValid evaluates If-Range, for dates and stale ETags the whole file is sent
+func rangeStillValid(c *fiber.Ctx, etag string) bool {
+	ifRange := c.Get(fiber.HeaderIfRange)
+	if ifRange == "" {
+		return true
+	}
+	// only strong ETags are valid for If-Range
+	return etag != "" && !strings.HasPrefix(etag, "W/") && ifRange == etag
+}
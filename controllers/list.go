@@ -0,0 +1,247 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Create list request body, the list goes after the user's other lists
+// unless a position is given
+type CreateListInput struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	Color    string `json:"color" validate:"omitempty,hexcolor"`
+	Position *int   `json:"position" validate:"omitempty,min=0"`
+}
+
+// Update list request body, an empty color removes it
+type UpdateListInput struct {
+	Name     *string `json:"name" validate:"omitempty,min=1,max=100"`
+	Color    *string `json:"color" validate:"omitempty,eq=|hexcolor"`
+	Archived *bool   `json:"archived"`
+	Position *int    `json:"position" validate:"omitempty,min=0"`
+}
+
+// ListController handles the todo lists of the current user,
+// the routes of a single list are guarded by EnsureListAccess
+type ListController struct {
+	lists repositories.ListRepository
+	todos *TodoController
+}
+
+// NewListController creates a ListController, todos lists and deletes the
+// todos of a list
+func NewListController(lists repositories.ListRepository, todos *TodoController) *ListController {
+	return &ListController{lists: lists, todos: todos}
+}
+
+// add a new list for the current user
+func (lc *ListController) CreateList(c *fiber.Ctx) error {
+	userID := currentUserID(c)
+	if userID == nil {
+		return apperrors.Unauthorized("Missing user")
+	}
+
+	var body CreateListInput
+	if err := c.BodyParser(&body); err != nil {
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
+	}
+	if errs := utils.ValidateStruct(body); errs != nil {
+		return apperrors.Validation(errs)
+	}
+
+	list := models.List{
+		UserID:   *userID,
+		Name:     strings.TrimSpace(body.Name),
+		Color:    strings.ToLower(body.Color),
+		Position: -1,
+		Audit:    models.Audit{CreatedBy: userID},
+	}
+	if body.Position != nil {
+		list.Position = *body.Position
+	}
+
+	if err := lc.lists.Create(context.Background(), &list); err != nil {
+		return apperrors.Internal(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(list)
+}
+
+// get the current user's lists and those shared with them (unless
+// shared=false), archived=true lists the archived ones
+func (lc *ListController) GetLists(c *fiber.Ctx) error {
+	userID := currentUserID(c)
+	if userID == nil {
+		return apperrors.Unauthorized("Missing user")
+	}
+
+	archived := false
+	if value := c.Query("archived"); value != "" {
+		var err error
+		if archived, err = strconv.ParseBool(value); err != nil {
+			return apperrors.BadRequest("Invalid archived, expected true or false")
+		}
+	}
+	req, err := pageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	page, err := lc.lists.FindPage(context.Background(), repositories.ListQuery{UserID: *userID, Shared: c.QueryBool("shared", true), Archived: &archived}, req)
+	if err != nil {
+		return pageError(err)
+	}
+	return sendPage(c, req, page)
+}
+
+// get list by id
+func (lc *ListController) GetList(c *fiber.Ctx) error {
+	listID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid list ID")
+	}
+
+	list, err := lc.lists.FindByID(context.Background(), listID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("List not found")
+		}
+		return apperrors.Internal(err)
+	}
+	return c.JSON(list)
+}
+
+// rename or recolor a list, its owner can also archive or reorder it
+func (lc *ListController) UpdateList(c *fiber.Ctx) error {
+	listID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid list ID")
+	}
+
+	var body UpdateListInput
+	if err := c.BodyParser(&body); err != nil {
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
+	}
+	if errs := utils.ValidateStruct(body); errs != nil {
+		return apperrors.Validation(errs)
+	}
+	if body.Name == nil && body.Color == nil && body.Archived == nil && body.Position == nil {
+		return apperrors.BadRequest("Nothing to update")
+	}
+	// archiving and ordering are about the owner's own lists
+	if access, _ := c.Locals("access").(models.Access); access < models.AccessOwner && (body.Archived != nil || body.Position != nil) {
+		return apperrors.Forbidden("Only the owner can archive or reorder a list")
+	}
+	if body.Name != nil {
+		name := strings.TrimSpace(*body.Name)
+		body.Name = &name
+	}
+	if body.Color != nil {
+		color := strings.ToLower(*body.Color)
+		body.Color = &color
+	}
+
+	list, err := lc.lists.Update(context.Background(), listID, repositories.ListUpdate{
+		Name:      body.Name,
+		Color:     body.Color,
+		Archived:  body.Archived,
+		Position:  body.Position,
+		UpdatedBy: currentUserID(c),
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("List not found")
+		}
+		return apperrors.Internal(err)
+	}
+	return c.JSON(list)
+}
+
+// delete a list, todos=inbox (the default) moves its todos to the inbox
+// and todos=delete deletes them with the list
+func (lc *ListController) DeleteList(c *fiber.Ctx) error {
+	listID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid list ID")
+	}
+	mode := c.Query("todos", "inbox")
+	if mode != "inbox" && mode != "delete" {
+		return apperrors.BadRequest("Invalid todos, expected inbox or delete")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	// the todos go first, so a failure never leaves them in a missing list.
+	// Only the owner's todos are deleted, collaborators keep the todos they
+	// filed in the list, in their inbox
+	var deleted int64
+	if mode == "delete" {
+		list, err := lc.lists.FindByID(ctx, listID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				return apperrors.NotFound("List not found")
+			}
+			return apperrors.Internal(err)
+		}
+		todos, err := lc.todos.todos.FindByListID(ctx, listID)
+		if err != nil {
+			return apperrors.Internal(err)
+		}
+		for _, todo := range todos {
+			if todo.UserID != list.UserID {
+				continue
+			}
+			if err := lc.todos.deleteTodo(ctx, todo); err != nil && !errors.Is(err, repositories.ErrNotFound) {
+				return apperrors.Internal(err)
+			}
+			deleted++
+		}
+	}
+	moved, err := lc.todos.todos.MoveToInbox(ctx, listID, currentUserID(c))
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if err := lc.lists.Delete(ctx, listID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("List not found")
+		}
+		return apperrors.Internal(err)
+	}
+
+	if err := lc.todos.invitations.DeleteByResource(ctx, listID); err != nil {
+		log.Printf("Failed to delete invitations to list %s: %v", listID.Hex(), err)
+	}
+
+	result := fiber.Map{"message": "List deleted successfully", "movedTodos": moved}
+	if mode == "delete" {
+		result["deletedTodos"] = deleted
+	}
+	return c.JSON(result)
+}
+
+// get the todos of a list, with the filters of the todo listings
+func (lc *ListController) GetListTodos(c *fiber.Ctx) error {
+	listID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid list ID")
+	}
+
+	query, err := todoQuery(c)
+	if err != nil {
+		return err
+	}
+	query.ListID = &listID
+	return lc.todos.sendTodos(c, query)
+}
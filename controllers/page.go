@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListResponse is one page of a listing. Page is the page number when the
+// page was selected by number rather than by cursor.
+type ListResponse[T any] struct {
+	Data       []T    `json:"data"`
+	Limit      int64  `json:"limit"`
+	Page       int64  `json:"page,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+}
+
+// pageRequest reads limit, cursor or page, and total=true.
+// The limit is capped at repositories.MaxPageLimit.
+func pageRequest(c *fiber.Ctx) (repositories.PageRequest, error) {
+	req := repositories.PageRequest{
+		Limit: int64(c.QueryInt("limit", repositories.DefaultPageLimit)),
+		Total: c.QueryBool("total"),
+	}
+	if req.Limit < 1 {
+		req.Limit = repositories.DefaultPageLimit
+	}
+	req.Limit = min(req.Limit, repositories.MaxPageLimit)
+
+	if value := c.Query("cursor"); value != "" {
+		if c.Query("page") != "" {
+			return req, apperrors.BadRequest("Use either cursor or page")
+		}
+		cursor, err := repositories.DecodeCursor(value)
+		if err != nil {
+			return req, apperrors.BadRequest("Invalid cursor")
+		}
+		req.Cursor = cursor
+		return req, nil
+	}
+
+	page := max(int64(c.QueryInt("page", 1)), 1)
+	req.Skip = (page - 1) * req.Limit
+	return req, nil
+}
+
+// pageError maps a cursor made for another order to 400
+func pageError(err error) error {
+	if errors.Is(err, repositories.ErrInvalidCursor) {
+		return apperrors.BadRequest("Invalid cursor")
+	}
+	return apperrors.Internal(err)
+}
+
+// sendPage responds with a ListResponse and Link headers to the next and
+// previous pages
+func sendPage[T any](c *fiber.Ctx, req repositories.PageRequest, page repositories.Page[T]) error {
+	res := ListResponse[T]{Data: page.Items, Limit: req.Limit, Total: page.Total}
+	if res.Data == nil {
+		res.Data = []T{}
+	}
+	if req.Cursor == nil {
+		res.Page = req.Skip/req.Limit + 1
+	}
+
+	var links []string
+	if page.Next != nil {
+		res.NextCursor = page.Next.Encode()
+		links = append(links, `<`+pageURL(c, res.NextCursor)+`>; rel="next"`)
+	}
+	if page.Prev != nil {
+		res.PrevCursor = page.Prev.Encode()
+		links = append(links, `<`+pageURL(c, res.PrevCursor)+`>; rel="prev"`)
+	}
+	if len(links) > 0 {
+		c.Set(fiber.HeaderLink, strings.Join(links, ", "))
+	}
+	return c.JSON(res)
+}
+
+// pageURL is the current request URL moved to cursor, keeping the filters
+func pageURL(c *fiber.Ctx, cursor string) string {
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	query.Del("page")
+	query.Set("cursor", cursor)
+	return c.BaseURL() + c.Path() + "?" + query.Encode()
+}
@@ -0,0 +1,410 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Invite request body, user is an email address or a username
+type InviteInput struct {
+	User string `json:"user" validate:"required,max=254"`
+	Role string `json:"role" validate:"required,oneof=viewer editor"`
+}
+
+// Change collaborator role request body
+type ShareRoleInput struct {
+	Role string `json:"role" validate:"required,oneof=viewer editor"`
+}
+
+// Shares is who a todo or list is shared with
+type Shares struct {
+	OwnerID       primitive.ObjectID    `json:"ownerId"`
+	Collaborators []models.Collaborator `json:"collaborators"`
+	Invitations   []models.Invitation   `json:"invitations"` // pending ones
+}
+
+// ShareController shares todos and lists with other users, the routes of
+// a todo or list are guarded by EnsureTodoAccess / EnsureListAccess
+type ShareController struct {
+	todos       repositories.TodoRepository
+	lists       repositories.ListRepository
+	users       repositories.UserRepository
+	invitations repositories.InvitationRepository
+}
+
+// NewShareController creates a ShareController using the given repositories
+func NewShareController(todos repositories.TodoRepository, lists repositories.ListRepository, users repositories.UserRepository, invitations repositories.InvitationRepository) *ShareController {
+	return &ShareController{todos: todos, lists: lists, users: users, invitations: invitations}
+}
+
+// shared is a todo or list as far as sharing goes
+type shared struct {
+	resource      string // models.ResourceTodo or models.ResourceList
+	id            primitive.ObjectID
+	ownerID       primitive.ObjectID
+	name          string
+	collaborators []models.Collaborator
+}
+
+func (s shared) collaborator(userID primitive.ObjectID) (models.Collaborator, bool) {
+	for _, c := range s.collaborators {
+		if c.UserID == userID {
+			return c, true
+		}
+	}
+	return models.Collaborator{}, false
+}
+
+// notFound is the 404 for a missing todo or list
+func notFound(resource string) error {
+	if resource == models.ResourceList {
+		return apperrors.NotFound("List not found")
+	}
+	return apperrors.NotFound("Todo not found")
+}
+
+// load reads the :id todo or list
+func (sc *ShareController) load(c *fiber.Ctx, resource string) (shared, error) {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return shared{}, apperrors.BadRequest("Invalid " + resource + " ID")
+	}
+	return sc.find(context.Background(), resource, id)
+}
+
+func (sc *ShareController) find(ctx context.Context, resource string, id primitive.ObjectID) (shared, error) {
+	s := shared{resource: resource, id: id}
+	if resource == models.ResourceList {
+		list, err := sc.lists.FindByID(ctx, id)
+		if err != nil {
+			return s, shareError(err, resource)
+		}
+		s.ownerID, s.name, s.collaborators = list.UserID, list.Name, list.Collaborators
+		return s, nil
+	}
+	todo, err := sc.todos.FindByID(ctx, id)
+	if err != nil {
+		return s, shareError(err, resource)
+	}
+	s.ownerID, s.name, s.collaborators = todo.UserID, todo.Title, todo.Collaborators
+	return s, nil
+}
+
+func (sc *ShareController) setCollaborator(ctx context.Context, s shared, collaborator models.Collaborator) error {
+	var err error
+	if s.resource == models.ResourceList {
+		_, err = sc.lists.SetCollaborator(ctx, s.id, collaborator)
+	} else {
+		_, err = sc.todos.SetCollaborator(ctx, s.id, collaborator)
+	}
+	return shareError(err, s.resource)
+}
+
+func (sc *ShareController) removeCollaborator(ctx context.Context, s shared, userID primitive.ObjectID) error {
+	var err error
+	if s.resource == models.ResourceList {
+		_, err = sc.lists.RemoveCollaborator(ctx, s.id, userID)
+	} else {
+		_, err = sc.todos.RemoveCollaborator(ctx, s.id, userID)
+	}
+	return shareError(err, s.resource)
+}
+
+// shareError maps a missing todo or list to 404
+func shareError(err error, resource string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return notFound(resource)
+	}
+	return apperrors.Internal(err)
+}
+
+// findUser looks the user up by email when value has an @, by username otherwise
+func (sc *ShareController) findUser(ctx context.Context, value string) (models.User, error) {
+	var user models.User
+	var err error
+	if value = strings.TrimSpace(value); strings.Contains(value, "@") {
+		user, err = sc.users.FindByEmail(ctx, value)
+	} else {
+		user, err = sc.users.FindByUsername(ctx, value)
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return user, apperrors.NotFound("User not found")
+	} else if err != nil {
+		return user, apperrors.Internal(err)
+	}
+	return user, nil
+}
+
+// invite a user to collaborate, they are added once they accept
+func (sc *ShareController) invite(c *fiber.Ctx, resource string) error {
+	var body InviteInput
+	if err := c.BodyParser(&body); err != nil {
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
+	}
+	if errs := utils.ValidateStruct(body); errs != nil {
+		return apperrors.Validation(errs)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s, err := sc.load(c, resource)
+	if err != nil {
+		return err
+	}
+	invitee, err := sc.findUser(ctx, body.User)
+	if err != nil {
+		return err
+	}
+	if invitee.ID == s.ownerID {
+		return apperrors.BadRequest("The owner already has access")
+	}
+	if _, ok := s.collaborator(invitee.ID); ok {
+		return apperrors.Conflict("Already shared with this user, change their role instead")
+	}
+
+	invitation := models.Invitation{
+		Resource:   resource,
+		ResourceID: s.id,
+		Name:       s.name,
+		UserID:     invitee.ID,
+		Role:       body.Role,
+		InvitedBy:  *currentUserID(c),
+	}
+	if err := sc.invitations.Create(ctx, &invitation); err != nil {
+		var dup *repositories.DuplicateError
+		if errors.As(err, &dup) {
+			return apperrors.Conflict("An invitation to this user is already pending")
+		}
+		return apperrors.Internal(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(invitation)
+}
+
+// list the collaborators and pending invitations
+func (sc *ShareController) shares(c *fiber.Ctx, resource string) error {
+	s, err := sc.load(c, resource)
+	if err != nil {
+		return err
+	}
+	invitations, err := sc.invitations.FindPending(context.Background(), s.id)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	res := Shares{OwnerID: s.ownerID, Collaborators: s.collaborators, Invitations: invitations}
+	if res.Collaborators == nil {
+		res.Collaborators = []models.Collaborator{}
+	}
+	return c.JSON(res)
+}
+
+// change a collaborator's role
+func (sc *ShareController) updateShare(c *fiber.Ctx, resource string) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid user ID")
+	}
+	var body ShareRoleInput
+	if err := c.BodyParser(&body); err != nil {
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
+	}
+	if errs := utils.ValidateStruct(body); errs != nil {
+		return apperrors.Validation(errs)
+	}
+
+	s, err := sc.load(c, resource)
+	if err != nil {
+		return err
+	}
+	collaborator, ok := s.collaborator(userID)
+	if !ok {
+		return apperrors.NotFound("Collaborator not found")
+	}
+	collaborator.Role = body.Role
+	if err := sc.setCollaborator(context.Background(), s, collaborator); err != nil {
+		return err
+	}
+	return c.JSON(collaborator)
+}
+
+// stop sharing with a collaborator, collaborators may remove themselves
+func (sc *ShareController) removeShare(c *fiber.Ctx, resource string) error {
+	userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid user ID")
+	}
+	if access, _ := c.Locals("access").(models.Access); access < models.AccessOwner && userID != *currentUserID(c) {
+		return apperrors.Forbidden("Only the owner can remove other collaborators")
+	}
+
+	s, err := sc.load(c, resource)
+	if err != nil {
+		return err
+	}
+	if _, ok := s.collaborator(userID); !ok {
+		return apperrors.NotFound("Collaborator not found")
+	}
+	if err := sc.removeCollaborator(context.Background(), s, userID); err != nil {
+		return err
+	}
+	return c.JSON(fiber.Map{"message": "Collaborator removed successfully"})
+}
+
+// invite a user to collaborate on a todo
+func (sc *ShareController) InviteToTodo(c *fiber.Ctx) error {
+	return sc.invite(c, models.ResourceTodo)
+}
+
+// invite a user to collaborate on a list and its todos
+func (sc *ShareController) InviteToList(c *fiber.Ctx) error {
+	return sc.invite(c, models.ResourceList)
+}
+
+// get who a todo is shared with
+func (sc *ShareController) GetTodoShares(c *fiber.Ctx) error {
+	return sc.shares(c, models.ResourceTodo)
+}
+
+// get who a list is shared with
+func (sc *ShareController) GetListShares(c *fiber.Ctx) error {
+	return sc.shares(c, models.ResourceList)
+}
+
+// change a todo collaborator's role
+func (sc *ShareController) UpdateTodoShare(c *fiber.Ctx) error {
+	return sc.updateShare(c, models.ResourceTodo)
+}
+
+// change a list collaborator's role
+func (sc *ShareController) UpdateListShare(c *fiber.Ctx) error {
+	return sc.updateShare(c, models.ResourceList)
+}
+
+// stop sharing a todo with a collaborator
+func (sc *ShareController) RemoveTodoShare(c *fiber.Ctx) error {
+	return sc.removeShare(c, models.ResourceTodo)
+}
+
+// stop sharing a list with a collaborator
+func (sc *ShareController) RemoveListShare(c *fiber.Ctx) error {
+	return sc.removeShare(c, models.ResourceList)
+}
+
+// get the current user's invitations, pending unless status says otherwise
+func (sc *ShareController) GetInvitations(c *fiber.Ctx) error {
+	status := c.Query("status", models.InvitationPending)
+	switch status {
+	case models.InvitationPending, models.InvitationAccepted, models.InvitationDeclined:
+	default:
+		return apperrors.BadRequest("Invalid status, expected pending, accepted or declined")
+	}
+	req, err := pageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	query := repositories.InvitationQuery{UserID: *currentUserID(c), Status: status}
+	page, err := sc.invitations.FindPage(context.Background(), query, req)
+	if err != nil {
+		return pageError(err)
+	}
+	return sendPage(c, req, page)
+}
+
+// findInvitation loads the :id invitation, only the invitee and the
+// inviter can see it
+func (sc *ShareController) findInvitation(c *fiber.Ctx) (models.Invitation, error) {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return models.Invitation{}, apperrors.BadRequest("Invalid invitation ID")
+	}
+	invitation, err := sc.invitations.FindByID(context.Background(), id)
+	userID := *currentUserID(c)
+	if errors.Is(err, repositories.ErrNotFound) || (err == nil && invitation.UserID != userID && invitation.InvitedBy != userID) {
+		return models.Invitation{}, apperrors.NotFound("Invitation not found")
+	} else if err != nil {
+		return models.Invitation{}, apperrors.Internal(err)
+	}
+	return invitation, nil
+}
+
+// respond accepts or declines the invitation as its invitee
+func (sc *ShareController) respond(c *fiber.Ctx, status string) (models.Invitation, error) {
+	invitation, err := sc.findInvitation(c)
+	if err != nil {
+		return invitation, err
+	}
+	if invitation.UserID != *currentUserID(c) {
+		return invitation, apperrors.Forbidden("Only the invited user can answer an invitation")
+	}
+
+	invitation, err = sc.invitations.Respond(context.Background(), invitation.ID, status)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return invitation, apperrors.Conflict("The invitation was already answered")
+	} else if err != nil {
+		return invitation, apperrors.Internal(err)
+	}
+	return invitation, nil
+}
+
+// accept an invitation, making the current user a collaborator
+func (sc *ShareController) AcceptInvitation(c *fiber.Ctx) error {
+	invitation, err := sc.respond(c, models.InvitationAccepted)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	s, err := sc.find(ctx, invitation.Resource, invitation.ResourceID)
+	if err == nil {
+		collaborator := models.Collaborator{UserID: invitation.UserID, Role: invitation.Role, AddedAt: *invitation.RespondedAt}
+		err = sc.setCollaborator(ctx, s, collaborator)
+	}
+	if err != nil {
+		// the invitation stays open, so accepting it can be retried
+		if err := sc.invitations.Reopen(ctx, invitation.ID); err != nil {
+			log.Printf("Failed to reopen invitation %s: %v", invitation.ID.Hex(), err)
+		}
+		return err
+	}
+	return c.JSON(invitation)
+}
+
+// decline an invitation
+func (sc *ShareController) DeclineInvitation(c *fiber.Ctx) error {
+	invitation, err := sc.respond(c, models.InvitationDeclined)
+	if err != nil {
+		return err
+	}
+	return c.JSON(invitation)
+}
+
+// withdraw an invitation, only its sender can
+func (sc *ShareController) CancelInvitation(c *fiber.Ctx) error {
+	invitation, err := sc.findInvitation(c)
+	if err != nil {
+		return err
+	}
+	if invitation.InvitedBy != *currentUserID(c) {
+		return apperrors.Forbidden("Only the sender can withdraw an invitation")
+	}
+
+	if err := sc.invitations.Delete(context.Background(), invitation.ID); err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.Internal(err)
+	}
+	return c.JSON(fiber.Map{"message": "Invitation withdrawn successfully"})
+}
@@ -2,281 +2,487 @@ package controllers
 
 import (
 	"context"
-	"fmt"
-	"os"
-	"strings"
+	"errors"
 	"time"
 
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
 	"github.com/clinton-mwachia/go-fiber-api-template/config"
 	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/storage"
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
-var todoCollection *mongo.Collection
+// Create todo request body (JSON or multipart with an image file part),
+// dueAt is RFC 3339 or a date-time / date read in dueTimezone
+type CreateTodoInput struct {
+	Title       string   `json:"title" form:"title" validate:"required,max=200"`
+	UserID      string   `json:"userId" form:"userId" validate:"required,mongodb"`
+	ListID      string   `json:"listId" form:"listId" validate:"omitempty,mongodb"`
+	Description string   `json:"description" form:"description" validate:"max=10000"`
+	DueAt       string   `json:"dueAt" form:"dueAt"`
+	DueTimezone string   `json:"dueTimezone" form:"dueTimezone" validate:"omitempty,timezone"`
+	Priority    int      `json:"priority" form:"priority" validate:"min=0,max=3"`
+	Tags        []string `json:"tags" form:"tags" validate:"max=20,dive,max=50"`
+	// AutoComplete completes the todo once all of its checklist items are done
+	AutoComplete bool `json:"autoComplete" form:"autoComplete"`
+	// Recurrence is an RRULE repeating the todo from its due date,
+	// RecurrenceExceptions the occurrences to skip
+	Recurrence           string   `json:"recurrence" form:"recurrence" validate:"max=500"`
+	RecurrenceExceptions []string `json:"recurrenceExceptions" form:"recurrenceExceptions" validate:"max=100"`
+}
+
+// Update todo request body (JSON or multipart with an image file part),
+// an empty dueAt clears the due date, an empty tags list clears the tags
+// and an empty listId moves the todo to the inbox; a recurrence restarts
+// the series at the due date and an empty one stops it
+type UpdateTodoInput struct {
+	Title        *string  `json:"title" form:"title" validate:"omitempty,min=1,max=200"`
+	ListID       *string  `json:"listId" form:"listId" validate:"omitempty,eq=|mongodb"`
+	Description  *string  `json:"description" form:"description" validate:"omitempty,max=10000"`
+	Completed    *bool    `json:"completed" form:"completed"`
+	DueAt        *string  `json:"dueAt" form:"dueAt"`
+	DueTimezone  *string  `json:"dueTimezone" form:"dueTimezone" validate:"omitempty,timezone"`
+	Priority     *int     `json:"priority" form:"priority" validate:"omitempty,min=0,max=3"`
+	Tags         []string `json:"tags" form:"tags" validate:"omitempty,max=20,dive,max=50"`
+	AutoComplete *bool    `json:"autoComplete" form:"autoComplete"`
+	Recurrence   *string  `json:"recurrence" form:"recurrence" validate:"omitempty,max=500"`
+	// RecurrenceExceptions replaces the skipped occurrences, an empty list clears them
+	RecurrenceExceptions []string `json:"recurrenceExceptions" form:"recurrenceExceptions" validate:"omitempty,max=100"`
+}
+
+// TodoController handles the todo routes
+type TodoController struct {
+	todos       repositories.TodoRepository
+	users       repositories.UserRepository
+	lists       repositories.ListRepository
+	invitations repositories.InvitationRepository
+	reminders   repositories.ReminderRepository
+	attachments repositories.AttachmentRepository
+	files       storage.Storage
+}
+
+// NewTodoController creates a TodoController using the given repositories,
+// todo images and attachments are kept in files
+func NewTodoController(todos repositories.TodoRepository, users repositories.UserRepository, lists repositories.ListRepository, invitations repositories.InvitationRepository, reminders repositories.ReminderRepository, attachments repositories.AttachmentRepository, files storage.Storage) *TodoController {
+	return &TodoController{todos: todos, users: users, lists: lists, invitations: invitations, reminders: reminders, attachments: attachments, files: files}
+}
+
+// checkList makes sure the list exists and both the todo's owner and the
+// current user may add todos to it; a role that may write every todo only
+// needs the owner's access
+func (tc *TodoController) checkList(ctx context.Context, c *fiber.Ctx, listID, ownerID primitive.ObjectID) error {
+	list, err := tc.lists.FindByID(ctx, listID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("List not found")
+	}
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	owner, caller := list.Access(ownerID), models.AccessNone
+	if userID := currentUserID(c); userID != nil {
+		caller = list.Access(*userID)
+	}
+	role, _ := c.Locals("role").(string)
+	if config.HasPermission(role, config.PermTodosWriteAll) {
+		caller = models.AccessEdit
+	}
+	if owner == models.AccessNone || caller == models.AccessNone {
+		return apperrors.NotFound("List not found")
+	}
+	if owner < models.AccessEdit || caller < models.AccessEdit {
+		return apperrors.Forbidden("You are not allowed to add todos to this list")
+	}
+	return nil
+}
+
+// checkLeaveList makes sure the current user may take the todo out of its
+// list, which ends the access of those who only see it through the list:
+// the todo's owner, an editor of the list or a role that may write every todo
+func (tc *TodoController) checkLeaveList(ctx context.Context, c *fiber.Ctx, todo models.Todo) error {
+	userID := currentUserID(c)
+	role, _ := c.Locals("role").(string)
+	if todo.ListID == nil || (userID != nil && *userID == todo.UserID) || config.HasPermission(role, config.PermTodosWriteAll) {
+		return nil
+	}
+	list, err := tc.lists.FindByID(ctx, *todo.ListID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	if userID == nil || list.Access(*userID) < models.AccessEdit {
+		return apperrors.Forbidden("You are not allowed to take todos out of this list")
+	}
+	return nil
+}
+
+// sendTodos responds with the page of todos matching query
+func (tc *TodoController) sendTodos(c *fiber.Ctx, query repositories.TodoQuery) error {
+	req, err := pageRequest(c)
+	if err != nil {
+		return err
+	}
 
-// Init sets up the collections after DB connection
-func InitTodoCollection() {
-	todoCollection = config.GetCollection("todos")
+	page, err := tc.todos.Find(context.Background(), query, req)
+	if err != nil {
+		return pageError(err)
+	}
+
+	for i := range page.Items {
+		tc.present(c.Context(), &page.Items[i])
+	}
+	return sendPage(c, req, page)
 }
 
 // add a new todo
-func CreateTodo(c *fiber.Ctx) error {
-	title := c.FormValue("title")
-	userID := c.FormValue("userId")
+func (tc *TodoController) CreateTodo(c *fiber.Ctx) error {
+	var body CreateTodoInput
+	if err := c.BodyParser(&body); err != nil {
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
+	}
+	body.Tags = utils.NormalizeTags(body.Tags)
+	if errs := utils.ValidateStruct(body); errs != nil {
+		return apperrors.Validation(errs)
+	}
 
-	var user models.User
+	var dueAt *time.Time
+	if body.DueAt != "" {
+		t, err := parseDueAt(body.DueAt, body.DueTimezone)
+		if err != nil {
+			return dueAtError()
+		}
+		dueAt = &t
+	}
 
-	uid, err := primitive.ObjectIDFromHex(userID)
+	var rule *string
+	if body.Recurrence != "" {
+		rule = &body.Recurrence
+	}
+	var exceptions []string
+	if len(body.RecurrenceExceptions) > 0 {
+		exceptions = body.RecurrenceExceptions
+	}
+	recurrence, err := buildRecurrence(nil, rule, exceptions, dueAt, body.DueTimezone)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID: " + err.Error()})
+		return err
+	}
+
+	uid, _ := primitive.ObjectIDFromHex(body.UserID)
+
+	// todos are created for the current user unless the role may write every todo
+	role, _ := c.Locals("role").(string)
+	if caller := currentUserID(c); (caller == nil || *caller != uid) && !config.HasPermission(role, config.PermTodosWriteAll) {
+		return apperrors.Forbidden("You are not allowed to create todos for other users")
 	}
 
 	// confirm user exists
-	err = userCollection.FindOne(context.Background(), bson.M{"_id": uid}).Decode(&user)
+	_, err = tc.users.FindByID(context.Background(), uid)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return c.Status(404).JSON(fiber.Map{"error": "User not found: " + err.Error()})
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("User not found")
 		}
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch user: " + err.Error()})
+		return apperrors.Internal(err)
 	}
 
-	if title == "" || userID == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "Title & UserID is required"})
+	var listID *primitive.ObjectID
+	if body.ListID != "" {
+		id, _ := primitive.ObjectIDFromHex(body.ListID)
+		if err := tc.checkList(context.Background(), c, id, uid); err != nil {
+			return err
+		}
+		listID = &id
 	}
 
 	todo := models.Todo{
-		ID:        primitive.NewObjectID(),
-		UserID:    uid,
-		Title:     title,
-		Completed: false,
+		ID:           primitive.NewObjectID(),
+		UserID:       uid,
+		ListID:       listID,
+		Title:        body.Title,
+		Description:  body.Description,
+		Completed:    false,
+		DueAt:        dueAt,
+		DueTimezone:  body.DueTimezone,
+		Priority:     body.Priority,
+		Tags:         body.Tags,
+		AutoComplete: body.AutoComplete,
+		Recurrence:   recurrence,
+		Audit:        models.Audit{CreatedBy: currentUserID(c)},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// new todos go to the end of their list or inbox
+	if todo.Rank, err = tc.rankAt(ctx, repositories.ScopeOf(todo), nil, false, todo.ID); err != nil {
+		return apperrors.Internal(err)
 	}
 
 	// Handle image upload
 	file, err := c.FormFile("image")
 	if err == nil {
-		// Save file
-		filename := fmt.Sprintf("uploads/%s_%s", time.Now().Format("20060102150405"), strings.ToLower(file.Filename))
-		if err := c.SaveFile(file, filename); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to save image"})
+		img, err := tc.saveImage(ctx, file)
+		if err != nil {
+			return err
 		}
-		todo.Image = filename
+		todo.Image = img.Key
+		todo.ImageVariants = img.Variants
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err = todoCollection.InsertOne(ctx, todo)
+	err = tc.todos.Create(ctx, &todo)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create todo: " + err.Error()})
+		tc.deleteFiles(ctx, todo.ImageKeys()...)
+		return apperrors.Internal(err)
 	}
 
-	res := models.Todo{
-		ID:        todo.ID,
-		UserID:    todo.UserID,
-		Title:     todo.Title,
-		Completed: todo.Completed,
-		Image:     todo.Image,
-	}
-
-	return c.Status(201).JSON(res)
+	tc.present(ctx, &todo)
+	return c.Status(201).JSON(todo)
 }
 
 // get all todos
-func GetTodos(c *fiber.Ctx) error {
-	cursor, err := todoCollection.Find(context.Background(), bson.M{})
+func (tc *TodoController) GetTodos(c *fiber.Ctx) error {
+	query, err := todoQuery(c)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch todos: " + err.Error()})
-	}
-	defer cursor.Close(context.Background())
-
-	todos := []models.Todo{}
-	if err := cursor.All(context.Background(), &todos); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse todos: " + err.Error()})
+		return err
 	}
-
-	return c.JSON(todos)
+	return tc.sendTodos(c, query)
 }
 
 // delete todo by id
-func DeleteTodo(c *fiber.Ctx) error {
+func (tc *TodoController) DeleteTodo(c *fiber.Ctx) error {
 	idParam := c.Params("id")
 	todoID, err := primitive.ObjectIDFromHex(idParam)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID: " + err.Error()})
+		return apperrors.BadRequest("Invalid ID")
 	}
 
 	// Find the todo first to get image path
-	var todo models.Todo
-	err = todoCollection.FindOne(context.Background(), bson.M{"_id": todoID}).Decode(&todo)
+	todo, err := tc.todos.FindByID(context.Background(), todoID)
 	if err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Todo not found: " + err.Error()})
+		return apperrors.NotFound("Todo not found")
 	}
 
-	// Delete the todo
-	result, err := todoCollection.DeleteOne(context.Background(), bson.M{"_id": todoID})
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete todo " + err.Error()})
-	}
-	if result.DeletedCount == 0 {
-		return c.Status(404).JSON(fiber.Map{"error": "Todo not found"})
-	}
-
-	// Delete image file if it exists
-	if todo.Image != "" {
-		if err := os.Remove(todo.Image); err != nil {
-			c.Status(404).JSON(fiber.Map{"error": fmt.Sprintf("%s%s", "Failed to delete todo image: ", err.Error())})
+	// Delete the todo, its image and its attachments
+	if err := tc.deleteTodo(context.Background(), todo); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("Todo not found")
 		}
+		return apperrors.Internal(err)
 	}
 
 	return c.JSON(fiber.Map{"message": "Todo deleted successfully"})
 }
 
 // update a todo
-func UpdateTodo(c *fiber.Ctx) error {
+func (tc *TodoController) UpdateTodo(c *fiber.Ctx) error {
 	idParam := c.Params("id")
 	todoID, err := primitive.ObjectIDFromHex(idParam)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID: " + err.Error()})
+		return apperrors.BadRequest("Invalid ID")
 	}
 
-	var body struct {
-		Title     *string `json:"title"`
-		Completed *bool   `json:"completed"`
-	}
+	var body UpdateTodoInput
 	if err := c.BodyParser(&body); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid body: " + err.Error()})
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
+	}
+	body.Tags = utils.NormalizeTags(body.Tags)
+	if errs := utils.ValidateStruct(body); errs != nil {
+		return apperrors.Validation(errs)
 	}
 
 	// Fetch current todo
-	var todo models.Todo
-	if err := todoCollection.FindOne(context.Background(), bson.M{"_id": todoID}).Decode(&todo); err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Todo not found: " + err.Error()})
+	todo, err := tc.todos.FindByID(context.Background(), todoID)
+	if err != nil {
+		return apperrors.NotFound("Todo not found")
+	}
+
+	update := repositories.TodoUpdate{
+		Title:        body.Title,
+		Description:  body.Description,
+		Completed:    body.Completed,
+		DueTimezone:  body.DueTimezone,
+		Priority:     body.Priority,
+		Tags:         body.Tags,
+		AutoComplete: body.AutoComplete,
+		UpdatedBy:    currentUserID(c),
+	}
+	// an empty listId moves the todo to the inbox
+	if body.ListID != nil {
+		var listID primitive.ObjectID
+		if *body.ListID != "" {
+			listID, _ = primitive.ObjectIDFromHex(*body.ListID)
+			if err := tc.checkList(context.Background(), c, listID, todo.UserID); err != nil {
+				return err
+			}
+		}
+		if todo.ListID == nil || *todo.ListID != listID {
+			if err := tc.checkLeaveList(context.Background(), c, todo); err != nil {
+				return err
+			}
+		}
+		update.ListID = &listID
+	}
+	// a local due date is read in the new timezone, or the current one
+	tz, dueAt := todo.DueTimezone, todo.DueAt
+	if body.DueTimezone != nil {
+		tz = *body.DueTimezone
+	}
+	if body.DueAt != nil {
+		var t time.Time
+		if *body.DueAt != "" {
+			if t, err = parseDueAt(*body.DueAt, tz); err != nil {
+				return dueAtError()
+			}
+		}
+		update.DueAt, dueAt = &t, nil
+		if !t.IsZero() {
+			dueAt = &t
+		}
 	}
-
-	update := bson.M{}
-	if body.Title != nil {
-		update["title"] = *body.Title
+	if update.Recurrence, err = buildRecurrence(todo.Recurrence, body.Recurrence, body.RecurrenceExceptions, dueAt, tz); err != nil {
+		return err
 	}
-	if body.Completed != nil {
-		update["completed"] = *body.Completed
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// a todo filed in another list goes to its end
+	if update.ListID != nil {
+		moved := todo
+		moved.ListID = nil
+		if !update.ListID.IsZero() {
+			moved.ListID = update.ListID
+		}
+		if scope := repositories.ScopeOf(moved); !scope.Contains(todo) {
+			key, err := tc.rankAt(ctx, scope, nil, false, todoID)
+			if err != nil {
+				return apperrors.Internal(err)
+			}
+			update.Rank = &key
+		}
 	}
 
 	// Handle image upload
 	file, err := c.FormFile("image")
+	var img storedImage
 	if err == nil {
-		// Delete old image if exists
-		if todo.Image != "" {
-			if err := os.Remove(todo.Image); err != nil {
-				c.Status(500).JSON(fiber.Map{"error": fmt.Sprintf("%s%s", "Failed to delete old todo image:", err)})
-			}
-		}
-
-		// Save new image
-		filename := fmt.Sprintf("uploads/%s_%s", time.Now().Format("20060102150405"), strings.ToLower(file.Filename))
-		if err := c.SaveFile(file, filename); err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": "Failed to save new image: " + err.Error()})
+		img, err = tc.saveImage(ctx, file)
+		if err != nil {
+			return err
 		}
-		update["image"] = filename
+		update.Image = &img.Key
+		update.ImageVariants = img.Variants
 	}
 
-	if len(update) == 0 {
-		return c.Status(400).JSON(fiber.Map{"error": "Nothing to update"})
+	if update.Empty() {
+		return apperrors.BadRequest("Nothing to update")
 	}
 
-	// Update in MongoDB
-	_, err = todoCollection.UpdateOne(context.Background(), bson.M{"_id": todoID}, bson.M{"$set": update})
+	// Update and return updated todo
+	updated, err := tc.todos.Update(ctx, todoID, update)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to update todo: " + err.Error()})
+		tc.deleteFiles(ctx, img.keys()...)
+		return apperrors.Internal(err)
 	}
 
-	// Return updated todo
-	var updated models.Todo
-	_ = todoCollection.FindOne(context.Background(), bson.M{"_id": todoID}).Decode(&updated)
+	// the old image is only removed once nothing references it anymore
+	if update.Image != nil {
+		tc.deleteFiles(ctx, todo.ImageKeys()...)
+	}
+	if update.DueAt != nil {
+		tc.rescheduleReminders(ctx, todoID, updated.DueAt)
+	}
 
-	return c.JSON(updated)
+	return tc.respondTodo(ctx, c, fiber.StatusOK, updated)
 }
 
 // get todo by id
-func GetTodoByID(c *fiber.Ctx) error {
+func (tc *TodoController) GetTodoByID(c *fiber.Ctx) error {
 	idParam := c.Params("id")
 	todoID, err := primitive.ObjectIDFromHex(idParam)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid ID: " + err.Error()})
+		return apperrors.BadRequest("Invalid ID")
 	}
 
-	var todo models.Todo
-	err = todoCollection.FindOne(context.Background(), bson.M{"_id": todoID}).Decode(&todo)
+	todo, err := tc.todos.FindByID(context.Background(), todoID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return c.Status(404).JSON(fiber.Map{"error": "Todo not found: " + err.Error()})
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("Todo not found")
 		}
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch todo: " + err.Error()})
+		return apperrors.Internal(err)
 	}
 
+	tc.present(c.Context(), &todo)
 	return c.JSON(todo)
 }
 
 // get todo by userid
-func GetTodosByUserID(c *fiber.Ctx) error {
+func (tc *TodoController) GetTodosByUserID(c *fiber.Ctx) error {
 	userIDParam := c.Params("userId")
 	userID, err := primitive.ObjectIDFromHex(userIDParam)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID: " + err.Error()})
+		return apperrors.BadRequest("Invalid user ID")
 	}
 
-	// Find all todos for this user
-	cursor, err := todoCollection.Find(context.Background(), bson.M{"userId": userID})
+	query, err := todoQuery(c)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch todos: " + err.Error()})
+		return err
 	}
-	defer cursor.Close(context.Background())
+	query.UserID = &userID
 
-	todos := []models.Todo{}
-	if err := cursor.All(context.Background(), &todos); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse todos: " + err.Error()})
+	// a user's own listing also has the todos shared with them, unless shared=false
+	if self := currentUserID(c); self != nil && *self == userID && c.QueryBool("shared", true) {
+		lists, err := tc.lists.FindSharedWith(context.Background(), userID)
+		if err != nil {
+			return apperrors.Internal(err)
+		}
+		for _, list := range lists {
+			query.SharedLists = append(query.SharedLists, list.ID)
+		}
+		query.UserID, query.VisibleTo = nil, &userID
 	}
 
-	return c.JSON(todos)
+	// Find the matching todos of this user
+	return tc.sendTodos(c, query)
 }
 
 // count all todos
-func CountTodos(c *fiber.Ctx) error {
-	count, err := todoCollection.CountDocuments(context.Background(), bson.M{})
+func (tc *TodoController) CountTodos(c *fiber.Ctx) error {
+	count, err := tc.todos.Count(context.Background())
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count todos: " + err.Error()})
+		return apperrors.Internal(err)
 	}
 
 	return c.JSON(fiber.Map{"count": count})
 }
 
 // count todos by user
-func CountTodosByUserID(c *fiber.Ctx) error {
+func (tc *TodoController) CountTodosByUserID(c *fiber.Ctx) error {
 	userIDParam := c.Params("userId")
 	userID, err := primitive.ObjectIDFromHex(userIDParam)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID format: " + err.Error()})
+		return apperrors.BadRequest("Invalid user ID")
 	}
 
-	// get user
-	var user models.User
-
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	err = userCollection.FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	// get user
+	user, err := tc.users.FindByID(ctx, userID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return c.Status(404).JSON(fiber.Map{"error": "User not found: " + err.Error()})
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("User not found")
 		}
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch user: " + err.Error()})
+		return apperrors.Internal(err)
 	}
 
-	count, err := todoCollection.CountDocuments(context.Background(), bson.M{"userId": userID})
+	count, err := tc.todos.CountByUserID(context.Background(), userID)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to count todos: " + err.Error()})
+		return apperrors.Internal(err)
 	}
 
 	return c.JSON(fiber.Map{"user": user.Username, "count": count})
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+)
+
+// due dates without an offset are read in the todo's timezone
+var dueAtLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// parseDueAt reads an RFC 3339 date-time, or a local date-time or date in
+// the IANA timezone tz (UTC when empty), and returns it in UTC with the
+// millisecond precision MongoDB stores
+func parseDueAt(value, tz string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC().Truncate(time.Millisecond), nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, layout := range dueAtLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, &time.ParseError{Layout: time.RFC3339, Value: value}
+}
+
+// dueAtError is the validation error for an unreadable due date
+func dueAtError() error {
+	return apperrors.Validation([]utils.FieldError{{
+		Field:   "dueAt",
+		Rule:    "datetime",
+		Message: "dueAt must be an RFC 3339 date-time, or a date-time or date in dueTimezone",
+	}})
+}
+
+// present prepares a todo for a response: signed image links, checklist
+// progress and the due and recurrence dates in the todo's timezone
+func (tc *TodoController) present(ctx context.Context, todo *models.Todo) {
+	tc.signImageURLs(ctx, todo)
+	todo.Progress = todo.ItemProgress()
+	if todo.DueTimezone == "" {
+		return
+	}
+	loc, err := time.LoadLocation(todo.DueTimezone)
+	if err != nil {
+		return
+	}
+	if todo.DueAt != nil {
+		dueAt := todo.DueAt.In(loc)
+		todo.DueAt = &dueAt
+	}
+	// copied, so the stored todo is left untouched
+	if todo.Recurrence != nil {
+		rec := *todo.Recurrence
+		rec.Start = rec.Start.In(loc)
+		rec.Exceptions = make([]time.Time, len(todo.Recurrence.Exceptions))
+		for i, t := range todo.Recurrence.Exceptions {
+			rec.Exceptions[i] = t.In(loc)
+		}
+		todo.Recurrence = &rec
+	}
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"strings"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
+	"github.com/clinton-mwachia/go-fiber-api-template/media"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// storedImage is an uploaded image and its variants, as storage keys
+type storedImage struct {
+	Key      string
+	Variants map[string]string
+}
+
+func (img storedImage) keys() []string {
+	return models.Todo{Image: img.Key, ImageVariants: img.Variants}.ImageKeys()
+}
+
+// fileURLExpiry is the lifetime of signed file URLs, FILE_URL_TTL_MIN
+func fileURLExpiry() time.Duration {
+	if config.Cfg.FileURLTTLMin <= 0 {
+		return time.Hour
+	}
+	return time.Duration(config.Cfg.FileURLTTLMin) * time.Minute
+}
+
+// imagePolicy limits todo images to common image types up to MAX_UPLOAD_MB
+func imagePolicy() media.Policy {
+	maxBytes := config.Cfg.MaxUploadBytes
+	if maxBytes <= 0 {
+		maxBytes = 5 << 20
+	}
+	return media.Policy{MaxBytes: maxBytes, Allowed: media.ImageTypes}
+}
+
+// imageVariants are the resized copies configured with IMAGE_VARIANTS
+func imageVariants() []media.Variant {
+	if config.Cfg.ImageVariants == nil {
+		return media.DefaultVariants
+	}
+	return config.Cfg.ImageVariants
+}
+
+// uploadError maps media errors to 413 / 415
+func uploadError(err error, policy media.Policy) error {
+	switch {
+	case errors.Is(err, media.ErrTooLarge):
+		return apperrors.TooLarge(fmt.Sprintf("Image must be at most %d MB", policy.MaxBytes>>20))
+	case errors.Is(err, media.ErrUnsupportedType):
+		return apperrors.UnsupportedMediaType("Image must be a valid JPEG, PNG, GIF or WebP file").Wrap(err)
+	}
+	return apperrors.BadRequest("Invalid image upload").Wrap(err)
+}
+
+// saveImage validates an uploaded image, strips its metadata, renders the
+// variants and stores everything under random keys, the client's filename
+// is never used
+func (tc *TodoController) saveImage(ctx context.Context, file *multipart.FileHeader) (storedImage, error) {
+	src, err := file.Open()
+	if err != nil {
+		return storedImage{}, apperrors.BadRequest("Invalid image upload").Wrap(err)
+	}
+	defer src.Close()
+
+	policy := imagePolicy()
+	img, err := media.Read(src, file.Size, policy)
+	if err != nil {
+		return storedImage{}, uploadError(err, policy)
+	}
+	renditions, err := media.Variants(img, imageVariants())
+	if err != nil {
+		return storedImage{}, uploadError(err, policy)
+	}
+
+	// variants sit next to the original: todos/<id>.png, todos/<id>_thumb.png
+	stored := storedImage{Key: media.RandomKey("todos", img.Ext), Variants: map[string]string{}}
+	if err := tc.files.Put(ctx, stored.Key, bytes.NewReader(img.Data), int64(len(img.Data)), img.ContentType); err != nil {
+		return storedImage{}, apperrors.Internal(err)
+	}
+	for _, r := range renditions {
+		key := strings.TrimSuffix(stored.Key, img.Ext) + "_" + r.Name + r.Ext
+		if err := tc.files.Put(ctx, key, bytes.NewReader(r.Data), int64(len(r.Data)), r.ContentType); err != nil {
+			tc.deleteFiles(ctx, stored.keys()...)
+			return storedImage{}, apperrors.Internal(err)
+		}
+		stored.Variants[r.Name] = key
+	}
+	return stored, nil
+}
+
+// deleteFiles removes stored files, failures only leave orphaned files behind
+func (tc *TodoController) deleteFiles(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := tc.files.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete file %s: %v", key, err)
+		}
+	}
+}
+
+// deleteTodo removes the todo along with its image, attachments, invitations
+// and reminders
+func (tc *TodoController) deleteTodo(ctx context.Context, todo models.Todo) error {
+	if err := tc.todos.Delete(ctx, todo.ID); err != nil {
+		return err
+	}
+	tc.deleteFiles(ctx, todo.ImageKeys()...)
+	tc.deleteAttachments(ctx, todo.ID)
+	if err := tc.invitations.DeleteByResource(ctx, todo.ID); err != nil {
+		log.Printf("Failed to delete invitations to todo %s: %v", todo.ID.Hex(), err)
+	}
+	if err := tc.reminders.DeleteByTodo(ctx, todo.ID); err != nil {
+		log.Printf("Failed to delete reminders of todo %s: %v", todo.ID.Hex(), err)
+	}
+	return nil
+}
+
+// deleteAttachments removes the attachments of a deleted todo,
+// content that can't be deleted is left to the orphan collector
+func (tc *TodoController) deleteAttachments(ctx context.Context, todoID primitive.ObjectID) {
+	attachments, err := tc.attachments.FindByTodoID(ctx, todoID)
+	if err != nil {
+		log.Printf("Failed to list attachments of todo %s: %v", todoID.Hex(), err)
+		return
+	}
+	for _, attachment := range attachments {
+		if err := tc.attachments.Delete(ctx, attachment.ID); err != nil {
+			log.Printf("Failed to delete attachment %s: %v", attachment.ID.Hex(), err)
+			continue
+		}
+		tc.deleteFiles(ctx, attachment.Key)
+	}
+}
+
+// signImageURLs fills ImageURLs with signed links to the original and its variants
+func (tc *TodoController) signImageURLs(ctx context.Context, todo *models.Todo) {
+	if todo.Image == "" {
+		return
+	}
+	expiry := fileURLExpiry()
+	todo.ImageURLs = map[string]string{}
+	keys := map[string]string{"original": todo.Image}
+	for name, key := range todo.ImageVariants {
+		keys[name] = key
+	}
+	for name, key := range keys {
+		url, err := tc.files.SignedURL(ctx, key, expiry)
+		if err != nil {
+			log.Printf("Failed to sign todo image %s: %v", key, err)
+			continue
+		}
+		todo.ImageURLs[name] = url
+	}
+}
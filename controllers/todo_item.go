@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxChecklistItems bounds the checklist, it is stored inside the todo
+const maxChecklistItems = 100
+
+// Add checklist item request body, position is the 0-based index and
+// defaults to the end
+type AddItemInput struct {
+	Title    string `json:"title" validate:"required,max=200"`
+	Position *int   `json:"position" validate:"omitempty,min=0"`
+}
+
+// Update checklist item request body, position moves the item
+type UpdateItemInput struct {
+	Title     *string `json:"title" validate:"omitempty,min=1,max=200"`
+	Completed *bool   `json:"completed"`
+	Position  *int    `json:"position" validate:"omitempty,min=0"`
+}
+
+// Checklist is the list of a todo's items
+type Checklist struct {
+	Items    []models.ChecklistItem `json:"items"`
+	Progress int                    `json:"progress"` // percent of items completed
+}
+
+// itemID reads the itemId param
+func itemID(c *fiber.Ctx) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(c.Params("itemId"))
+	if err != nil {
+		return id, apperrors.BadRequest("Invalid item ID")
+	}
+	return id, nil
+}
+
+// itemError maps a missing item to 404
+func itemError(err error) error {
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Item not found")
+	}
+	return apperrors.Internal(err)
+}
+
+// syncCompletion completes a todo with autoComplete once all of its items
+// are done, and reopens it when an item is added or reopened
+func (tc *TodoController) syncCompletion(ctx context.Context, c *fiber.Ctx, todo models.Todo) (models.Todo, error) {
+	if !todo.AutoComplete || len(todo.Items) == 0 {
+		return todo, nil
+	}
+	done := todo.ItemsDone()
+	if done == todo.Completed {
+		return todo, nil
+	}
+	return tc.todos.Update(ctx, todo.ID, repositories.TodoUpdate{Completed: &done, UpdatedBy: currentUserID(c)})
+}
+
+// respondTodo syncs the todo's completion with its items, schedules the
+// next occurrence of a completed recurring todo and sends it
+func (tc *TodoController) respondTodo(ctx context.Context, c *fiber.Ctx, status int, todo models.Todo) error {
+	todo, err := tc.syncCompletion(ctx, c, todo)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	if todo, err = tc.scheduleNext(ctx, c, todo); err != nil {
+		return apperrors.Internal(err)
+	}
+	tc.present(ctx, &todo)
+	return c.Status(status).JSON(todo)
+}
+
+// add a checklist item to a todo
+func (tc *TodoController) AddItem(c *fiber.Ctx) error {
+	todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid todo ID")
+	}
+
+	var body AddItemInput
+	if err := c.BodyParser(&body); err != nil {
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
+	}
+	if errs := utils.ValidateStruct(body); errs != nil {
+		return apperrors.Validation(errs)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	todo, err := tc.todos.FindByID(ctx, todoID)
+	if err != nil {
+		return apperrors.NotFound("Todo not found")
+	}
+	if len(todo.Items) >= maxChecklistItems {
+		return apperrors.Conflict(fmt.Sprintf("A todo can have at most %d items", maxChecklistItems))
+	}
+
+	position := -1
+	if body.Position != nil {
+		position = *body.Position
+	}
+	item := models.ChecklistItem{Title: body.Title}
+	todo, err = tc.todos.AddItem(ctx, todoID, &item, position, currentUserID(c))
+	if err != nil {
+		return itemError(err)
+	}
+
+	return tc.respondTodo(ctx, c, fiber.StatusCreated, todo)
+}
+
+// list the checklist of a todo
+func (tc *TodoController) GetItems(c *fiber.Ctx) error {
+	todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid todo ID")
+	}
+
+	todo, err := tc.todos.FindByID(context.Background(), todoID)
+	if err != nil {
+		return apperrors.NotFound("Todo not found")
+	}
+
+	checklist := Checklist{Items: todo.Items, Progress: todo.ItemProgress()}
+	if checklist.Items == nil {
+		checklist.Items = []models.ChecklistItem{}
+	}
+	return c.JSON(checklist)
+}
+
+// get a checklist item
+func (tc *TodoController) GetItem(c *fiber.Ctx) error {
+	todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid todo ID")
+	}
+	id, err := itemID(c)
+	if err != nil {
+		return err
+	}
+
+	todo, err := tc.todos.FindByID(context.Background(), todoID)
+	if err != nil {
+		return apperrors.NotFound("Todo not found")
+	}
+	i := slices.IndexFunc(todo.Items, func(item models.ChecklistItem) bool { return item.ID == id })
+	if i < 0 {
+		return apperrors.NotFound("Item not found")
+	}
+	return c.JSON(todo.Items[i])
+}
+
+// rename, complete or move a checklist item
+func (tc *TodoController) UpdateItem(c *fiber.Ctx) error {
+	todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid todo ID")
+	}
+	id, err := itemID(c)
+	if err != nil {
+		return err
+	}
+
+	var body UpdateItemInput
+	if err := c.BodyParser(&body); err != nil {
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
+	}
+	if errs := utils.ValidateStruct(body); errs != nil {
+		return apperrors.Validation(errs)
+	}
+	if body.Title == nil && body.Completed == nil && body.Position == nil {
+		return apperrors.BadRequest("Nothing to update")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	todo, err := tc.todos.UpdateItem(ctx, todoID, id, repositories.ItemUpdate{
+		Title:     body.Title,
+		Completed: body.Completed,
+		Position:  body.Position,
+		UpdatedBy: currentUserID(c),
+	})
+	if err != nil {
+		return itemError(err)
+	}
+
+	return tc.respondTodo(ctx, c, fiber.StatusOK, todo)
+}
+
+// remove a checklist item
+func (tc *TodoController) DeleteItem(c *fiber.Ctx) error {
+	todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid todo ID")
+	}
+	id, err := itemID(c)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	todo, err := tc.todos.DeleteItem(ctx, todoID, id, currentUserID(c))
+	if err != nil {
+		return itemError(err)
+	}
+
+	return tc.respondTodo(ctx, c, fiber.StatusOK, todo)
+}
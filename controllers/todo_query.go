@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxSearchLength bounds the q query param
+const maxSearchLength = 200
+
+// todoQuery reads the filters and sort of a todo listing:
+// list, completed, tags, priority, dueAfter, dueBefore, q and sort
+func todoQuery(c *fiber.Ctx) (repositories.TodoQuery, error) {
+	var query repositories.TodoQuery
+
+	// list=<id> matches a list's todos, list=inbox those without one
+	if value := c.Query("list"); value != "" {
+		var listID primitive.ObjectID
+		if value != "inbox" {
+			id, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				return query, apperrors.BadRequest("Invalid list, expected a list ID or inbox")
+			}
+			listID = id
+		}
+		query.ListID = &listID
+	}
+
+	if value := c.Query("completed"); value != "" {
+		completed, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, apperrors.BadRequest("Invalid completed, expected true or false")
+		}
+		query.Completed = &completed
+	}
+
+	// tags=work,home matches todos with both
+	if value := c.Query("tags"); value != "" {
+		query.Tags = utils.NormalizeTags(strings.Split(value, ","))
+	}
+
+	// priority=2,3 matches either
+	if value := c.Query("priority"); value != "" {
+		for _, p := range strings.Split(value, ",") {
+			priority, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil || priority < models.PriorityNone || priority > models.PriorityHigh {
+				return query, apperrors.BadRequest("Invalid priority, expected values from 0 to 3")
+			}
+			query.Priorities = append(query.Priorities, priority)
+		}
+	}
+
+	var err error
+	if query.DueAfter, err = queryTime(c, "dueAfter"); err != nil {
+		return query, err
+	}
+	if query.DueBefore, err = queryTime(c, "dueBefore"); err != nil {
+		return query, err
+	}
+
+	query.Search = strings.TrimSpace(c.Query("q"))
+	if len([]rune(query.Search)) > maxSearchLength {
+		return query, apperrors.BadRequest("Search must be at most " + strconv.Itoa(maxSearchLength) + " characters")
+	}
+
+	// sort=-priority,dueAt, prefix with - for descending
+	if value := c.Query("sort"); value != "" {
+		seen := map[string]bool{}
+		for _, field := range strings.Split(value, ",") {
+			sort := repositories.SortKey{Field: strings.TrimPrefix(field, "-"), Desc: strings.HasPrefix(field, "-")}
+			if !slices.Contains(repositories.TodoSortFields, sort.Field) {
+				return query, apperrors.BadRequest("Invalid sort field: " + sort.Field)
+			}
+			if seen[sort.Field] {
+				return query, apperrors.BadRequest("Duplicate sort field: " + sort.Field)
+			}
+			seen[sort.Field] = true
+			query.Sort = append(query.Sort, sort)
+		}
+	}
+
+	return query, nil
+}
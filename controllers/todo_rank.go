@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/rank"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Move todo request body: the todo goes right before or after another todo
+// of its list (or inbox), or to the end of listId; an empty listId is the
+// inbox. With both, the other todo must be in listId.
+type MoveTodoInput struct {
+	Before string  `json:"before" validate:"omitempty,mongodb"`
+	After  string  `json:"after" validate:"omitempty,mongodb"`
+	ListID *string `json:"listId" validate:"omitempty,eq=|mongodb"`
+}
+
+// errNoRoom is returned when ties or missing ranks leave no rank between
+// two todos, the scope needs rebalancing
+var errNoRoom = errors.New("no rank between the todos")
+
+// rankBounds returns the ranks a todo placed next to ref goes between,
+// before or after it, or after the scope's last todo for a nil ref
+func (tc *TodoController) rankBounds(ctx context.Context, scope repositories.RankScope, ref *models.Todo, before bool, skip primitive.ObjectID) (lo, hi string, err error) {
+	if ref != nil && ref.Rank == "" {
+		return "", "", errNoRoom
+	}
+	neighbour, err := tc.todos.Neighbour(ctx, scope, ref, ref == nil || before, skip)
+	if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+		return "", "", err
+	}
+	switch {
+	case ref == nil:
+		return neighbour.Rank, "", nil
+	case before:
+		return neighbour.Rank, ref.Rank, nil
+	}
+	return ref.Rank, neighbour.Rank, nil
+}
+
+// rankAt returns the rank placing a todo next to ref, before or after it,
+// or at the end of the scope for a nil ref; skip is the todo being moved.
+// When ties leave no room the scope is rebalanced once.
+func (tc *TodoController) rankAt(ctx context.Context, scope repositories.RankScope, ref *models.Todo, before bool, skip primitive.ObjectID) (string, error) {
+	for rebalanced := false; ; rebalanced = true {
+		lo, hi, err := tc.rankBounds(ctx, scope, ref, before, skip)
+		if err == nil {
+			var key string
+			if key, err = rank.Between(lo, hi); err == nil {
+				return key, nil
+			}
+		}
+		if rebalanced || !(errors.Is(err, errNoRoom) || errors.Is(err, rank.ErrOrder) || errors.Is(err, rank.ErrInvalid)) {
+			return "", err
+		}
+
+		if _, err := tc.todos.Rebalance(ctx, scope); err != nil {
+			return "", err
+		}
+		if ref != nil {
+			reloaded, err := tc.todos.FindByID(ctx, ref.ID)
+			if err != nil {
+				return "", err
+			}
+			ref = &reloaded
+		}
+	}
+}
+
+// moveRef finds the todo another is moved next to, it has to be in scope
+// and visible to the current user
+func (tc *TodoController) moveRef(ctx context.Context, c *fiber.Ctx, field, value string, todo models.Todo, scope repositories.RankScope) (*models.Todo, error) {
+	refID, _ := primitive.ObjectIDFromHex(value)
+	invalid := apperrors.Validation([]utils.FieldError{{
+		Field: field, Rule: "same_list", Message: field + " must be another todo of the same list or inbox",
+	}})
+	if refID == todo.ID {
+		return nil, invalid
+	}
+	ref, err := tc.todos.FindByID(ctx, refID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, apperrors.Internal(err)
+	}
+	if !scope.Contains(ref) {
+		return nil, invalid
+	}
+
+	role, _ := c.Locals("role").(string)
+	if config.HasPermission(role, config.PermTodosReadAll) {
+		return &ref, nil
+	}
+	var list *models.List
+	if ref.ListID != nil {
+		found, err := tc.lists.FindByID(ctx, *ref.ListID)
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			return nil, apperrors.Internal(err)
+		}
+		if err == nil {
+			list = &found
+		}
+	}
+	if userID := currentUserID(c); userID == nil || ref.Access(*userID, list) == models.AccessNone {
+		return nil, invalid
+	}
+	return &ref, nil
+}
+
+// move a todo before or after another one, or to the end of a list
+func (tc *TodoController) MoveTodo(c *fiber.Ctx) error {
+	todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid ID")
+	}
+
+	var body MoveTodoInput
+	if err := c.BodyParser(&body); err != nil {
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
+	}
+	if errs := utils.ValidateStruct(body); errs != nil {
+		return apperrors.Validation(errs)
+	}
+	if body.Before != "" && body.After != "" {
+		return apperrors.Validation([]utils.FieldError{{Field: "after", Rule: "excluded_with", Message: "only one of before and after can be set"}})
+	}
+	if body.Before == "" && body.After == "" && body.ListID == nil {
+		return apperrors.BadRequest("Nothing to move, expected before, after or listId")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	todo, err := tc.todos.FindByID(ctx, todoID)
+	if err != nil {
+		return apperrors.NotFound("Todo not found")
+	}
+
+	update := repositories.TodoUpdate{UpdatedBy: currentUserID(c)}
+	moved := todo
+	if body.ListID != nil {
+		var listID primitive.ObjectID
+		moved.ListID = nil
+		if *body.ListID != "" {
+			listID, _ = primitive.ObjectIDFromHex(*body.ListID)
+			if err := tc.checkList(ctx, c, listID, todo.UserID); err != nil {
+				return err
+			}
+			moved.ListID = &listID
+		}
+		if todo.ListID == nil || *todo.ListID != listID {
+			if err := tc.checkLeaveList(ctx, c, todo); err != nil {
+				return err
+			}
+		}
+		update.ListID = &listID
+	}
+	scope := repositories.ScopeOf(moved)
+
+	var ref *models.Todo
+	switch {
+	case body.Before != "":
+		ref, err = tc.moveRef(ctx, c, "before", body.Before, todo, scope)
+	case body.After != "":
+		ref, err = tc.moveRef(ctx, c, "after", body.After, todo, scope)
+	}
+	if err != nil {
+		return err
+	}
+
+	key, err := tc.rankAt(ctx, scope, ref, body.Before != "", todo.ID)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	update.Rank = &key
+
+	updated, err := tc.todos.Update(ctx, todoID, update)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("Todo not found")
+		}
+		return apperrors.Internal(err)
+	}
+
+	tc.present(ctx, &updated)
+	return c.JSON(updated)
+}
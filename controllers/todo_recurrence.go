@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/recurrence"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// occurrence listing limits
+const (
+	maxOccurrenceRange = 366 * 24 * time.Hour
+	maxOccurrences     = 1000
+)
+
+// maxExceptions caps the skipped occurrences of a todo, as the
+// recurrenceExceptions of create and update do
+const maxExceptions = 100
+
+// Occurrences is the expansion of a recurring todo over a date range
+type Occurrences struct {
+	TodoID      primitive.ObjectID `json:"todoId"`
+	Rule        string             `json:"rule"`
+	Timezone    string             `json:"timezone"`
+	Occurrences []time.Time        `json:"occurrences"` // in the todo's timezone, skipped ones left out
+}
+
+// recurrenceError is the validation error for an unusable recurrence field
+func recurrenceError(field, message string) error {
+	return apperrors.Validation([]utils.FieldError{{Field: field, Rule: "recurrence", Message: message}})
+}
+
+// todoSeries is the todo's recurrence in its due timezone
+func todoSeries(todo models.Todo) (recurrence.Series, error) {
+	if todo.Recurrence == nil {
+		return recurrence.Series{}, errors.New("todo doesn't recur")
+	}
+	rule, err := recurrence.Parse(todo.Recurrence.Rule)
+	if err != nil {
+		return recurrence.Series{}, err
+	}
+	loc, err := time.LoadLocation(todo.DueTimezone)
+	if err != nil {
+		return recurrence.Series{}, err
+	}
+	return recurrence.Series{Rule: rule, Start: todo.Recurrence.Start.In(loc), Exceptions: todo.Recurrence.Exceptions}, nil
+}
+
+// buildRecurrence applies the recurrence fields of a create or update body to
+// current, for a todo due at dueAt in tz. A rule (re)starts the series at
+// dueAt and an empty one clears it. Skipped occurrences are date-times, or
+// dates standing for the occurrence on that day. It returns nil when nothing
+// changes and an empty recurrence to clear it.
+func buildRecurrence(current *models.Recurrence, rule *string, exceptions []string, dueAt *time.Time, tz string) (*models.Recurrence, error) {
+	if rule != nil && *rule == "" {
+		return &models.Recurrence{}, nil
+	}
+	if rule == nil && exceptions == nil {
+		if current != nil && dueAt == nil {
+			return nil, recurrenceError("dueAt", "dueAt is required for a recurring todo")
+		}
+		return nil, nil
+	}
+	if dueAt == nil {
+		return nil, recurrenceError("dueAt", "dueAt is required for a recurring todo")
+	}
+
+	var rec models.Recurrence
+	switch {
+	case rule != nil:
+		parsed, err := recurrence.Parse(*rule)
+		if err != nil {
+			return nil, recurrenceError("recurrence", "recurrence must be an RRULE such as FREQ=WEEKLY;BYDAY=MO: "+err.Error())
+		}
+		rec = models.Recurrence{Rule: parsed.String(), Start: *dueAt}
+		if current != nil {
+			rec.Exceptions, rec.NextID = current.Exceptions, current.NextID
+		}
+	case current != nil:
+		rec = *current
+	default:
+		return nil, recurrenceError("recurrenceExceptions", "recurrenceExceptions need a recurrence")
+	}
+
+	if exceptions != nil {
+		series, err := todoSeries(models.Todo{Recurrence: &rec, DueTimezone: tz})
+		if err != nil {
+			return nil, apperrors.Internal(err)
+		}
+		rec.Exceptions = nil
+		for _, value := range exceptions {
+			skipped, err := parseException(series, value)
+			if err != nil {
+				return nil, err
+			}
+			if !slices.ContainsFunc(rec.Exceptions, skipped.Equal) {
+				rec.Exceptions = append(rec.Exceptions, skipped)
+			}
+		}
+		slices.SortFunc(rec.Exceptions, time.Time.Compare)
+	}
+	return &rec, nil
+}
+
+// parseException reads a skipped occurrence of the series
+func parseException(series recurrence.Series, value string) (time.Time, error) {
+	if day, err := time.Parse(time.DateOnly, value); err == nil {
+		on, ok := series.On(day.Date())
+		if !ok {
+			return time.Time{}, recurrenceError("recurrenceExceptions", "there is no occurrence on "+value)
+		}
+		return on.UTC(), nil
+	}
+	skipped, err := parseDueAt(value, series.Start.Location().String())
+	if err != nil {
+		return time.Time{}, recurrenceError("recurrenceExceptions", "recurrenceExceptions must be dates or date-times like dueAt")
+	}
+	return skipped, nil
+}
+
+// scheduleNext creates the todo of the next occurrence once a recurring todo
+// is completed, at most once per todo. The next todo copies the details and
+// a fresh checklist, but not the image or attachments. Reminders relative
+// to the due date carry over.
+func (tc *TodoController) scheduleNext(ctx context.Context, c *fiber.Ctx, todo models.Todo) (models.Todo, error) {
+	if !todo.Completed || todo.Recurrence == nil || todo.Recurrence.NextID != nil || todo.DueAt == nil {
+		return todo, nil
+	}
+	series, err := todoSeries(todo)
+	if err != nil {
+		return todo, err
+	}
+	dueAt, ok := series.Next(*todo.DueAt)
+	if !ok {
+		return todo, nil
+	}
+	dueAt = dueAt.UTC()
+
+	next := models.Todo{
+		ID:            primitive.NewObjectID(),
+		UserID:        todo.UserID,
+		ListID:        todo.ListID,
+		Title:         todo.Title,
+		Description:   todo.Description,
+		DueAt:         &dueAt,
+		DueTimezone:   todo.DueTimezone,
+		Priority:      todo.Priority,
+		Tags:          todo.Tags,
+		Collaborators: todo.Collaborators,
+		AutoComplete:  todo.AutoComplete,
+		Recurrence:    &models.Recurrence{Rule: todo.Recurrence.Rule, Start: todo.Recurrence.Start, Exceptions: todo.Recurrence.Exceptions},
+		Audit:         models.Audit{CreatedBy: currentUserID(c)},
+	}
+	// the next occurrence takes the place right after this one
+	if next.Rank, err = tc.rankAt(ctx, repositories.ScopeOf(todo), &todo, false, primitive.ObjectID{}); err != nil {
+		return todo, err
+	}
+	for _, item := range todo.Items {
+		next.Items = append(next.Items, models.ChecklistItem{
+			ID: primitive.NewObjectID(), Title: item.Title, CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+		})
+	}
+	if err := tc.todos.Create(ctx, &next); err != nil {
+		return todo, err
+	}
+
+	// someone else completed it at the same time, keep theirs
+	if err := tc.todos.LinkNext(ctx, todo.ID, next.ID); err != nil {
+		if derr := tc.todos.Delete(ctx, next.ID); derr != nil {
+			log.Printf("failed to delete duplicate occurrence %s: %v", next.ID.Hex(), derr)
+		}
+		if !errors.Is(err, repositories.ErrNotFound) {
+			return todo, err
+		}
+	} else {
+		tc.copyReminders(ctx, todo.ID, next)
+	}
+	return tc.todos.FindByID(ctx, todo.ID)
+}
+
+// skip the todo's current occurrence, moving it to the next one
+func (tc *TodoController) SkipOccurrence(c *fiber.Ctx) error {
+	todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid ID")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	todo, err := tc.todos.FindByID(ctx, todoID)
+	if err != nil {
+		return apperrors.NotFound("Todo not found")
+	}
+	if todo.Recurrence == nil || todo.DueAt == nil {
+		return apperrors.BadRequest("The todo doesn't recur")
+	}
+	if todo.Completed {
+		return apperrors.Conflict("A completed occurrence can't be skipped")
+	}
+	series, err := todoSeries(todo)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	dueAt, ok := series.Next(*todo.DueAt)
+	if !ok {
+		return apperrors.Conflict("The series has no more occurrences")
+	}
+	dueAt = dueAt.UTC()
+
+	rec := *todo.Recurrence
+	rec.Exceptions = append(slices.Clone(rec.Exceptions), *todo.DueAt)
+	// past the cap the oldest skips go, those before the new due date only
+	// matter when listing past occurrences
+	slices.SortFunc(rec.Exceptions, time.Time.Compare)
+	for len(rec.Exceptions) > maxExceptions && rec.Exceptions[0].Before(dueAt) {
+		rec.Exceptions = rec.Exceptions[1:]
+	}
+	updated, err := tc.todos.Update(ctx, todoID, repositories.TodoUpdate{
+		DueAt: &dueAt, Recurrence: &rec, UpdatedBy: currentUserID(c),
+	})
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	tc.rescheduleReminders(ctx, todoID, updated.DueAt)
+
+	tc.present(ctx, &updated)
+	return c.JSON(updated)
+}
+
+// list the occurrences of a recurring todo between from and to
+func (tc *TodoController) GetOccurrences(c *fiber.Ctx) error {
+	todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid ID")
+	}
+
+	todo, err := tc.todos.FindByID(context.Background(), todoID)
+	if err != nil {
+		return apperrors.NotFound("Todo not found")
+	}
+	if todo.Recurrence == nil {
+		return apperrors.BadRequest("The todo doesn't recur")
+	}
+	series, err := todoSeries(todo)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	// the range defaults to a month from the current occurrence
+	from, to := todo.Recurrence.Start, time.Time{}
+	if todo.DueAt != nil {
+		from = *todo.DueAt
+	}
+	tz := series.Start.Location().String()
+	if value := c.Query("from"); value != "" {
+		if from, err = parseDueAt(value, tz); err != nil {
+			return apperrors.BadRequest("Invalid from, expected an RFC 3339 date-time or a date")
+		}
+	}
+	to = from.AddDate(0, 1, 0)
+	if value := c.Query("to"); value != "" {
+		if to, err = parseDueAt(value, tz); err != nil {
+			return apperrors.BadRequest("Invalid to, expected an RFC 3339 date-time or a date")
+		}
+	}
+	if !to.After(from) || to.Sub(from) > maxOccurrenceRange {
+		return apperrors.BadRequest("to must be after from, at most 366 days later")
+	}
+
+	occurrences := series.Between(from, to, maxOccurrences)
+	return c.JSON(Occurrences{TodoID: todo.ID, Rule: todo.Recurrence.Rule, Timezone: tz, Occurrences: occurrences})
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxReminders bounds the reminders a user sets on one todo
+const maxReminders = 10
+
+// Add reminder request body, either an absolute time (read like dueAt) or
+// minutes before the todo is due
+type AddReminderInput struct {
+	At            *string `json:"at"`
+	MinutesBefore *int    `json:"minutesBefore" validate:"omitempty,min=0,max=525600"`
+	Channel       string  `json:"channel" validate:"required,oneof=email webhook"`
+}
+
+// reminderError is the validation error for an unusable reminder field
+func reminderError(field, rule, message string) error {
+	return apperrors.Validation([]utils.FieldError{{Field: field, Rule: rule, Message: message}})
+}
+
+// channelEnabled tells whether the server is set up to send on the channel
+func channelEnabled(channel string) bool {
+	switch channel {
+	case models.ChannelEmail:
+		return config.Cfg.SMTPHost != ""
+	case models.ChannelWebhook:
+		return config.Cfg.ReminderWebhookURL != ""
+	}
+	return false
+}
+
+// rescheduleReminders moves the todo's offset reminders to its due date,
+// a failure only delays them so it is logged
+func (tc *TodoController) rescheduleReminders(ctx context.Context, todoID primitive.ObjectID, dueAt *time.Time) {
+	if err := tc.reminders.Reschedule(ctx, todoID, dueAt); err != nil {
+		log.Printf("Failed to reschedule reminders of todo %s: %v", todoID.Hex(), err)
+	}
+}
+
+// copyReminders sets the offset reminders of a recurring todo on its next
+// occurrence, the ones that would already be due are dropped
+func (tc *TodoController) copyReminders(ctx context.Context, from primitive.ObjectID, next models.Todo) {
+	reminders, err := tc.reminders.FindByTodo(ctx, from, primitive.ObjectID{})
+	if err != nil {
+		log.Printf("Failed to copy reminders of todo %s: %v", from.Hex(), err)
+		return
+	}
+	for _, reminder := range reminders {
+		if reminder.MinutesBefore == nil {
+			continue
+		}
+		fireAt := reminder.FireTime(next.DueAt)
+		if fireAt == nil || !fireAt.After(time.Now()) {
+			continue
+		}
+		copied := models.Reminder{
+			TodoID: next.ID, UserID: reminder.UserID, Channel: reminder.Channel,
+			MinutesBefore: reminder.MinutesBefore, FireAt: fireAt,
+		}
+		if err := tc.reminders.Create(ctx, &copied); err != nil {
+			log.Printf("Failed to copy reminder %s: %v", reminder.ID.Hex(), err)
+		}
+	}
+}
+
+// set a reminder on a todo for the current user
+func (tc *TodoController) AddReminder(c *fiber.Ctx) error {
+	todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid todo ID")
+	}
+	userID := currentUserID(c)
+	if userID == nil {
+		return apperrors.Unauthorized("Missing user")
+	}
+
+	var body AddReminderInput
+	if err := c.BodyParser(&body); err != nil {
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
+	}
+	if errs := utils.ValidateStruct(body); errs != nil {
+		return apperrors.Validation(errs)
+	}
+	if (body.At == nil) == (body.MinutesBefore == nil) {
+		return reminderError("at", "required_without", "exactly one of at and minutesBefore is required")
+	}
+	if !channelEnabled(body.Channel) {
+		return reminderError("channel", "enabled", body.Channel+" reminders are not enabled on this server")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	todo, err := tc.todos.FindByID(ctx, todoID)
+	if err != nil {
+		return apperrors.NotFound("Todo not found")
+	}
+
+	reminder := models.Reminder{TodoID: todoID, UserID: *userID, Channel: body.Channel}
+	if body.At != nil {
+		at, err := parseDueAt(*body.At, todo.DueTimezone)
+		if err != nil {
+			return reminderError("at", "datetime", "at must be an RFC 3339 date-time, or a date-time or date in the todo's dueTimezone")
+		}
+		if !at.After(time.Now()) {
+			return reminderError("at", "future", "at must be in the future")
+		}
+		reminder.At = &at
+	} else {
+		if todo.DueAt == nil {
+			return reminderError("minutesBefore", "due", "minutesBefore needs a todo with a dueAt")
+		}
+		reminder.MinutesBefore = body.MinutesBefore
+	}
+	reminder.FireAt = reminder.FireTime(todo.DueAt)
+
+	existing, err := tc.reminders.FindByTodo(ctx, todoID, *userID)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	if len(existing) >= maxReminders {
+		return apperrors.Conflict(fmt.Sprintf("A todo can have at most %d reminders per user", maxReminders))
+	}
+
+	if err := tc.reminders.Create(ctx, &reminder); err != nil {
+		return apperrors.Internal(err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(reminder)
+}
+
+// list the current user's reminders on a todo
+func (tc *TodoController) GetReminders(c *fiber.Ctx) error {
+	todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid todo ID")
+	}
+	userID := currentUserID(c)
+	if userID == nil {
+		return apperrors.Unauthorized("Missing user")
+	}
+
+	reminders, err := tc.reminders.FindByTodo(context.Background(), todoID, *userID)
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	return c.JSON(reminders)
+}
+
+// delete one of the current user's reminders
+func (tc *TodoController) DeleteReminder(c *fiber.Ctx) error {
+	todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid todo ID")
+	}
+	id, err := primitive.ObjectIDFromHex(c.Params("reminderId"))
+	if err != nil {
+		return apperrors.BadRequest("Invalid reminder ID")
+	}
+	userID := currentUserID(c)
+	if userID == nil {
+		return apperrors.Unauthorized("Missing user")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// someone else's reminder is not found, as if it didn't exist
+	reminder, err := tc.reminders.FindByID(ctx, id)
+	if err == nil && (reminder.TodoID != todoID || reminder.UserID != *userID) {
+		err = repositories.ErrNotFound
+	}
+	if err == nil {
+		err = tc.reminders.Delete(ctx, id)
+	}
+	if errors.Is(err, repositories.ErrNotFound) {
+		return apperrors.NotFound("Reminder not found")
+	}
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+	return c.JSON(fiber.Map{"message": "Reminder deleted successfully"})
+}
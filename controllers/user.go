@@ -2,282 +2,311 @@ package controllers
 
 import (
 	"context"
-	"os"
+	"errors"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
 	"github.com/clinton-mwachia/go-fiber-api-template/config"
 	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
 	"github.com/clinton-mwachia/go-fiber-api-template/utils"
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/v2/mongo"
-	"go.mongodb.org/mongo-driver/v2/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// define user collection
-var userCollection *mongo.Collection
+// Register request body
+type RegisterInput struct {
+	Username string `json:"username" validate:"required,min=3,max=50"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+// Update user request body, nil fields are left untouched
+type UpdateUserInput struct {
+	Username *string `json:"username" validate:"omitempty,min=3,max=50"`
+	Email    *string `json:"email" validate:"omitempty,email,max=254"`
+	Role     *string `json:"role" validate:"omitempty,role"`
+}
+
+// Change password request body
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,password"`
+}
 
-// Login request body
-type LoginInput struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+// Reset password request body
+type ResetPasswordInput struct {
+	NewPassword string `json:"newPassword" validate:"required,password"`
 }
 
-// Login response with expiry
-type LoginResponse struct {
-	Token     string `json:"token"`
-	ExpiresAt int64  `json:"expires_at"` // UNIX timestamp
+// UserController handles the user routes
+type UserController struct {
+	users    repositories.UserRepository
+	sessions repositories.SessionRepository
 }
 
-// Init sets up the collections after DB connection
-func InitUserCollection() {
-	userCollection = config.GetCollection("users")
+// NewUserController creates a UserController using the given repositories,
+// a user's sessions are revoked when their password changes or they are deleted
+func NewUserController(users repositories.UserRepository, sessions repositories.SessionRepository) *UserController {
+	return &UserController{users: users, sessions: sessions}
 }
 
 // register a new user
-func Register(c *fiber.Ctx) error {
-	var body models.User
+func (uc *UserController) Register(c *fiber.Ctx) error {
+	var body RegisterInput
 	if err := c.BodyParser(&body); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request: " + err.Error()})
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
+	}
+	if errs := utils.ValidateStruct(body); errs != nil {
+		return apperrors.Validation(errs)
 	}
 
 	// Hash password
 	hashed, _ := utils.HashPassword(body.Password)
-	body.Password = hashed
-	if body.Role == "" {
-		body.Role = "user"
+	id := primitive.NewObjectID()
+	user := models.User{
+		// set ID manually
+		ID:       id,
+		Username: body.Username,
+		Email:    body.Email,
+		Password: hashed,
+		// public registration never grants elevated roles,
+		// admins promote users through UpdateUser
+		Role: config.RoleUser,
+		// self registration, the user is its own creator
+		Audit: models.Audit{CreatedBy: &id},
 	}
-	body.ID = primitive.NewObjectID()
-	// set ID manually
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	_, err := userCollection.InsertOne(ctx, body)
+	err := uc.users.Create(ctx, &user)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to register user: " + err.Error()})
+		var dup *repositories.DuplicateError
+		if errors.As(err, &dup) {
+			return apperrors.Duplicate(dup.Field)
+		}
+		return apperrors.Internal(err)
 	}
 
 	return c.Status(201).JSON(fiber.Map{"message": "User registered successfully"})
 }
 
-// get all users
-func GetAllUsers(c *fiber.Ctx) error {
-	cursor, err := userCollection.Find(context.Background(), bson.M{})
+// get all users, oldest first by default
+func (uc *UserController) GetAllUsers(c *fiber.Ctx) error {
+	return uc.listUsers(c, "createdAt")
+}
+
+// get all users, newest first by default
+func (uc *UserController) GetPaginatedUsers(c *fiber.Ctx) error {
+	return uc.listUsers(c, "-createdAt")
+}
+
+// listUsers sends a page of users, see pageRequest
+func (uc *UserController) listUsers(c *fiber.Ctx, defaultSort string) error {
+	req, err := pageRequest(c)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch users: " + err.Error()})
+		return err
 	}
-	defer cursor.Close(context.Background())
 
-	users := []models.User{}
-	if err := cursor.All(context.Background(), &users); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse users"})
+	// sort=createdAt ascending, sort=-createdAt descending
+	var query repositories.UserQuery
+	sort := c.Query("sort", defaultSort)
+	query.SortBy = strings.TrimPrefix(sort, "-")
+	query.Desc = strings.HasPrefix(sort, "-")
+	if !slices.Contains(repositories.UserSortFields, query.SortBy) {
+		return apperrors.BadRequest("Invalid sort field: " + query.SortBy)
 	}
 
-	return c.JSON(users)
-}
-
-// get all users with pagination
-func GetPaginatedUsers(c *fiber.Ctx) error {
-	// pagination params
-	page := c.QueryInt("page", 1)
-	limit := c.QueryInt("limit", 20)
-
-	if page < 1 {
-		page = 1
+	// createdAt range filters, RFC 3339 timestamps
+	if query.CreatedAfter, err = queryTime(c, "createdAfter"); err != nil {
+		return err
 	}
-	if limit < 1 {
-		limit = 20
+	if query.CreatedBefore, err = queryTime(c, "createdBefore"); err != nil {
+		return err
 	}
 
-	skip := (page - 1) * limit
-
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	opts := options.Find().
-		SetSkip(int64(skip)).
-		SetLimit(int64(limit)).
-		SetSort(bson.M{"created_at": -1}) // newest first
-
-	cursor, err := userCollection.Find(ctx, bson.M{}, opts)
+	page, err := uc.users.FindPage(ctx, query, req)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch users: " + err.Error()})
+		return pageError(err)
 	}
-	defer cursor.Close(ctx)
 
-	users := []models.User{}
-	if err := cursor.All(ctx, &users); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to parse users: " + err.Error()})
-	}
-
-	return c.JSON(fiber.Map{
-		"page":  page,
-		"limit": limit,
-		"data":  users,
-	})
+	return sendPage(c, req, page)
 }
 
 // get user by id
-func GetUserByID(c *fiber.Ctx) error {
+func (uc *UserController) GetUserByID(c *fiber.Ctx) error {
 	idParam := c.Params("id")
 
 	// Validate ObjectID
 	objID, err := primitive.ObjectIDFromHex(idParam)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID: " + err.Error()})
+		return apperrors.BadRequest("Invalid user ID")
 	}
 
-	var user models.User
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	err = userCollection.FindOne(ctx, bson.M{"_id": objID}).Decode(&user)
+	user, err := uc.users.FindByID(ctx, objID)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("User not found")
 		}
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch user"})
+		return apperrors.Internal(err)
 	}
 
 	return c.JSON(user)
 }
 
 // update user by id
-func UpdateUser(c *fiber.Ctx) error {
+func (uc *UserController) UpdateUser(c *fiber.Ctx) error {
 	idParam := c.Params("id")
 
 	// Validate ObjectID
 	objID, err := primitive.ObjectIDFromHex(idParam)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID: " + err.Error()})
-	}
-
-	var body struct {
-		Username *string `json:"username"`
-		Email    *string `json:"email"`
-		Role     *string `json:"role"`
+		return apperrors.BadRequest("Invalid user ID")
 	}
 
+	var body UpdateUserInput
 	if err := c.BodyParser(&body); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body: " + err.Error()})
-	}
-
-	update := bson.M{}
-	if body.Username != nil {
-		update["username"] = body.Username
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
 	}
-	if body.Email != nil {
-		update["email"] = *body.Email
+	if errs := utils.ValidateStruct(body); errs != nil {
+		return apperrors.Validation(errs)
 	}
+
 	if body.Role != nil {
-		update["role"] = *body.Role
+		// only users allowed to manage other users can change roles
+		role, _ := c.Locals("role").(string)
+		if !config.HasPermission(role, config.PermUsersWrite) {
+			return apperrors.Forbidden("You are not allowed to change roles")
+		}
 	}
 
-	if len(update) == 0 {
-		return c.Status(400).JSON(fiber.Map{"error": "No fields to update"})
+	if body.Username == nil && body.Email == nil && body.Role == nil {
+		return apperrors.BadRequest("No fields to update")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	result, err := userCollection.UpdateOne(
-		ctx,
-		bson.M{"_id": objID},
-		bson.M{"$set": update},
-	)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to update user: " + err.Error()})
+	var previousRole string
+	if body.Role != nil {
+		user, err := uc.users.FindByID(ctx, objID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				return apperrors.NotFound("User not found")
+			}
+			return apperrors.Internal(err)
+		}
+		previousRole = user.Role
 	}
-	if result.MatchedCount == 0 {
-		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+
+	updatedUser, err := uc.users.Update(ctx, objID, repositories.UserUpdate{
+		Username:  body.Username,
+		Email:     body.Email,
+		Role:      body.Role,
+		UpdatedBy: currentUserID(c),
+	})
+	if err != nil {
+		var dup *repositories.DuplicateError
+		if errors.As(err, &dup) {
+			return apperrors.Duplicate(dup.Field)
+		}
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("User not found")
+		}
+		return apperrors.Internal(err)
 	}
 
-	// Fetch updated user
-	var updatedUser models.User
-	if err := userCollection.FindOne(ctx, bson.M{"_id": objID}).Decode(&updatedUser); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch updated user: " + err.Error()})
+	// tokens carry the role, sessions are revoked so the new one applies right away
+	if body.Role != nil && updatedUser.Role != previousRole {
+		if _, err := uc.sessions.RevokeAllForUser(ctx, objID); err != nil {
+			return apperrors.Internal(err)
+		}
 	}
 
 	return c.JSON(updatedUser)
 }
 
 // delete user by id
-func DeleteUser(c *fiber.Ctx) error {
+func (uc *UserController) DeleteUser(c *fiber.Ctx) error {
 	idParam := c.Params("id")
 
 	// Validate ObjectID
 	objID, err := primitive.ObjectIDFromHex(idParam)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID: " + err.Error()})
+		return apperrors.BadRequest("Invalid user ID")
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	result, err := userCollection.DeleteOne(ctx, bson.M{"_id": objID})
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete user: " + err.Error()})
+	if err := uc.users.Delete(ctx, objID); err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("User not found")
+		}
+		return apperrors.Internal(err)
 	}
-
-	if result.DeletedCount == 0 {
-		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	if _, err := uc.sessions.RevokeAllForUser(ctx, objID); err != nil {
+		return apperrors.Internal(err)
 	}
 
 	return c.JSON(fiber.Map{"message": "User deleted successfully"})
 }
 
 // change password
-func ChangePassword(c *fiber.Ctx) error {
+func (uc *UserController) ChangePassword(c *fiber.Ctx) error {
 	idParam := c.Params("id")
 
 	// Validate ObjectID
 	objID, err := primitive.ObjectIDFromHex(idParam)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID: " + err.Error()})
+		return apperrors.BadRequest("Invalid user ID")
 	}
 
-	var body struct {
-		CurrentPassword string `json:"current_password"`
-		NewPassword     string `json:"new_password"`
-	}
+	var body ChangePasswordInput
 	if err := c.BodyParser(&body); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
 	}
-
-	if body.CurrentPassword == "" || body.NewPassword == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "Both current and new password are required"})
+	if errs := utils.ValidateStruct(body); errs != nil {
+		return apperrors.Validation(errs)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	// Fetch user
-	var user models.User
-	if err := userCollection.FindOne(ctx, bson.M{"_id": objID}).Decode(&user); err != nil {
-		if err == mongo.ErrNoDocuments {
-			return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	user, err := uc.users.FindByID(ctx, objID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("User not found")
 		}
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch user"})
+		return apperrors.Internal(err)
 	}
 
 	// Verify current password
 	if !utils.CheckPassword(user.Password, body.CurrentPassword) {
-		return c.Status(400).JSON(fiber.Map{"error": "Current password is incorrect"})
+		return apperrors.BadRequest("Current password is incorrect")
 	}
 
 	// Hash new password
 	hashed, _ := utils.HashPassword(body.NewPassword)
 
 	// Update in DB
-	_, err = userCollection.UpdateOne(
-		ctx,
-		bson.M{"_id": objID},
-		bson.M{"$set": bson.M{"password": hashed}},
-	)
+	_, err = uc.users.Update(ctx, objID, repositories.UserUpdate{Password: &hashed, UpdatedBy: currentUserID(c)})
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to update password: " + err.Error()})
+		return apperrors.Internal(err)
+	}
+
+	// sign out every device, the old password may have leaked
+	if _, err := uc.sessions.RevokeAllForUser(ctx, objID); err != nil {
+		return apperrors.Internal(err)
 	}
 
 	return c.JSON(fiber.Map{"message": "Password updated successfully"})
@@ -285,81 +314,39 @@ func ChangePassword(c *fiber.Ctx) error {
 
 // reset user password
 // ONLY ADMIN CAN DO THIS
-func ResetPassword(c *fiber.Ctx) error {
-	type ResetInput struct {
-		NewPassword string `json:"newPassword"`
-	}
-
-	var input ResetInput
+func (uc *UserController) ResetPassword(c *fiber.Ctx) error {
+	var input ResetPasswordInput
 	if err := c.BodyParser(&input); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request: " + err.Error()})
+		return apperrors.BadRequest("Invalid request body").Wrap(err)
+	}
+	if errs := utils.ValidateStruct(input); errs != nil {
+		return apperrors.Validation(errs)
 	}
 
 	userId := c.Params("id")
 	objID, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid user ID: " + err.Error()})
+		return apperrors.BadRequest("Invalid user ID")
 	}
 
 	// Hash the new password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to hash password"})
+		return apperrors.Internal(err)
 	}
 
 	// Update the user’s password
-	update := bson.M{"$set": bson.M{"password": string(hashedPassword)}}
-	result, err := userCollection.UpdateOne(context.Background(), bson.M{"_id": objID}, update)
+	hashed := string(hashedPassword)
+	_, err = uc.users.Update(context.Background(), objID, repositories.UserUpdate{Password: &hashed, UpdatedBy: currentUserID(c)})
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to reset password: " + err.Error()})
+		if errors.Is(err, repositories.ErrNotFound) {
+			return apperrors.NotFound("User not found")
+		}
+		return apperrors.Internal(err)
 	}
-
-	if result.MatchedCount == 0 {
-		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	if _, err := uc.sessions.RevokeAllForUser(context.Background(), objID); err != nil {
+		return apperrors.Internal(err)
 	}
 
 	return c.JSON(fiber.Map{"message": "Password reset successfully"})
 }
-
-// Login user
-func Login(c *fiber.Ctx) error {
-	jwtSecret := []byte(os.Getenv("JWT_SECRET"))
-	var input LoginInput
-	if err := c.BodyParser(&input); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request: " + err.Error()})
-	}
-
-	// Find user by email
-	var user models.User
-	err := userCollection.FindOne(context.Background(), bson.M{"email": input.Email}).Decode(&user)
-	if err == mongo.ErrNoDocuments {
-		return c.Status(404).JSON(fiber.Map{"error": "User not found: " + err.Error()})
-	} else if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Something went wrong: " + err.Error()})
-	}
-
-	// Check password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Invalid email or password: " + err.Error()})
-	}
-
-	// Expiry time
-	expirationTime := time.Now().Add(time.Hour * 72).Unix() // 72 hours from now
-	// Create JWT token
-	claims := jwt.MapClaims{
-		"user_id": user.ID.Hex(),
-		"role":    user.Role,
-		"exp":     expirationTime,
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(jwtSecret)
-
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate token"})
-	}
-
-	return c.JSON(LoginResponse{
-		Token:     signedToken,
-		ExpiresAt: expirationTime,
-	})
-}
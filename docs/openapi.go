@@ -0,0 +1,416 @@
+package docs
+
+import (
+	_ "embed"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//go:embed swagger.html
+var swaggerHTML []byte
+
+// Param documents a query parameter
+type Param struct {
+	Name        string
+	Type        string // openapi primitive type, e.g. "integer"
+	Description string
+}
+
+// Operation documents a single route
+type Operation struct {
+	Summary string
+	Tag     string
+	// Public routes don't require a bearer token
+	Public bool
+	// Body is a value of the JSON request body type
+	Body any
+	// Form is a value of the multipart form type, Files lists its file parts
+	Form  any
+	Files []string
+	Query []Param
+	// Response is a value of the success response type, nil means no body
+	Response any
+	// Status is the success status, defaults to 200
+	Status int
+}
+
+// pathParam matches named params and the greedy + wildcard, which is documented as {path}
+var pathParam = regexp.MustCompile(`:(\w+)|/(\+)$`)
+
+// OpenAPIPath converts a fiber route path (/todo/:id, /files/+) to an
+// openapi path (/todo/{id}, /files/{path})
+func OpenAPIPath(path string) string {
+	return pathParam.ReplaceAllStringFunc(path, func(m string) string {
+		if m == "/+" {
+			return "/{path}"
+		}
+		return "{" + m[1:] + "}"
+	})
+}
+
+// paramName is the openapi name of a pathParam match
+func paramName(m []string) string {
+	if m[2] != "" {
+		return "path"
+	}
+	return m[1]
+}
+
+// Key identifies an operation by method and fiber path
+func Key(method, path string) string {
+	return method + " " + path
+}
+
+// Routes returns the documentable routes registered on the app under /api
+func Routes(app *fiber.App) []fiber.Route {
+	routes := []fiber.Route{}
+	seen := map[string]bool{}
+	for _, r := range app.GetRoutes(true) {
+		if r.Method == fiber.MethodHead || !strings.HasPrefix(r.Path, "/api/") {
+			continue
+		}
+		key := Key(r.Method, r.Path)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		routes = append(routes, r)
+	}
+	return routes
+}
+
+// Build generates the openapi document from the routes registered on the app
+func Build(app *fiber.App) fiber.Map {
+	g := &generator{schemas: fiber.Map{}}
+	// RFC 7807 problem rendered by middlewares.ErrorHandler
+	g.schemas["Problem"] = fiber.Map{
+		"type": "object",
+		"properties": fiber.Map{
+			"type":       fiber.Map{"type": "string"},
+			"title":      fiber.Map{"type": "string"},
+			"status":     fiber.Map{"type": "integer"},
+			"detail":     fiber.Map{"type": "string"},
+			"instance":   fiber.Map{"type": "string"},
+			"code":       fiber.Map{"type": "string"},
+			"request_id": fiber.Map{"type": "string"},
+			"errors":     g.schema(reflect.TypeOf([]utils.FieldError{})),
+		},
+		"required": []string{"type", "title", "status", "code"},
+	}
+
+	paths := fiber.Map{}
+	for _, r := range Routes(app) {
+		op, ok := Operations[Key(r.Method, r.Path)]
+		if !ok {
+			continue
+		}
+		path := OpenAPIPath(r.Path)
+		item, _ := paths[path].(fiber.Map)
+		if item == nil {
+			item = fiber.Map{}
+			paths[path] = item
+		}
+		item[strings.ToLower(r.Method)] = g.operation(r, op)
+	}
+
+	return fiber.Map{
+		"openapi": "3.1.0",
+		"info": fiber.Map{
+			"title":   "Fiber + MongoDB API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": fiber.Map{
+			"schemas": g.schemas,
+			"securitySchemes": fiber.Map{
+				"bearerAuth": fiber.Map{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+// SpecHandler serves the openapi document, built on first request
+// so every route is registered by then
+func SpecHandler(app *fiber.App) fiber.Handler {
+	var (
+		once sync.Once
+		spec fiber.Map
+	)
+	return func(c *fiber.Ctx) error {
+		once.Do(func() { spec = Build(app) })
+		return c.JSON(spec)
+	}
+}
+
+// UIHandler serves swagger ui pointed at the openapi document
+func UIHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.Send(swaggerHTML)
+	}
+}
+
+type generator struct {
+	schemas fiber.Map
+}
+
+func (g *generator) operation(r fiber.Route, op Operation) fiber.Map {
+	out := fiber.Map{
+		"summary":     op.Summary,
+		"operationId": operationID(r),
+	}
+	if op.Tag != "" {
+		out["tags"] = []string{op.Tag}
+	}
+	if !op.Public {
+		out["security"] = []fiber.Map{{"bearerAuth": []string{}}}
+	}
+
+	params := []fiber.Map{}
+	for _, m := range pathParam.FindAllStringSubmatch(r.Path, -1) {
+		params = append(params, fiber.Map{
+			"name": paramName(m), "in": "path", "required": true,
+			"schema": fiber.Map{"type": "string"},
+		})
+	}
+	for _, q := range op.Query {
+		params = append(params, fiber.Map{
+			"name": q.Name, "in": "query", "description": q.Description,
+			"schema": fiber.Map{"type": q.Type},
+		})
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+
+	content := fiber.Map{}
+	if op.Body != nil {
+		content[fiber.MIMEApplicationJSON] = fiber.Map{"schema": g.schema(reflect.TypeOf(op.Body))}
+	}
+	if op.Form != nil {
+		schema := g.inline(reflect.TypeOf(op.Form))
+		props := schema["properties"].(fiber.Map)
+		for _, f := range op.Files {
+			props[f] = fiber.Map{"type": "string", "format": "binary"}
+		}
+		content[fiber.MIMEMultipartForm] = fiber.Map{"schema": schema}
+	}
+	if len(content) > 0 {
+		out["requestBody"] = fiber.Map{"required": true, "content": content}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = fiber.StatusOK
+	}
+	success := fiber.Map{"description": "Success"}
+	if op.Response != nil {
+		success["content"] = fiber.Map{
+			fiber.MIMEApplicationJSON: fiber.Map{"schema": g.schema(reflect.TypeOf(op.Response))},
+		}
+	}
+	out["responses"] = fiber.Map{
+		strconv.Itoa(status): success,
+		"default": fiber.Map{
+			"description": "Error",
+			"content": fiber.Map{
+				"application/problem+json": fiber.Map{"schema": fiber.Map{"$ref": "#/components/schemas/Problem"}},
+			},
+		},
+	}
+	return out
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+// schema returns the json schema of t, named structs become component refs
+func (g *generator) schema(t reflect.Type) fiber.Map {
+	switch t {
+	case timeType:
+		return fiber.Map{"type": "string", "format": "date-time"}
+	case objectIDType:
+		return fiber.Map{"type": "string", "pattern": "^[0-9a-f]{24}$"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.String:
+		return fiber.Map{"type": "string"}
+	case reflect.Bool:
+		return fiber.Map{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fiber.Map{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return fiber.Map{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return fiber.Map{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map, reflect.Interface:
+		return fiber.Map{"type": "object"}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.inline(t)
+		}
+		name := schemaName(t)
+		if _, ok := g.schemas[name]; !ok {
+			// reserve the name first so recursive types terminate
+			g.schemas[name] = fiber.Map{}
+			g.schemas[name] = g.inline(t)
+		}
+		return fiber.Map{"$ref": "#/components/schemas/" + name}
+	}
+	return fiber.Map{}
+}
+
+// schemaName is the component name of a named struct, instances of generic
+// types are named after their arguments: ListResponse[models.Todo] is ListResponseTodo
+func schemaName(t reflect.Type) string {
+	base, args, ok := strings.Cut(t.Name(), "[")
+	if !ok {
+		return base
+	}
+	for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+		base += arg[strings.LastIndex(arg, ".")+1:]
+	}
+	return base
+}
+
+// inline returns the object schema of struct t
+func (g *generator) inline(t reflect.Type) fiber.Map {
+	props := fiber.Map{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			embedded := g.inline(f.Type)
+			for k, v := range embedded["properties"].(fiber.Map) {
+				props[k] = v
+			}
+			if req, ok := embedded["required"].([]string); ok {
+				required = append(required, req...)
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		rules := strings.Split(f.Tag.Get("validate"), ",")
+		if slices.Contains(rules, "required") ||
+			(f.Type.Kind() != reflect.Pointer && !strings.Contains(opts, "omitempty") && !slices.Contains(rules, "omitempty")) {
+			required = append(required, name)
+		}
+		applyRules(props[name].(fiber.Map), f.Type, rules)
+	}
+
+	out := fiber.Map{"type": "object", "properties": props}
+	if len(required) > 0 {
+		sort.Strings(required)
+		out["required"] = required
+	}
+	return out
+}
+
+// applyRules copies the validate tag constraints onto the schema of a
+// property of type t, rules after dive apply to the items of a slice
+func applyRules(schema fiber.Map, t reflect.Type, rules []string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for i, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			if items, ok := schema["items"].(fiber.Map); ok && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+				applyRules(items, t.Elem(), rules[i+1:])
+			}
+			return
+		case "email":
+			schema["format"] = "email"
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			// min and max bound the value of numbers, the length of strings and slices
+			suffix := "Length"
+			switch t.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+				reflect.Float32, reflect.Float64:
+				suffix = "imum"
+			case reflect.Slice, reflect.Array:
+				suffix = "Items"
+			}
+			schema[name+suffix] = n
+		case "oneof":
+			schema["enum"] = strings.Fields(param)
+		case "mongodb":
+			schema["pattern"] = "^[0-9a-f]{24}$"
+		case "hexcolor":
+			schema["pattern"] = "^#([0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$"
+		case "eq":
+			// eq=|rule accepts an empty string as well
+			if alt, ok := strings.CutPrefix(param, "|"); ok {
+				applyRules(schema, t, []string{alt})
+				if pattern, ok := schema["pattern"].(string); ok {
+					schema["pattern"] = "^$|" + pattern
+				}
+			}
+		case "role":
+			roles := []string{}
+			for role := range config.RolePermissions {
+				roles = append(roles, role)
+			}
+			sort.Strings(roles)
+			schema["enum"] = roles
+		case "password":
+			schema["minLength"] = 8
+			schema["description"] = "At least 8 characters with upper case, lower case and a digit"
+		}
+	}
+}
+
+// operationID derives a stable id like getTodoById from the route
+func operationID(r fiber.Route) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(r.Method))
+	for _, part := range strings.Split(strings.TrimPrefix(r.Path, "/api"), "/") {
+		if part == "" {
+			continue
+		}
+		if part == "+" {
+			part = ":path"
+		}
+		if strings.HasPrefix(part, ":") {
+			b.WriteString("By")
+			part = part[1:]
+		}
+		for _, word := range strings.FieldsFunc(part, func(r rune) bool { return r == '-' || r == '.' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
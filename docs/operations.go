@@ -0,0 +1,309 @@
+package docs
+
+import (
+	"slices"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Message is the generic success response
+type Message struct {
+	Message string `json:"message"`
+}
+
+// Count is returned by the count routes
+type Count struct {
+	Count int64 `json:"count"`
+}
+
+// UserCount is returned when counting a user's todos
+type UserCount struct {
+	User  string `json:"user"`
+	Count int64  `json:"count"`
+}
+
+// LogoutAll is returned after revoking every session
+type LogoutAll struct {
+	Message string `json:"message"`
+	Revoked int64  `json:"revoked"`
+}
+
+// ListDeleted is returned after deleting a list, with the number of todos
+// moved to an inbox and deleted along with it
+type ListDeleted struct {
+	Message      string `json:"message"`
+	MovedTodos   int64  `json:"movedTodos,omitempty"`
+	DeletedTodos int64  `json:"deletedTodos,omitempty"`
+}
+
+// pageParams are the query params of every listing
+var pageParams = []Param{
+	{Name: "limit", Type: "integer", Description: "Page size, defaults to 20 and is capped at 100"},
+	{Name: "cursor", Type: "string", Description: "nextCursor or prevCursor of a previous page, filters and sort must stay the same"},
+	{Name: "page", Type: "integer", Description: "Page number starting at 1, instead of a cursor"},
+	{Name: "total", Type: "boolean", Description: "Also count every match"},
+}
+
+// userFilters are the query params of the user listings
+var userFilters = append(slices.Clone(pageParams),
+	Param{Name: "sort", Type: "string", Description: "createdAt, updatedAt, username or email, prefix with - for descending"},
+	Param{Name: "createdAfter", Type: "string", Description: "Only users created at or after this RFC 3339 time"},
+	Param{Name: "createdBefore", Type: "string", Description: "Only users created before this RFC 3339 time"},
+)
+
+// todoFilters are the query params of the todo listings
+var todoFilters = append(slices.Clone(pageParams),
+	Param{Name: "list", Type: "string", Description: "Only the todos of this list ID, or inbox for todos without a list"},
+	Param{Name: "completed", Type: "boolean", Description: "Only completed (true) or open (false) todos"},
+	Param{Name: "tags", Type: "string", Description: "Comma separated tags, todos must carry all of them"},
+	Param{Name: "priority", Type: "string", Description: "Comma separated priorities from 0 (none) to 3 (high), todos may have any of them"},
+	Param{Name: "dueAfter", Type: "string", Description: "Only todos due at or after this RFC 3339 time"},
+	Param{Name: "dueBefore", Type: "string", Description: "Only todos due before this RFC 3339 time"},
+	Param{Name: "q", Type: "string", Description: "Full-text search over title and description, results are ordered by relevance unless sort is set"},
+	Param{Name: "sort", Type: "string", Description: "Comma separated rank, createdAt, updatedAt, dueAt, completedAt, priority or title, prefix with - for descending. Defaults to rank, the order set with move"},
+)
+
+// Operations documents every route, keyed by Key(method, fiber path).
+// Adding a route to routes.SetUpRouter without an entry here fails the tests.
+var Operations = map[string]Operation{
+	// docs
+	Key(fiber.MethodGet, "/api/openapi.json"): {
+		Summary: "OpenAPI document", Tag: "docs", Public: true, Response: map[string]any{},
+	},
+	Key(fiber.MethodGet, "/api/docs"): {
+		Summary: "Swagger UI", Tag: "docs", Public: true,
+	},
+
+	// auth
+	Key(fiber.MethodPost, "/api/login"): {
+		Summary: "Login and receive an access and refresh token", Tag: "auth", Public: true,
+		Body: controllers.LoginInput{}, Response: controllers.LoginResponse{},
+	},
+	Key(fiber.MethodPost, "/api/token/refresh"): {
+		Summary: "Rotate the refresh token and receive a new access token", Tag: "auth", Public: true,
+		Body: controllers.RefreshInput{}, Response: controllers.LoginResponse{},
+	},
+	Key(fiber.MethodPost, "/api/logout"): {
+		Summary: "Revoke the current session", Tag: "auth", Response: Message{},
+	},
+	Key(fiber.MethodPost, "/api/logout-all"): {
+		Summary: "Revoke every session of the current user", Tag: "auth", Response: LogoutAll{},
+	},
+
+	// users
+	Key(fiber.MethodPost, "/api/user/register"): {
+		Summary: "Register a new user", Tag: "users", Public: true,
+		Body: controllers.RegisterInput{}, Response: Message{}, Status: fiber.StatusCreated,
+	},
+	Key(fiber.MethodGet, "/api/users"): {
+		Summary: "List all users oldest first (admin)", Tag: "users",
+		Response: controllers.ListResponse[models.User]{}, Query: userFilters,
+	},
+	Key(fiber.MethodGet, "/api/user/:id"): {
+		Summary: "Get a user", Tag: "users", Response: models.User{},
+	},
+	Key(fiber.MethodGet, "/api/users/paginated"): {
+		Summary: "List users newest first (admin)", Tag: "users",
+		Response: controllers.ListResponse[models.User]{}, Query: userFilters,
+	},
+	Key(fiber.MethodPut, "/api/user/:id"): {
+		Summary: "Update a user", Tag: "users",
+		Body: controllers.UpdateUserInput{}, Response: models.User{},
+	},
+	Key(fiber.MethodDelete, "/api/user/:id"): {
+		Summary: "Delete a user (admin)", Tag: "users", Response: Message{},
+	},
+	Key(fiber.MethodPut, "/api/change-password/:id"): {
+		Summary: "Change own password, revoking every session", Tag: "users",
+		Body: controllers.ChangePasswordInput{}, Response: Message{},
+	},
+	Key(fiber.MethodPut, "/api/reset-password/:id"): {
+		Summary: "Reset a user's password and revoke their sessions (admin)", Tag: "users",
+		Body: controllers.ResetPasswordInput{}, Response: Message{},
+	},
+
+	// todos
+	Key(fiber.MethodPost, "/api/todo/register"): {
+		Summary: "Create a todo for the current user (any user with todos:write-all)", Tag: "todos",
+		Body: controllers.CreateTodoInput{}, Form: controllers.CreateTodoInput{}, Files: []string{"image"},
+		Response: models.Todo{}, Status: fiber.StatusCreated,
+	},
+	Key(fiber.MethodGet, "/api/todos"): {
+		Summary: "List all todos (admin)", Tag: "todos", Response: controllers.ListResponse[models.Todo]{},
+		Query: todoFilters,
+	},
+	Key(fiber.MethodDelete, "/api/todo/:id"): {
+		Summary: "Delete own todo", Tag: "todos", Response: Message{},
+	},
+	Key(fiber.MethodPut, "/api/todo/:id"): {
+		Summary: "Update a todo (owner, editor or todos:write-all)", Tag: "todos",
+		Body: controllers.UpdateTodoInput{}, Form: controllers.UpdateTodoInput{}, Files: []string{"image"},
+		Response: models.Todo{},
+	},
+	Key(fiber.MethodGet, "/api/todo/:id"): {
+		Summary: "Get a todo (owner, collaborator or todos:read-all)", Tag: "todos", Response: models.Todo{},
+	},
+	Key(fiber.MethodPost, "/api/todo/:id/skip"): {
+		Summary: "Skip the current occurrence of a recurring todo, moving it to the next one", Tag: "todos",
+		Response: models.Todo{},
+	},
+	Key(fiber.MethodPost, "/api/todo/:id/move"): {
+		Summary: "Move a todo before or after another of its list or inbox, or to the end of a list (owner or editor)", Tag: "todos",
+		Body: controllers.MoveTodoInput{}, Response: models.Todo{},
+	},
+	Key(fiber.MethodGet, "/api/todo/:id/occurrences"): {
+		Summary: "List the occurrences of a recurring todo in a date range", Tag: "todos", Response: controllers.Occurrences{},
+		Query: []Param{
+			{Name: "from", Type: "string", Description: "RFC 3339 date-time or date in the todo's timezone, defaults to the due date"},
+			{Name: "to", Type: "string", Description: "Exclusive end of the range, defaults to a month after from, at most 366 days"},
+		},
+	},
+	Key(fiber.MethodGet, "/api/todos/:userId/count"): {
+		Summary: "Count a user's todos", Tag: "todos", Response: UserCount{},
+	},
+	Key(fiber.MethodGet, "/api/todos/count"): {
+		Summary: "Count all todos (admin, 3 requests per minute)", Tag: "todos", Response: Count{},
+	},
+	Key(fiber.MethodGet, "/api/todos/:userId"): {
+		Summary: "List a user's todos, with the todos shared with them when it's the current user", Tag: "todos",
+		Response: controllers.ListResponse[models.Todo]{},
+		Query: append(slices.Clone(todoFilters),
+			Param{Name: "shared", Type: "boolean", Description: "Include the todos shared with the current user, defaults to true"},
+		),
+	},
+
+	// lists
+	Key(fiber.MethodPost, "/api/list/register"): {
+		Summary: "Create a list for the current user", Tag: "lists",
+		Body: controllers.CreateListInput{}, Response: models.List{}, Status: fiber.StatusCreated,
+	},
+	Key(fiber.MethodGet, "/api/lists"): {
+		Summary: "List the current user's own and shared lists by position", Tag: "lists", Response: controllers.ListResponse[models.List]{},
+		Query: append(slices.Clone(pageParams),
+			Param{Name: "archived", Type: "boolean", Description: "List the archived lists instead of the active ones"},
+			Param{Name: "shared", Type: "boolean", Description: "Include the lists shared with the current user, defaults to true"},
+		),
+	},
+	Key(fiber.MethodGet, "/api/list/:id"): {
+		Summary: "Get an own or shared list", Tag: "lists", Response: models.List{},
+	},
+	Key(fiber.MethodPut, "/api/list/:id"): {
+		Summary: "Rename or recolor a list (owner or editor), archive or reorder it (owner)", Tag: "lists",
+		Body: controllers.UpdateListInput{}, Response: models.List{},
+	},
+	Key(fiber.MethodDelete, "/api/list/:id"): {
+		Summary: "Delete own list, moving its todos to the inbox or deleting them", Tag: "lists", Response: ListDeleted{},
+		Query: []Param{{Name: "todos", Type: "string", Description: "inbox (default) moves the list's todos to the inbox, delete deletes the owner's todos and moves the ones collaborators filed to their inbox"}},
+	},
+	Key(fiber.MethodGet, "/api/list/:id/todos"): {
+		Summary: "List the todos of an own or shared list", Tag: "lists", Response: controllers.ListResponse[models.Todo]{},
+		Query: todoFilters,
+	},
+
+	// sharing
+	Key(fiber.MethodPost, "/api/todo/:id/shares"): {
+		Summary: "Invite a user to collaborate on own todo", Tag: "sharing",
+		Body: controllers.InviteInput{}, Response: models.Invitation{}, Status: fiber.StatusCreated,
+	},
+	Key(fiber.MethodGet, "/api/todo/:id/shares"): {
+		Summary: "List a todo's collaborators and pending invitations", Tag: "sharing", Response: controllers.Shares{},
+	},
+	Key(fiber.MethodPut, "/api/todo/:id/shares/:userId"): {
+		Summary: "Change a todo collaborator's role", Tag: "sharing",
+		Body: controllers.ShareRoleInput{}, Response: models.Collaborator{},
+	},
+	Key(fiber.MethodDelete, "/api/todo/:id/shares/:userId"): {
+		Summary: "Stop sharing a todo with a collaborator, or leave it", Tag: "sharing", Response: Message{},
+	},
+	Key(fiber.MethodPost, "/api/list/:id/shares"): {
+		Summary: "Invite a user to collaborate on own list and its todos", Tag: "sharing",
+		Body: controllers.InviteInput{}, Response: models.Invitation{}, Status: fiber.StatusCreated,
+	},
+	Key(fiber.MethodGet, "/api/list/:id/shares"): {
+		Summary: "List a list's collaborators and pending invitations", Tag: "sharing", Response: controllers.Shares{},
+	},
+	Key(fiber.MethodPut, "/api/list/:id/shares/:userId"): {
+		Summary: "Change a list collaborator's role", Tag: "sharing",
+		Body: controllers.ShareRoleInput{}, Response: models.Collaborator{},
+	},
+	Key(fiber.MethodDelete, "/api/list/:id/shares/:userId"): {
+		Summary: "Stop sharing a list with a collaborator, or leave it", Tag: "sharing", Response: Message{},
+	},
+	Key(fiber.MethodGet, "/api/invitations"): {
+		Summary: "List the invitations sent to the current user", Tag: "sharing",
+		Response: controllers.ListResponse[models.Invitation]{},
+		Query: append(slices.Clone(pageParams),
+			Param{Name: "status", Type: "string", Description: "pending (default), accepted or declined"},
+		),
+	},
+	Key(fiber.MethodPost, "/api/invitation/:id/accept"): {
+		Summary: "Accept an invitation and become a collaborator", Tag: "sharing", Response: models.Invitation{},
+	},
+	Key(fiber.MethodPost, "/api/invitation/:id/decline"): {
+		Summary: "Decline an invitation", Tag: "sharing", Response: models.Invitation{},
+	},
+	Key(fiber.MethodDelete, "/api/invitation/:id"): {
+		Summary: "Withdraw a sent invitation", Tag: "sharing", Response: Message{},
+	},
+
+	// attachments
+	Key(fiber.MethodPost, "/api/todo/:id/attachments"): {
+		Summary: "Attach a file to own todo (counts towards the storage quota)", Tag: "attachments",
+		Form: struct{}{}, Files: []string{"file"}, Response: models.Attachment{}, Status: fiber.StatusCreated,
+	},
+	Key(fiber.MethodGet, "/api/todo/:id/attachments"): {
+		Summary: "List the attachments of own todo, oldest first", Tag: "attachments",
+		Response: controllers.ListResponse[models.Attachment]{}, Query: pageParams,
+	},
+	Key(fiber.MethodGet, "/api/todo/:id/attachments/:attachmentId"): {
+		Summary: "Get an attachment's metadata", Tag: "attachments", Response: models.Attachment{},
+	},
+	Key(fiber.MethodGet, "/api/todo/:id/attachments/:attachmentId/download"): {
+		Summary: "Download an attachment under its original name", Tag: "attachments",
+	},
+	Key(fiber.MethodDelete, "/api/todo/:id/attachments/:attachmentId"): {
+		Summary: "Delete an attachment", Tag: "attachments", Response: Message{},
+	},
+
+	// checklist
+	Key(fiber.MethodPost, "/api/todo/:id/items"): {
+		Summary: "Add a checklist item to own todo, returns the todo", Tag: "checklist",
+		Body: controllers.AddItemInput{}, Response: models.Todo{}, Status: fiber.StatusCreated,
+	},
+	Key(fiber.MethodGet, "/api/todo/:id/items"): {
+		Summary: "List the checklist of own todo", Tag: "checklist", Response: controllers.Checklist{},
+	},
+	Key(fiber.MethodGet, "/api/todo/:id/items/:itemId"): {
+		Summary: "Get a checklist item", Tag: "checklist", Response: models.ChecklistItem{},
+	},
+	Key(fiber.MethodPut, "/api/todo/:id/items/:itemId"): {
+		Summary: "Rename, complete or move a checklist item, returns the todo", Tag: "checklist",
+		Body: controllers.UpdateItemInput{}, Response: models.Todo{},
+	},
+	Key(fiber.MethodDelete, "/api/todo/:id/items/:itemId"): {
+		Summary: "Remove a checklist item, returns the todo", Tag: "checklist", Response: models.Todo{},
+	},
+
+	// reminders
+	Key(fiber.MethodPost, "/api/todo/:id/reminders"): {
+		Summary: "Remind the current user of a todo at a time or some minutes before it is due", Tag: "reminders",
+		Body: controllers.AddReminderInput{}, Response: models.Reminder{}, Status: fiber.StatusCreated,
+	},
+	Key(fiber.MethodGet, "/api/todo/:id/reminders"): {
+		Summary: "List the current user's reminders on a todo", Tag: "reminders", Response: []models.Reminder{},
+	},
+	Key(fiber.MethodDelete, "/api/todo/:id/reminders/:reminderId"): {
+		Summary: "Delete one of the current user's reminders", Tag: "reminders", Response: Message{},
+	},
+
+	// files
+	Key(fiber.MethodGet, "/api/files/+"): {
+		Summary: "Download a todo image (owner or collaborator, or a signed URL from imageUrls without a token)", Tag: "files",
+		Query: []Param{
+			{Name: "expires", Type: "integer", Description: "Expiry of a signed URL, unix seconds"},
+			{Name: "signature", Type: "string", Description: "HMAC signature of a signed URL"},
+		},
+	},
+}
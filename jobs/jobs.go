@@ -0,0 +1,26 @@
+// Package jobs holds background work that runs next to the API server
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every runs fn right away and then every interval until ctx is done,
+// errors are logged and the next run goes ahead as scheduled
+func Every(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("job %s failed: %v", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
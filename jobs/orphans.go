@@ -0,0 +1,129 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OrphanCollector reconciles stored files with the images and attachments
+// todos reference. Files nothing references are orphans, references to
+// missing files are dangling.
+type OrphanCollector struct {
+	Files       storage.Storage
+	Todos       repositories.TodoRepository
+	Attachments repositories.AttachmentRepository
+	// Grace protects recent files, uploads are stored before their todo is saved
+	Grace time.Duration
+	// DryRun only reports, nothing is deleted
+	DryRun bool
+}
+
+// DanglingRef is a file a todo points at, reported when it is not in storage
+type DanglingRef struct {
+	TodoID primitive.ObjectID
+	Key    string
+}
+
+// OrphanReport is the outcome of one collection
+type OrphanReport struct {
+	Scanned int
+	// Orphans are unreferenced files older than the grace period
+	Orphans []storage.Object
+	// Recent counts unreferenced files still within the grace period
+	Recent  int
+	Deleted int
+	// Failed lists orphans that could not be deleted
+	Failed   []string
+	Dangling []DanglingRef
+}
+
+// Run compares storage with the referenced keys and, unless DryRun is set,
+// deletes the orphans
+func (gc *OrphanCollector) Run(ctx context.Context) (OrphanReport, error) {
+	var report OrphanReport
+	cutoff := time.Now().Add(-gc.Grace)
+
+	// storage is listed before references are loaded, so a todo saved in
+	// between is seen as referencing its (listed) file
+	stored := map[string]storage.Object{}
+	err := gc.Files.List(ctx, "", func(obj storage.Object) error {
+		stored[obj.Key] = obj
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	report.Scanned = len(stored)
+
+	refs, err := gc.references(ctx)
+	if err != nil {
+		return report, err
+	}
+	referenced := map[string]bool{}
+	for _, ref := range refs {
+		referenced[ref.Key] = true
+		if _, ok := stored[ref.Key]; ok {
+			continue
+		}
+		// uploaded after the listing?
+		if _, err := gc.Files.Stat(ctx, ref.Key); errors.Is(err, storage.ErrNotFound) {
+			report.Dangling = append(report.Dangling, ref)
+		} else if err != nil {
+			return report, err
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(stored)) {
+		obj := stored[key]
+		if referenced[key] {
+			continue
+		}
+		if obj.LastModified.After(cutoff) {
+			report.Recent++
+			continue
+		}
+		report.Orphans = append(report.Orphans, obj)
+		if gc.DryRun {
+			continue
+		}
+		if err := gc.Files.Delete(ctx, key); err != nil {
+			report.Failed = append(report.Failed, key)
+			continue
+		}
+		report.Deleted++
+	}
+	return report, ctx.Err()
+}
+
+// references lists every stored file a todo points at
+func (gc *OrphanCollector) references(ctx context.Context) ([]DanglingRef, error) {
+	todos, err := gc.Todos.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var refs []DanglingRef
+	for _, todo := range todos {
+		for _, key := range todo.ImageKeys() {
+			refs = append(refs, DanglingRef{TodoID: todo.ID, Key: key})
+		}
+	}
+
+	if gc.Attachments == nil {
+		return refs, nil
+	}
+	attachments, err := gc.Attachments.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, attachment := range attachments {
+		refs = append(refs, DanglingRef{TodoID: attachment.TodoID, Key: attachment.Key})
+	}
+	return refs, nil
+}
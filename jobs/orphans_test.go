@@ -0,0 +1,97 @@
+package jobs_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/jobs"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// put stores a file and backdates it by age
+func put(t *testing.T, files storage.Storage, dir, key string, age time.Duration) {
+	t.Helper()
+	if err := files.Put(context.Background(), key, strings.NewReader("x"), 1, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Now().Add(-age)
+	if err := os.Chtimes(filepath.Join(dir, filepath.FromSlash(key)), modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(t *testing.T, files storage.Storage, key string) bool {
+	t.Helper()
+	_, err := files.Stat(context.Background(), key)
+	return err == nil
+}
+
+func TestOrphanCollector(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	files, err := storage.NewLocal(dir, storage.NewSigner("secret", "/api/files"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	todos := repositories.NewMemoryTodoRepository()
+
+	put(t, files, dir, "todos/kept.png", 48*time.Hour)
+	put(t, files, dir, "todos/kept_thumb.png", 48*time.Hour)
+	put(t, files, dir, "todos/old.png", 48*time.Hour)
+	put(t, files, dir, "todos/fresh.png", time.Minute)
+
+	todo := models.Todo{
+		UserID:        primitive.NewObjectID(),
+		Title:         "one",
+		Image:         "todos/kept.png",
+		ImageVariants: map[string]string{"thumb": "todos/kept_thumb.png", "medium": "todos/gone_medium.png"},
+	}
+	if err := todos.Create(ctx, &todo); err != nil {
+		t.Fatal(err)
+	}
+
+	attachments := repositories.NewMemoryAttachmentRepository()
+	put(t, files, dir, "attachments/doc.pdf", 48*time.Hour)
+	if err := attachments.Create(ctx, &models.Attachment{TodoID: todo.ID, Key: "attachments/doc.pdf", Size: 1}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	gc := &jobs.OrphanCollector{Files: files, Todos: todos, Attachments: attachments, Grace: 24 * time.Hour, DryRun: true}
+	report, err := gc.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Scanned != 5 || report.Recent != 1 || report.Deleted != 0 {
+		t.Fatalf("unexpected dry run report %+v", report)
+	}
+	if len(report.Orphans) != 1 || report.Orphans[0].Key != "todos/old.png" {
+		t.Fatalf("expected todos/old.png as the only orphan, got %+v", report.Orphans)
+	}
+	if len(report.Dangling) != 1 || report.Dangling[0].Key != "todos/gone_medium.png" || report.Dangling[0].TodoID != todo.ID {
+		t.Fatalf("expected the missing medium variant as dangling, got %+v", report.Dangling)
+	}
+	if !exists(t, files, "todos/old.png") {
+		t.Fatal("a dry run must not delete anything")
+	}
+
+	gc.DryRun = false
+	report, err = gc.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Deleted != 1 || exists(t, files, "todos/old.png") {
+		t.Fatalf("expected the orphan to be deleted, got %+v", report)
+	}
+	for _, key := range []string{"todos/kept.png", "todos/kept_thumb.png", "todos/fresh.png", "attachments/doc.pdf"} {
+		if !exists(t, files, key) {
+			t.Fatalf("expected %s to be kept", key)
+		}
+	}
+}
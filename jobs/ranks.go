@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/rank"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+)
+
+// RankRebalancer spreads out the ranks of lists (and inboxes) where moves
+// made them long. Rebalancing keeps the order, only the keys change.
+type RankRebalancer struct {
+	Todos repositories.TodoRepository
+	// MaxLength is the rank length past which a scope is rebalanced,
+	// defaults to rank.MaxLength
+	MaxLength int
+}
+
+// RankReport is the outcome of one run
+type RankReport struct {
+	Scopes int
+	// Todos counts the todos given a new rank
+	Todos int64
+}
+
+// Run rebalances every scope holding a rank longer than MaxLength
+func (rr *RankRebalancer) Run(ctx context.Context) (RankReport, error) {
+	var report RankReport
+	length := rr.MaxLength
+	if length <= 0 {
+		length = rank.MaxLength
+	}
+	scopes, err := rr.Todos.LongRanks(ctx, length)
+	if err != nil {
+		return report, err
+	}
+	for _, scope := range scopes {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		changed, err := rr.Todos.Rebalance(ctx, scope)
+		if err != nil {
+			return report, err
+		}
+		report.Scopes++
+		report.Todos += changed
+	}
+	return report, nil
+}
@@ -0,0 +1,63 @@
+package jobs_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/jobs"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRankRebalancer(t *testing.T) {
+	ctx := context.Background()
+	repos := repositories.NewMemoryRepositories()
+	userID, listID := primitive.NewObjectID(), primitive.NewObjectID()
+
+	create := func(title, key string, list *primitive.ObjectID) models.Todo {
+		t.Helper()
+		todo := models.Todo{UserID: userID, Title: title, Rank: key, ListID: list}
+		if err := repos.Todos.Create(ctx, &todo); err != nil {
+			t.Fatal(err)
+		}
+		return todo
+	}
+	// a list where moves into the same spot grew the keys
+	long := []models.Todo{
+		create("first", "V", &listID),
+		create("second", "V"+strings.Repeat("1", 10), &listID),
+		create("third", "V"+strings.Repeat("1", 9)+"2", &listID),
+		create("last", "W", &listID),
+	}
+	inbox := create("inbox", "V", nil)
+
+	rebalancer := &jobs.RankRebalancer{Todos: repos.Todos, MaxLength: 8}
+	report, err := rebalancer.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Scopes != 1 || report.Todos != 4 {
+		t.Fatalf("expected the list rebalanced, got %+v", report)
+	}
+	prev := ""
+	for _, todo := range long {
+		got, err := repos.Todos.FindByID(ctx, todo.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Rank) > 2 || got.Rank <= prev {
+			t.Fatalf("expected short ranks in the same order, got %q after %q", got.Rank, prev)
+		}
+		prev = got.Rank
+	}
+	if got, _ := repos.Todos.FindByID(ctx, inbox.ID); got.Rank != "V" {
+		t.Fatalf("expected the inbox untouched, got %q", got.Rank)
+	}
+
+	// nothing left to do
+	if report, err := rebalancer.Run(ctx); err != nil || report != (jobs.RankReport{}) {
+		t.Fatalf("expected an empty run, got %+v %v", report, err)
+	}
+}
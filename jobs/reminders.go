@@ -0,0 +1,174 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/notify"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+)
+
+// ReminderSender delivers due reminders. Each one is leased to Owner for
+// Lease before it is sent, so instances sharing the database never send it
+// twice at the same time. Delivery is at least once: a reminder whose lease
+// runs out before it is finished is sent again.
+type ReminderSender struct {
+	Reminders repositories.ReminderRepository
+	Todos     repositories.TodoRepository
+	Users     repositories.UserRepository
+	Lists     repositories.ListRepository
+	// Notifiers by reminder channel, reminders on a missing channel fail
+	Notifiers map[string]notify.Notifier
+	// Owner identifies this instance in leases
+	Owner string
+	Lease time.Duration
+	// MaxAttempts is how often delivery is tried before the reminder fails
+	MaxAttempts int
+	// Batch caps the reminders sent per run, 0 sends all that are due
+	Batch int
+}
+
+// ReminderReport is the outcome of one run
+type ReminderReport struct {
+	Sent      int
+	Retried   int
+	Failed    int
+	Cancelled int
+}
+
+// retry delays grow from a minute to an hour
+const (
+	minReminderRetry = time.Minute
+	maxReminderRetry = time.Hour
+)
+
+// Run sends the reminders that are due
+func (rs *ReminderSender) Run(ctx context.Context) (ReminderReport, error) {
+	var report ReminderReport
+	for rs.Batch == 0 || report.Sent+report.Retried+report.Failed+report.Cancelled < rs.Batch {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		now := time.Now()
+		reminder, err := rs.Reminders.Lease(ctx, now, rs.Owner, now.Add(rs.Lease))
+		if errors.Is(err, repositories.ErrNotFound) {
+			return report, nil
+		}
+		if err != nil {
+			return report, err
+		}
+
+		result, err := rs.deliver(ctx, reminder)
+		if err != nil {
+			// the reminder is tried again once the lease runs out
+			return report, err
+		}
+		if err := rs.Reminders.Finish(ctx, reminder.ID, rs.Owner, result); errors.Is(err, repositories.ErrNotFound) {
+			log.Printf("reminders: lost the lease on %s before finishing it", reminder.ID.Hex())
+			continue
+		} else if err != nil {
+			return report, err
+		}
+
+		switch result.Status {
+		case models.ReminderSent:
+			report.Sent++
+		case models.ReminderPending:
+			report.Retried++
+		case models.ReminderFailed:
+			report.Failed++
+		case models.ReminderCancelled:
+			report.Cancelled++
+		}
+	}
+	return report, nil
+}
+
+// deliver notifies the reminder's user, unless the todo is done or they can
+// no longer see it. An error means the reminder couldn't be looked at.
+func (rs *ReminderSender) deliver(ctx context.Context, reminder models.Reminder) (repositories.ReminderResult, error) {
+	cancelled := func(reason string) (repositories.ReminderResult, error) {
+		return repositories.ReminderResult{Status: models.ReminderCancelled, Error: reason}, nil
+	}
+
+	todo, err := rs.Todos.FindByID(ctx, reminder.TodoID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return cancelled("the todo was deleted")
+	} else if err != nil {
+		return repositories.ReminderResult{}, err
+	}
+	if todo.Completed {
+		return cancelled("the todo is completed")
+	}
+	var list *models.List
+	if todo.ListID != nil {
+		found, err := rs.Lists.FindByID(ctx, *todo.ListID)
+		if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+			return repositories.ReminderResult{}, err
+		}
+		if err == nil {
+			list = &found
+		}
+	}
+	if todo.Access(reminder.UserID, list) == models.AccessNone {
+		return cancelled("the user no longer has access to the todo")
+	}
+	user, err := rs.Users.FindByID(ctx, reminder.UserID)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return cancelled("the user was deleted")
+	} else if err != nil {
+		return repositories.ReminderResult{}, err
+	}
+
+	notifier, ok := rs.Notifiers[reminder.Channel]
+	if !ok {
+		return repositories.ReminderResult{Status: models.ReminderFailed, Error: "channel " + reminder.Channel + " is not configured"}, nil
+	}
+	if err := notifier.Notify(ctx, reminderNotification(reminder, todo, user)); err != nil {
+		if reminder.Attempts >= rs.MaxAttempts {
+			return repositories.ReminderResult{Status: models.ReminderFailed, Error: err.Error()}, nil
+		}
+		return repositories.ReminderResult{Status: models.ReminderPending, Error: err.Error(), RetryAt: time.Now().Add(retryDelay(reminder.Attempts))}, nil
+	}
+	return repositories.ReminderResult{Status: models.ReminderSent}, nil
+}
+
+// retryDelay doubles with every failed attempt
+func retryDelay(attempts int) time.Duration {
+	delay := minReminderRetry
+	for i := 1; i < attempts && delay < maxReminderRetry; i++ {
+		delay *= 2
+	}
+	return min(delay, maxReminderRetry)
+}
+
+// reminderNotification is the message sent for a reminder
+func reminderNotification(reminder models.Reminder, todo models.Todo, user models.User) notify.Notification {
+	text := todo.Title
+	if todo.DueAt != nil {
+		dueAt := *todo.DueAt
+		if loc, err := time.LoadLocation(todo.DueTimezone); err == nil {
+			dueAt = dueAt.In(loc)
+		}
+		text = fmt.Sprintf("%s is due %s", todo.Title, dueAt.Format("Mon, 02 Jan 2006 15:04 MST"))
+	}
+	if todo.Description != "" {
+		text += "\n\n" + todo.Description
+	}
+
+	return notify.Notification{
+		To:      notify.Recipient{ID: user.ID.Hex(), Username: user.Username, Email: user.Email},
+		Subject: "Reminder: " + todo.Title,
+		Text:    text,
+		Data: map[string]any{
+			"reminderId": reminder.ID.Hex(),
+			"todoId":     todo.ID.Hex(),
+			"title":      todo.Title,
+			"dueAt":      todo.DueAt,
+		},
+	}
+}
@@ -0,0 +1,194 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/jobs"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/notify"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeNotifier records notifications, failing while fail is set
+type fakeNotifier struct {
+	mu   sync.Mutex
+	sent []notify.Notification
+	fail error
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, n notify.Notification) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail != nil {
+		return f.fail
+	}
+	f.sent = append(f.sent, n)
+	return nil
+}
+
+type reminderFixture struct {
+	repos    *repositories.Repositories
+	notifier *fakeNotifier
+	bob      models.User
+}
+
+func newReminderFixture(t *testing.T) *reminderFixture {
+	t.Helper()
+	f := &reminderFixture{repos: repositories.NewMemoryRepositories(), notifier: &fakeNotifier{}}
+	f.bob = models.User{Username: "bob", Email: "bob@example.com"}
+	if err := f.repos.Users.Create(context.Background(), &f.bob); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *reminderFixture) sender(owner string, maxAttempts int) *jobs.ReminderSender {
+	return &jobs.ReminderSender{
+		Reminders:   f.repos.Reminders,
+		Todos:       f.repos.Todos,
+		Users:       f.repos.Users,
+		Lists:       f.repos.Lists,
+		Notifiers:   map[string]notify.Notifier{models.ChannelEmail: f.notifier},
+		Owner:       owner,
+		Lease:       time.Minute,
+		MaxAttempts: maxAttempts,
+	}
+}
+
+// remind creates a todo and a reminder for user firing at
+func (f *reminderFixture) remind(t *testing.T, todo models.Todo, user primitive.ObjectID, at time.Time) models.Reminder {
+	t.Helper()
+	ctx := context.Background()
+	if todo.ID.IsZero() {
+		if err := f.repos.Todos.Create(ctx, &todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reminder := models.Reminder{TodoID: todo.ID, UserID: user, Channel: models.ChannelEmail, At: &at, FireAt: &at}
+	if err := f.repos.Reminders.Create(ctx, &reminder); err != nil {
+		t.Fatal(err)
+	}
+	return reminder
+}
+
+func (f *reminderFixture) status(t *testing.T, id primitive.ObjectID) models.Reminder {
+	t.Helper()
+	reminder, err := f.repos.Reminders.FindByID(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reminder
+}
+
+func TestReminderSender(t *testing.T) {
+	f := newReminderFixture(t)
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	var due []models.Reminder
+	for _, title := range []string{"one", "two", "three", "four"} {
+		due = append(due, f.remind(t, models.Todo{UserID: f.bob.ID, Title: title}, f.bob.ID, past))
+	}
+	later := f.remind(t, models.Todo{UserID: f.bob.ID, Title: "later"}, f.bob.ID, future)
+	done := f.remind(t, models.Todo{UserID: f.bob.ID, Title: "done", Completed: true}, f.bob.ID, past)
+	// reminders outlive a collaborator's access
+	notShared := f.remind(t, models.Todo{UserID: primitive.NewObjectID(), Title: "not shared"}, f.bob.ID, past)
+
+	// two instances running at once send every due reminder exactly once
+	reports := make([]jobs.ReminderReport, 2)
+	var wg sync.WaitGroup
+	for i, owner := range []string{"a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report, err := f.sender(owner, 3).Run(context.Background())
+			if err != nil {
+				t.Error(err)
+			}
+			reports[i] = report
+		}()
+	}
+	wg.Wait()
+	if sent, cancelled := reports[0].Sent+reports[1].Sent, reports[0].Cancelled+reports[1].Cancelled; sent != 4 || cancelled != 2 {
+		t.Fatalf("expected 4 sent and 2 cancelled, got %+v", reports)
+	}
+	seen := map[string]bool{}
+	for _, n := range f.notifier.sent {
+		if seen[n.Subject] || n.To.Email != "bob@example.com" {
+			t.Fatalf("unexpected notification %+v", n)
+		}
+		seen[n.Subject] = true
+	}
+	for _, reminder := range due {
+		if got := f.status(t, reminder.ID); got.Status != models.ReminderSent || got.SentAt == nil || got.Attempts != 1 {
+			t.Fatalf("expected a sent reminder, got %+v", got)
+		}
+	}
+	if got := f.status(t, later.ID); got.Status != models.ReminderPending || got.Attempts != 0 {
+		t.Fatalf("expected the future reminder untouched, got %+v", got)
+	}
+	for _, id := range []primitive.ObjectID{done.ID, notShared.ID} {
+		if got := f.status(t, id); got.Status != models.ReminderCancelled || got.LastError == "" {
+			t.Fatalf("expected a cancelled reminder, got %+v", got)
+		}
+	}
+
+	// an instance that died holding a lease loses the reminder once the lease runs out
+	crashed := f.remind(t, models.Todo{UserID: f.bob.ID, Title: "crashed"}, f.bob.ID, past)
+	if _, err := f.repos.Reminders.Lease(context.Background(), time.Now(), "c", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if report, err := f.sender("a", 3).Run(context.Background()); err != nil || report.Sent != 1 {
+		t.Fatalf("expected the expired lease taken over, got %+v %v", report, err)
+	}
+	if err := f.repos.Reminders.Finish(context.Background(), crashed.ID, "c", repositories.ReminderResult{Status: models.ReminderSent}); !errors.Is(err, repositories.ErrNotFound) {
+		t.Fatalf("expected the lost lease to be refused, got %v", err)
+	}
+}
+
+func TestReminderSenderRetries(t *testing.T) {
+	f := newReminderFixture(t)
+	f.notifier.fail = errors.New("smtp: 421 try again later")
+	past := time.Now().Add(-time.Minute)
+	reminder := f.remind(t, models.Todo{UserID: f.bob.ID, Title: "pay rent"}, f.bob.ID, past)
+
+	// a failed delivery waits before it is tried again
+	sender := f.sender("a", 2)
+	if report, err := sender.Run(context.Background()); err != nil || report.Retried != 1 {
+		t.Fatalf("expected a retry, got %+v %v", report, err)
+	}
+	got := f.status(t, reminder.ID)
+	if got.Status != models.ReminderPending || got.Attempts != 1 || got.LastError != "smtp: 421 try again later" ||
+		got.LeaseUntil == nil || got.LeaseUntil.Before(time.Now().Add(50*time.Second)) {
+		t.Fatalf("unexpected reminder after a failure %+v", got)
+	}
+	if report, _ := sender.Run(context.Background()); report.Retried != 0 {
+		t.Fatalf("expected the retry to wait, got %+v", report)
+	}
+
+	// the last attempt fails the reminder
+	last := f.remind(t, models.Todo{UserID: f.bob.ID, Title: "call mum"}, f.bob.ID, past)
+	if report, err := f.sender("a", 1).Run(context.Background()); err != nil || report.Failed != 1 || report.Retried != 0 {
+		t.Fatalf("expected a failure, got %+v %v", report, err)
+	}
+	if got := f.status(t, last.ID); got.Status != models.ReminderFailed || got.Attempts != 1 || got.LastError == "" {
+		t.Fatalf("unexpected failed reminder %+v", got)
+	}
+
+	// so do reminders on a channel without a notifier
+	f.notifier.fail = nil
+	webhook := f.remind(t, models.Todo{UserID: f.bob.ID, Title: "hook"}, f.bob.ID, past)
+	webhook.ID, webhook.Channel = primitive.ObjectID{}, models.ChannelWebhook
+	if err := f.repos.Reminders.Create(context.Background(), &webhook); err != nil {
+		t.Fatal(err)
+	}
+	if report, err := f.sender("a", 3).Run(context.Background()); err != nil || report.Failed != 1 || report.Sent != 1 {
+		t.Fatalf("expected the webhook reminder to fail, got %+v %v", report, err)
+	}
+	if got := f.status(t, webhook.ID); got.Status != models.ReminderFailed || got.LastError != "channel webhook is not configured" {
+		t.Fatalf("unexpected webhook reminder %+v", got)
+	}
+}
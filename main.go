@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
 	"github.com/clinton-mwachia/go-fiber-api-template/config"
-	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/clinton-mwachia/go-fiber-api-template/jobs"
+	"github.com/clinton-mwachia/go-fiber-api-template/middlewares"
+	"github.com/clinton-mwachia/go-fiber-api-template/migrations"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/notify"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
 	"github.com/clinton-mwachia/go-fiber-api-template/routes"
-	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"github.com/clinton-mwachia/go-fiber-api-template/storage"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -18,15 +28,32 @@ import (
 )
 
 func main() {
-	app := fiber.New()
+	// subcommands such as `migrate` run instead of the server
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// load env
+	config.Load()
+
+	app := fiber.New(fiber.Config{
+		// render every returned error as application/problem+json
+		ErrorHandler: middlewares.ErrorHandler,
+		// room for the largest upload plus the rest of the multipart form
+		BodyLimit: int(config.Cfg.MaxUploadBytes) + 1<<20,
+	})
 
 	// cors config for customization
 	app.Use(cors.New(cors.Config{
 		// user "*" in AllowOrigins to allow all origins, methods etc but it is prohibited
 		// because it can expose your application to security risks.
-		AllowOrigins: "http://127.0.0.1:8080",
-		AllowMethods: "GET,POST,PUT,DELETE",
-		AllowHeaders: "Origin, Content-Type, Accept",
+		AllowOrigins:  "http://127.0.0.1:8080",
+		AllowMethods:  "GET,POST,PUT,DELETE",
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization",
+		ExposeHeaders: "X-Request-ID",
 	}))
 
 	// Rate Limiting middleware for all routes
@@ -34,9 +61,7 @@ func main() {
 		Max:        100,             // max requests
 		Expiration: 1 * time.Minute, // per minute
 		LimitReached: func(c *fiber.Ctx) error {
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"error": "Too many requests, please try again later",
-			})
+			return apperrors.TooManyRequests("Too many requests, please try again later")
 		},
 	}))
 
@@ -45,20 +70,68 @@ func main() {
 		Level: compress.LevelBestCompression,
 	}))
 
-	// ensure uploads folder is created
-	utils.EnsureUploadsFolder()
-
-	// load env
-	config.Load()
 	// connect DB
 	config.ConnectDB()
 
-	// initialize user collection
-	controllers.InitUserCollection()
-	controllers.InitTodoCollection()
+	// bring the schema up to date, the lock makes concurrent instances skip this
+	if config.Cfg.AutoMigrate {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		done, err := migrations.New(config.DB).Up(ctx, 0)
+		cancel()
+		for _, m := range done {
+			log.Printf("applied migration %d %s", m.Version, m.Name)
+		}
+		if errors.Is(err, migrations.ErrLocked) {
+			log.Println("migrations are being applied by another instance")
+		} else if err != nil {
+			log.Printf("⚠️ migration failed, run `migrate status` to inspect: %v", err)
+		}
+	}
+
+	// repositories backed by mongo
+	repos := repositories.NewMongoRepositories(config.DB)
+
+	// uploaded files, local folder or S3 depending on STORAGE_DRIVER
+	signer := storage.NewSigner(config.Cfg.FileURLSecret, "/api/files")
+	files, err := newStorage(context.Background(), signer)
+	if err != nil {
+		log.Fatal("Storage setup error: ", err)
+	}
 
 	// setup routes (controllers contain logic)
-	routes.SetUpRouter(app)
+	routes.SetUpRouter(app, repos, files, signer)
+
+	// background jobs stop when the server shuts down
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	if interval := config.Cfg.OrphanGCIntervalMin; interval > 0 {
+		gc := newOrphanCollector(repos, files, config.Cfg.OrphanGCDryRun)
+		go jobs.Every(jobsCtx, "orphan-gc", time.Duration(interval)*time.Minute, func(ctx context.Context) error {
+			report, err := gc.Run(ctx)
+			logOrphanReport(report, gc.DryRun)
+			return err
+		})
+	}
+	if interval := config.Cfg.ReminderIntervalSec; interval > 0 {
+		sender := newReminderSender(repos)
+		go jobs.Every(jobsCtx, "reminders", time.Duration(interval)*time.Second, func(ctx context.Context) error {
+			report, err := sender.Run(ctx)
+			if report != (jobs.ReminderReport{}) {
+				log.Printf("reminders: %d sent, %d to retry, %d failed, %d cancelled", report.Sent, report.Retried, report.Failed, report.Cancelled)
+			}
+			return err
+		})
+	}
+
+	if interval := config.Cfg.RankRebalanceIntervalMin; interval > 0 {
+		rebalancer := &jobs.RankRebalancer{Todos: repos.Todos}
+		go jobs.Every(jobsCtx, "rank-rebalance", time.Duration(interval)*time.Minute, func(ctx context.Context) error {
+			report, err := rebalancer.Run(ctx)
+			if report.Scopes > 0 {
+				log.Printf("rank-rebalance: %d todos re-ranked in %d lists", report.Todos, report.Scopes)
+			}
+			return err
+		})
+	}
 
 	// server admin
 	app.Static("/admin", "./admin")
@@ -81,6 +154,87 @@ func main() {
 	<-stop
 	log.Println("🛑 Shutting down server...")
 
+	stopJobs()
+
 	// Disconnect DB gracefully
 	config.DisconnectDB()
 }
+
+// newOrphanCollector reconciles files with todos using the configured grace period
+func newOrphanCollector(repos *repositories.Repositories, files storage.Storage, dryRun bool) *jobs.OrphanCollector {
+	return &jobs.OrphanCollector{
+		Files:       files,
+		Todos:       repos.Todos,
+		Attachments: repos.Attachments,
+		Grace:       time.Duration(config.Cfg.OrphanGCGraceHours) * time.Hour,
+		DryRun:      dryRun,
+	}
+}
+
+// newReminderSender sends reminders over the channels the config enables,
+// leases are held in the name of this host and process
+func newReminderSender(repos *repositories.Repositories) *jobs.ReminderSender {
+	cfg := config.Cfg
+	notifiers := map[string]notify.Notifier{}
+	if cfg.SMTPHost != "" {
+		notifiers[models.ChannelEmail] = &notify.SMTP{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		}
+	}
+	if cfg.ReminderWebhookURL != "" {
+		notifiers[models.ChannelWebhook] = &notify.Webhook{URL: cfg.ReminderWebhookURL, Secret: cfg.ReminderWebhookSecret}
+	}
+
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return &jobs.ReminderSender{
+		Reminders:   repos.Reminders,
+		Todos:       repos.Todos,
+		Users:       repos.Users,
+		Lists:       repos.Lists,
+		Notifiers:   notifiers,
+		Owner:       host + "-" + hex.EncodeToString(suffix),
+		Lease:       2 * time.Minute,
+		MaxAttempts: 5,
+		Batch:       100,
+	}
+}
+
+// logOrphanReport logs what a background collection found
+func logOrphanReport(report jobs.OrphanReport, dryRun bool) {
+	if len(report.Orphans) == 0 && len(report.Dangling) == 0 {
+		return
+	}
+	if dryRun {
+		log.Printf("orphan-gc: %d of %d files are orphaned (dry run, nothing deleted)", len(report.Orphans), report.Scanned)
+	} else {
+		log.Printf("orphan-gc: deleted %d of %d orphaned files, %d failed", report.Deleted, len(report.Orphans), len(report.Failed))
+	}
+	for _, ref := range report.Dangling {
+		log.Printf("orphan-gc: todo %s references missing file %s", ref.TodoID.Hex(), ref.Key)
+	}
+}
+
+// newStorage builds the file storage selected by the config,
+// signer signs and checks the URLs served by /api/files
+func newStorage(ctx context.Context, signer *storage.Signer) (storage.Storage, error) {
+	cfg := config.Cfg
+	return storage.New(ctx, storage.Config{
+		Driver: cfg.StorageDriver,
+		Dir:    cfg.StorageDir,
+		Signer: signer,
+		S3: storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			UseSSL:    cfg.S3UseSSL,
+		},
+	})
+}
@@ -0,0 +1,249 @@
+package media_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/media"
+)
+
+var policy = media.Policy{MaxBytes: 64 << 10, Allowed: media.ImageTypes}
+
+func testImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for x := 0; x < 8; x++ {
+		img.Set(x, x, color.RGBA{R: 255, A: 255})
+	}
+	return img
+}
+
+// exifSegment builds an APP1 segment with orientation and a fake GPS marker
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte{'I', 'I', 0x2A, 0x00}
+	tiff = binary.LittleEndian.AppendUint32(tiff, 8)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	tiff = append(tiff, []byte("GPS 52.3676N 4.9041E")...)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func jpegWithExif(t *testing.T, orientation uint16) []byte {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	// right after SOI
+	out := append([]byte{}, data[:2]...)
+	out = append(out, exifSegment(orientation)...)
+	comment := []byte{0xFF, 0xFE, 0x00, 0x0B}
+	out = append(out, append(comment, []byte("secret!!!")...)...)
+	return append(out, data[2:]...)
+}
+
+func pngWithText(t *testing.T) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	text := []byte("Comment\x00taken at home")
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(text)))
+	chunk = append(chunk, "tEXt"...)
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(append([]byte("tEXt"), text...)))
+
+	// after the signature and IHDR (8 + 25 bytes)
+	out := append([]byte{}, data[:33]...)
+	out = append(out, chunk...)
+	return append(out, data[33:]...)
+}
+
+func TestReadStripsJPEGMetadata(t *testing.T) {
+	data := jpegWithExif(t, 6)
+	f, err := media.Read(bytes.NewReader(data), int64(len(data)), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ContentType != "image/jpeg" || f.Ext != ".jpg" {
+		t.Fatalf("unexpected type %s %s", f.ContentType, f.Ext)
+	}
+	if bytes.Contains(f.Data, []byte("GPS")) || bytes.Contains(f.Data, []byte("secret")) {
+		t.Fatal("expected metadata to be removed")
+	}
+	if !bytes.Contains(f.Data, []byte("Exif\x00\x00MM")) {
+		t.Fatal("expected orientation to be kept")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(f.Data)); err != nil {
+		t.Fatalf("stripped jpeg no longer decodes: %v", err)
+	}
+
+	// nothing worth keeping for upright images
+	data = jpegWithExif(t, 1)
+	f, _ = media.Read(bytes.NewReader(data), int64(len(data)), policy)
+	if bytes.Contains(f.Data, []byte("Exif")) {
+		t.Fatal("expected exif to be removed entirely")
+	}
+}
+
+func TestReadStripsPNGText(t *testing.T) {
+	data := pngWithText(t)
+	f, err := media.Read(bytes.NewReader(data), int64(len(data)), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(f.Data, []byte("taken at home")) {
+		t.Fatal("expected text chunk to be removed")
+	}
+	if _, err := png.Decode(bytes.NewReader(f.Data)); err != nil {
+		t.Fatalf("stripped png no longer decodes: %v", err)
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	html := []byte("<html><script>alert(1)</script></html>")
+	if _, err := media.Read(bytes.NewReader(html), int64(len(html)), policy); !errors.Is(err, media.ErrUnsupportedType) {
+		t.Fatalf("expected ErrUnsupportedType, got %v", err)
+	}
+
+	// a png signature followed by garbage
+	broken := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\xffIHDR")
+	if _, err := media.Read(bytes.NewReader(broken), int64(len(broken)), policy); !errors.Is(err, media.ErrUnsupportedType) {
+		t.Fatalf("expected malformed png to be rejected, got %v", err)
+	}
+
+	big := bytes.Repeat([]byte{0}, int(policy.MaxBytes)+1)
+	if _, err := media.Read(bytes.NewReader(big), int64(len(big)), policy); !errors.Is(err, media.ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+	// lying about the size does not help
+	if _, err := media.Read(bytes.NewReader(big), 10, policy); !errors.Is(err, media.ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge for understated size, got %v", err)
+	}
+}
+
+func TestRandomKey(t *testing.T) {
+	a, b := media.RandomKey("todos", ".png"), media.RandomKey("todos", ".png")
+	if a == b || !strings.HasPrefix(a, "todos/") || !strings.HasSuffix(a, ".png") || len(a) != len("todos/")+32+len(".png") {
+		t.Fatalf("unexpected keys %q %q", a, b)
+	}
+}
+
+func TestCleanFilename(t *testing.T) {
+	for in, want := range map[string]string{
+		"report.pdf":             "report.pdf",
+		"../../etc/passwd":       "passwd",
+		`C:\Users\bob\notes.txt`: "notes.txt",
+		"bad\x00name\n.txt":      "badname.txt",
+		"  ":                     "file",
+		"..":                     "file",
+		strings.Repeat("é", 200): strings.Repeat("é", 127),
+	} {
+		if got := media.CleanFilename(in); got != want {
+			t.Errorf("CleanFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestReadStripsWebPExif(t *testing.T) {
+	chunk := func(fourCC string, data []byte) []byte {
+		out := append([]byte(fourCC), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+		out = append(out, data...)
+		if len(data)%2 == 1 {
+			out = append(out, 0)
+		}
+		return out
+	}
+	body := []byte("WEBP")
+	body = append(body, chunk("VP8X", []byte{0x08, 0, 0, 0, 7, 0, 0, 7, 0, 0})...)
+	body = append(body, chunk("VP8L", []byte{0x2f, 7, 0xc0, 0x01, 0x10})...)
+	body = append(body, chunk("EXIF", []byte("GPS 52.3676N"))...)
+	data := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	data = append(data, body...)
+
+	f, err := media.Read(bytes.NewReader(data), int64(len(data)), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.ContentType != "image/webp" || bytes.Contains(f.Data, []byte("GPS")) {
+		t.Fatalf("expected exif chunk to be removed from %s", f.ContentType)
+	}
+	if f.Data[20]&0x08 != 0 {
+		t.Fatal("expected VP8X exif flag to be cleared")
+	}
+	if got := binary.LittleEndian.Uint32(f.Data[4:]); int(got) != len(f.Data)-8 {
+		t.Fatalf("expected RIFF size %d, got %d", len(f.Data)-8, got)
+	}
+}
+
+func TestVariants(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 400, 200)), nil); err != nil {
+		t.Fatal(err)
+	}
+	// rotated 90 degrees by the camera
+	data := append([]byte{0xFF, 0xD8}, exifSegment(6)...)
+	data = append(data, buf.Bytes()[2:]...)
+
+	f, err := media.Read(bytes.NewReader(data), int64(len(data)), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renditions, err := media.Variants(f, media.DefaultVariants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]image.Point{"thumb": {100, 200}, "medium": {200, 400}}
+	for _, r := range renditions {
+		img, format, err := image.Decode(bytes.NewReader(r.Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != "jpeg" || r.ContentType != "image/jpeg" || r.Ext != ".jpg" {
+			t.Fatalf("expected jpeg %s, got %s %s", r.Name, format, r.ContentType)
+		}
+		if got := img.Bounds().Size(); got != want[r.Name] {
+			t.Fatalf("expected %s to be %v upright and never upscaled, got %v", r.Name, want[r.Name], got)
+		}
+	}
+
+	png := pngWithText(t)
+	f, _ = media.Read(bytes.NewReader(png), int64(len(png)), policy)
+	renditions, err = media.Variants(f, []media.Variant{{Name: "thumb", MaxSize: 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, format, _ := image.Decode(bytes.NewReader(renditions[0].Data))
+	if format != "png" || img.Bounds().Dx() != 4 {
+		t.Fatalf("expected a 4px png, got %s %v", format, img.Bounds())
+	}
+}
+
+func TestParseVariants(t *testing.T) {
+	got, err := media.ParseVariants("thumb=150, large=1600")
+	if err != nil || len(got) != 2 || got[1] != (media.Variant{Name: "large", MaxSize: 1600}) {
+		t.Fatalf("unexpected %v %v", got, err)
+	}
+	for _, spec := range []string{"thumb", "thumb=0", "../x=10", "original=10"} {
+		if _, err := media.ParseVariants(spec); err == nil {
+			t.Fatalf("expected %q to be rejected", spec)
+		}
+	}
+}
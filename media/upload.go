@@ -0,0 +1,123 @@
+package media
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// ErrTooLarge is returned for uploads above Policy.MaxBytes
+	ErrTooLarge = errors.New("file too large")
+	// ErrUnsupportedType is returned when the sniffed type is not allowed
+	ErrUnsupportedType = errors.New("unsupported file type")
+)
+
+// ImageTypes maps the image types accepted for todos to their extension
+var ImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// AttachmentTypes are the types accepted as todo attachments: images, PDFs,
+// plain text (including CSV) and zip archives (which covers office documents)
+var AttachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+	"application/zip": ".zip",
+}
+
+// Policy restricts what an upload may contain
+type Policy struct {
+	// MaxBytes is the largest accepted upload, 0 disables the check
+	MaxBytes int64
+	// Allowed maps content types, as sniffed from the data, to the extension stored
+	Allowed map[string]string
+}
+
+// File is an upload that passed the policy, with metadata removed
+type File struct {
+	Data        []byte
+	ContentType string
+	Ext         string
+}
+
+// Read validates an upload of declared size against the policy.
+// The type comes from the leading magic bytes, never from the client's
+// filename or Content-Type header.
+func Read(r io.Reader, size int64, policy Policy) (*File, error) {
+	if policy.MaxBytes > 0 && size > policy.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	var data []byte
+	var err error
+	if policy.MaxBytes > 0 {
+		// the declared size can lie, never read more than the limit
+		data, err = io.ReadAll(io.LimitReader(r, policy.MaxBytes+1))
+		if err == nil && int64(len(data)) > policy.MaxBytes {
+			return nil, ErrTooLarge
+		}
+	} else {
+		data, err = io.ReadAll(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	ext, ok := policy.Allowed[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, contentType)
+	}
+
+	data, err = StripMetadata(contentType, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	return &File{Data: data, ContentType: contentType, Ext: ext}, nil
+}
+
+// CleanFilename makes a client supplied file name safe to store and echo back:
+// directories and control characters are dropped and it is capped at 255 bytes
+func CleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "file"
+	}
+	return name
+}
+
+// RandomKey returns a collision free storage key such as "todos/3f2a...9c.png"
+func RandomKey(prefix, ext string) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand never fails on supported platforms
+	}
+	return prefix + "/" + hex.EncodeToString(b) + ext
+}
@@ -0,0 +1,171 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"regexp"
+	"strconv"
+	"strings"
+
+	// decoders for image.Decode
+	_ "image/gif"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels bounds the size of images that get decoded,
+// a small file can declare huge dimensions
+const MaxPixels = 40_000_000
+
+// Variant is a named resized copy that fits in a MaxSize x MaxSize box
+type Variant struct {
+	Name    string
+	MaxSize int
+}
+
+// DefaultVariants are generated when IMAGE_VARIANTS is not set
+var DefaultVariants = []Variant{{Name: "thumb", MaxSize: 200}, {Name: "medium", MaxSize: 800}}
+
+var variantName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// ParseVariants reads a spec such as "thumb=200,medium=800"
+func ParseVariants(spec string) ([]Variant, error) {
+	var variants []Variant
+	for _, part := range strings.Split(spec, ",") {
+		name, size, ok := strings.Cut(strings.TrimSpace(part), "=")
+		n, err := strconv.Atoi(size)
+		if !ok || err != nil || n <= 0 || !variantName.MatchString(name) || name == "original" {
+			return nil, fmt.Errorf("invalid image variant %q, expected name=size", part)
+		}
+		variants = append(variants, Variant{Name: name, MaxSize: n})
+	}
+	return variants, nil
+}
+
+// Rendition is an encoded variant
+type Rendition struct {
+	Name        string
+	Data        []byte
+	ContentType string
+	Ext         string
+}
+
+// Variants resizes f to every variant without upscaling. JPEGs stay JPEG,
+// everything else becomes PNG to keep transparency. Orientation from the
+// EXIF data is applied so variants are upright without metadata.
+func Variants(f *File, variants []Variant) ([]Rendition, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(f.Data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(f.Data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedType, err)
+	}
+
+	orientation := uint16(1)
+	if f.ContentType == "image/jpeg" {
+		orientation = jpegOrientation(f.Data)
+	}
+
+	renditions := make([]Rendition, 0, len(variants))
+	for _, v := range variants {
+		w, h := fit(src.Bounds().Dx(), src.Bounds().Dy(), v.MaxSize)
+		dst := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+		img := orient(dst, orientation)
+
+		var buf bytes.Buffer
+		r := Rendition{Name: v.Name}
+		if f.ContentType == "image/jpeg" {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+			r.ContentType, r.Ext = "image/jpeg", ".jpg"
+		} else {
+			err = png.Encode(&buf, img)
+			r.ContentType, r.Ext = "image/png", ".png"
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.Data = buf.Bytes()
+		renditions = append(renditions, r)
+	}
+	return renditions, nil
+}
+
+// fit scales w x h down to fit in a box, keeping the aspect ratio
+func fit(w, h, box int) (int, int) {
+	if w <= box && h <= box {
+		return w, h
+	}
+	if w >= h {
+		return box, max(1, h*box/w)
+	}
+	return max(1, w*box/h), box
+}
+
+// orient applies an EXIF orientation (1-8) to img
+func orient(img *image.RGBA, orientation uint16) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // upside down
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored upside down
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotate 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate 90 counter clockwise
+				dx, dy = y, w-1-x
+			}
+			dst.SetRGBA(dx, dy, img.RGBAAt(x, y))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation finds the EXIF orientation of JPEG data, 1 if there is none
+func jpegOrientation(data []byte) uint16 {
+	i := 2
+	for i+4 <= len(data) && data[i] == 0xFF {
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			break
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			break
+		}
+		if marker == 0xE1 {
+			if o := exifOrientation(data[i+4 : end]); o > 0 {
+				return o
+			}
+		}
+		i = end
+	}
+	return 1
+}
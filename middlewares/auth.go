@@ -1,22 +1,25 @@
 package middlewares
 
 import (
+	"context"
+	"errors"
 	"os"
 	"time"
 
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// ensures auth token is available
-func AuthRequired() fiber.Handler {
+// ensures auth token is available and its session has not been revoked
+func AuthRequired(sessions repositories.SessionRepository) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Get the token from the Authorization header
 		tokenString := c.Get("Authorization")
 		if tokenString == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Missing or malformed JWT",
-			})
+			return apperrors.Unauthorized("Missing or malformed JWT")
 		}
 
 		// Remove "Bearer " prefix if present
@@ -27,15 +30,16 @@ func AuthRequired() fiber.Handler {
 		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
 			// Validate signing method
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fiber.NewError(fiber.StatusUnauthorized, "Invalid signing method")
+				return nil, errors.New("invalid signing method")
 			}
 			return []byte(os.Getenv("JWT_SECRET")), nil
 		})
 
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": err.Error(),
-			})
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				return apperrors.Unauthorized("Token expired").WithCode(apperrors.CodeTokenExpired)
+			}
+			return apperrors.Unauthorized("Invalid token").WithCode(apperrors.CodeInvalidToken).Wrap(err)
 		}
 
 		// Validate claims
@@ -43,22 +47,43 @@ func AuthRequired() fiber.Handler {
 			// Check expiration
 			if exp, ok := claims["exp"].(float64); ok {
 				if int64(exp) < time.Now().Unix() {
-					return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-						"error": "Token expired",
-					})
+					return apperrors.Unauthorized("Token expired").WithCode(apperrors.CodeTokenExpired)
+				}
+			}
+
+			// Check the session backing this token is still active
+			sid, _ := claims["sid"].(string)
+			sessionID, err := primitive.ObjectIDFromHex(sid)
+			if err != nil {
+				return apperrors.Unauthorized("Invalid token claims").WithCode(apperrors.CodeInvalidToken)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			session, err := sessions.FindByID(ctx, sessionID)
+			if err != nil {
+				if errors.Is(err, repositories.ErrNotFound) {
+					return apperrors.Unauthorized("Session not found").WithCode(apperrors.CodeSessionRevoked)
 				}
+				return apperrors.Internal(err)
+			}
+			if session.Revoked {
+				return apperrors.Unauthorized("Session has been revoked").WithCode(apperrors.CodeSessionRevoked)
 			}
 
 			// Save userId in context for later use
 			if userID, ok := claims["user_id"].(string); ok {
 				c.Locals("user_id", userID)
 			}
+			if role, ok := claims["role"].(string); ok {
+				c.Locals("role", role)
+			}
+			c.Locals("session_id", sid)
 
 			return c.Next()
 		}
 
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid token claims",
-		})
+		return apperrors.Unauthorized("Invalid token claims").WithCode(apperrors.CodeInvalidToken)
 	}
 }
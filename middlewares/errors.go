@@ -0,0 +1,77 @@
+package middlewares
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/gofiber/fiber/v2"
+)
+
+// MIMEProblemJSON is the RFC 7807 content type
+const MIMEProblemJSON = "application/problem+json"
+
+// ErrorHandler renders every error returned by a handler as problem+json.
+// Use it as fiber.Config.ErrorHandler.
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var appErr *apperrors.Error
+	if !errors.As(err, &appErr) {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			appErr = apperrors.New(fiberErr.Code, codeForStatus(fiberErr.Code), fiberErr.Message)
+		} else {
+			appErr = apperrors.Internal(err)
+		}
+	}
+
+	requestID, _ := c.Locals("requestid").(string)
+	if appErr.Err != nil {
+		log.Printf("request %s: %s %s: %v", requestID, c.Method(), c.Path(), appErr.Err)
+	}
+
+	problem := fiber.Map{
+		"type":       "/problems/" + strings.ReplaceAll(appErr.Code, "_", "-"),
+		"title":      http.StatusText(appErr.Status),
+		"status":     appErr.Status,
+		"detail":     appErr.Detail,
+		"instance":   c.OriginalURL(),
+		"code":       appErr.Code,
+		"request_id": requestID,
+	}
+	if len(appErr.Fields) > 0 {
+		problem["errors"] = appErr.Fields
+	}
+
+	return c.Status(appErr.Status).JSON(problem, MIMEProblemJSON)
+}
+
+func codeForStatus(status int) string {
+	switch status {
+	case fiber.StatusBadRequest:
+		return apperrors.CodeBadRequest
+	case fiber.StatusUnauthorized:
+		return apperrors.CodeUnauthorized
+	case fiber.StatusForbidden:
+		return apperrors.CodeForbidden
+	case fiber.StatusNotFound:
+		return apperrors.CodeNotFound
+	case fiber.StatusConflict:
+		return apperrors.CodeConflict
+	case fiber.StatusUnprocessableEntity:
+		return apperrors.CodeValidation
+	case fiber.StatusRequestEntityTooLarge:
+		return apperrors.CodeTooLarge
+	case fiber.StatusUnsupportedMediaType:
+		return apperrors.CodeUnsupportedMedia
+	case fiber.StatusRequestedRangeNotSatisfiable:
+		return apperrors.CodeRangeNotSatisfied
+	case fiber.StatusTooManyRequests:
+		return apperrors.CodeTooManyRequests
+	}
+	if status >= 500 {
+		return apperrors.CodeInternal
+	}
+	return strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
@@ -2,47 +2,116 @@ package middlewares
 
 import (
 	"context"
-	"net/http"
+	"errors"
 	"time"
 
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
 	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
-// EnsureTodoOwner ensures that only the user who created the todo can update/delete it
-func EnsureTodoOwner(todoCollection *mongo.Collection) fiber.Handler {
+// denied is the error for a user lacking access, resource is "todo" or "list"
+func denied(access models.Access, resource string) error {
+	if access == models.AccessView {
+		return apperrors.Forbidden("You are not allowed to access this " + resource)
+	}
+	return apperrors.Forbidden("You are not allowed to modify this " + resource)
+}
+
+// EnsureTodoAccess lets the user through when they may use the :id todo at
+// the given level, as its owner or as a collaborator on the todo or its list.
+// Users allowed to read or write every todo may always view or edit it.
+// The granted models.Access is stored in the "access" local.
+func EnsureTodoAccess(todos repositories.TodoRepository, lists repositories.ListRepository, access models.Access) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Get todo ID from URL
-		todoIDParam := c.Params("id")
-		todoID, err := primitive.ObjectIDFromHex(todoIDParam)
+		todoID, err := primitive.ObjectIDFromHex(c.Params("id"))
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid todo ID"})
+			return apperrors.BadRequest("Invalid todo ID")
 		}
 
 		// Get user ID from context (set by AuthRequired middleware)
-		userIDStr := c.Locals("user_id").(string)
+		userIDStr, _ := c.Locals("user_id").(string)
 		userID, _ := primitive.ObjectIDFromHex(userIDStr)
 
 		// Find the todo
-		var todo models.Todo
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 
-		err = todoCollection.FindOne(ctx, primitive.M{"_id": todoID}).Decode(&todo)
+		todo, err := todos.FindByID(ctx, todoID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrNotFound) {
+				return apperrors.NotFound("Todo not found")
+			}
+			return apperrors.Internal(err)
+		}
+
+		// the list only matters when the todo alone doesn't grant enough
+		granted := todo.Access(userID, nil)
+		if granted < access && todo.ListID != nil {
+			list, err := lists.FindByID(ctx, *todo.ListID)
+			if err != nil && !errors.Is(err, repositories.ErrNotFound) {
+				return apperrors.Internal(err)
+			}
+			if err == nil {
+				granted = todo.Access(userID, &list)
+			}
+		}
+
+		if granted < access && !anyTodo(c, access) {
+			return denied(access, "todo")
+		}
+
+		c.Locals("access", granted)
+		return c.Next()
+	}
+}
+
+// anyTodo reports whether the user's role grants access to every todo
+func anyTodo(c *fiber.Ctx, access models.Access) bool {
+	role, _ := c.Locals("role").(string)
+	switch access {
+	case models.AccessView:
+		return config.HasPermission(role, config.PermTodosReadAll)
+	case models.AccessEdit:
+		return config.HasPermission(role, config.PermTodosWriteAll)
+	}
+	return false
+}
+
+// EnsureListAccess lets the user through when they may use the :id list at
+// the given level, as its owner or as a collaborator.
+// The granted models.Access is stored in the "access" local.
+func EnsureListAccess(lists repositories.ListRepository, access models.Access) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		listID, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return apperrors.BadRequest("Invalid list ID")
+		}
+
+		userIDStr, _ := c.Locals("user_id").(string)
+		userID, _ := primitive.ObjectIDFromHex(userIDStr)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		list, err := lists.FindByID(ctx, listID)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Todo not found"})
+			if errors.Is(err, repositories.ErrNotFound) {
+				return apperrors.NotFound("List not found")
 			}
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+			return apperrors.Internal(err)
 		}
 
-		// Check ownership
-		if todo.UserID != userID {
-			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "You are not allowed to modify this todo"})
+		granted := list.Access(userID)
+		if granted < access {
+			return denied(access, "list")
 		}
 
+		c.Locals("access", granted)
 		return c.Next()
 	}
 }
@@ -0,0 +1,38 @@
+package middlewares
+
+import (
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequirePermission allows the request only if the user's role grants all the permissions.
+// Must run after AuthRequired.
+func RequirePermission(permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals("role").(string)
+		for _, p := range permissions {
+			if !config.HasPermission(role, p) {
+				return apperrors.Forbidden("Missing permission: " + p)
+			}
+		}
+		return c.Next()
+	}
+}
+
+// RequireSelfOrPermission allows users to act on their own account (route param)
+// while anyone else needs the permission.
+// Must run after AuthRequired.
+func RequireSelfOrPermission(param, permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(string)
+		if userID != "" && userID == c.Params(param) {
+			return c.Next()
+		}
+		role, _ := c.Locals("role").(string)
+		if config.HasPermission(role, permission) {
+			return c.Next()
+		}
+		return apperrors.Forbidden("You are not allowed to access this resource")
+	}
+}
@@ -0,0 +1,65 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/rank"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// BackfillRanks ranks the todos stored before ranks existed, in creation
+// order within each list and each user's inbox. Ranked todos are left alone
+// so it is safe to rerun.
+func BackfillRanks(ctx context.Context, db *mongo.Database) (int64, error) {
+	todos := db.Collection("todos")
+	unranked := bson.M{"$or": bson.A{bson.M{"rank": bson.M{"$exists": false}}, bson.M{"rank": ""}}}
+	cursor, err := todos.Find(ctx, unranked, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"userId": 1, "listId": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var docs []struct {
+		ID     primitive.ObjectID  `bson:"_id"`
+		UserID primitive.ObjectID  `bson:"userId"`
+		ListID *primitive.ObjectID `bson:"listId"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return 0, err
+	}
+
+	// a list's todos share a scope whoever owns them, inbox todos are per user
+	scopes := map[primitive.ObjectID][]primitive.ObjectID{}
+	var order []primitive.ObjectID
+	for _, doc := range docs {
+		key := doc.UserID
+		if doc.ListID != nil && !doc.ListID.IsZero() {
+			key = *doc.ListID
+		}
+		if _, ok := scopes[key]; !ok {
+			order = append(order, key)
+		}
+		scopes[key] = append(scopes[key], doc.ID)
+	}
+
+	var writes []mongo.WriteModel
+	for _, key := range order {
+		ids := scopes[key]
+		for i, r := range rank.Spread(len(ids)) {
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": ids[i], "$or": unranked["$or"]}).
+				SetUpdate(bson.M{"$set": bson.M{"rank": r}}))
+		}
+	}
+	if len(writes) == 0 {
+		return 0, nil
+	}
+	result, err := todos.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
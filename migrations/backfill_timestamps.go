@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// BackfillTimestamps sets createdAt/updatedAt on users and todos stored before
+// the audit fields existed, using the creation time embedded in their id.
+// Documents that already have createdAt are left alone so it is safe to rerun.
+func BackfillTimestamps(ctx context.Context, db *mongo.Database) (int64, error) {
+	var total int64
+	for _, name := range []string{"users", "todos"} {
+		n, err := backfillCollection(ctx, db.Collection(name))
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+func backfillCollection(ctx context.Context, collection *mongo.Collection) (int64, error) {
+	// ids are read back in Go rather than with $toDate: ObjectIDs from the
+	// v1 bson package are stored as binary, which the server can't convert
+	cursor, err := collection.Find(ctx,
+		bson.M{"createdAt": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"_id": 1}),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var updated int64
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return updated, err
+		}
+
+		created := doc.ID.Timestamp().UTC()
+		if doc.ID.IsZero() {
+			created = time.Now().UTC()
+		}
+		result, err := collection.UpdateOne(ctx,
+			bson.M{"_id": doc.ID, "createdAt": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"createdAt": created, "updatedAt": created}},
+		)
+		if err != nil {
+			return updated, err
+		}
+		updated += result.ModifiedCount
+	}
+	return updated, cursor.Err()
+}
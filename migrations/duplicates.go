@@ -0,0 +1,266 @@
+package migrations
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// DuplicateUser is one account of a DuplicateGroup
+type DuplicateUser struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Username  string             `bson:"username"`
+	Email     string             `bson:"email"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+// DuplicateGroup lists the accounts sharing Field once normalized, oldest first
+type DuplicateGroup struct {
+	Field string
+	Key   string
+	Users []DuplicateUser
+}
+
+// FindDuplicateUsers reports accounts that collide on their normalized
+// email or username and would break the unique indexes
+func FindDuplicateUsers(ctx context.Context, db *mongo.Database) ([]DuplicateGroup, error) {
+	cursor, err := db.Collection("users").Find(ctx, bson.M{},
+		options.Find().SetProjection(bson.M{"username": 1, "email": 1, "createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var users []DuplicateUser
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	groups := group(users, "email", utils.NormalizeEmail)
+	groups = append(groups, group(users, "username", utils.NormalizeUsername)...)
+	return groups, nil
+}
+
+func group(users []DuplicateUser, field string, normalize func(string) string) []DuplicateGroup {
+	byKey := map[string][]DuplicateUser{}
+	for _, u := range users {
+		value := u.Email
+		if field == "username" {
+			value = u.Username
+		}
+		key := normalize(value)
+		byKey[key] = append(byKey[key], u)
+	}
+
+	groups := []DuplicateGroup{}
+	for key, members := range byKey {
+		if len(members) < 2 {
+			continue
+		}
+		slices.SortFunc(members, func(a, b DuplicateUser) int {
+			if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+				return c
+			}
+			return slices.Compare(a.ID[:], b.ID[:])
+		})
+		groups = append(groups, DuplicateGroup{Field: field, Key: key, Users: members})
+	}
+	slices.SortFunc(groups, func(a, b DuplicateGroup) int { return cmp.Compare(a.Key, b.Key) })
+	return groups
+}
+
+// ResolveDuplicateUsers fixes the duplicates reported by FindDuplicateUsers.
+// Accounts sharing an email are merged into the oldest one, see mergeSteps.
+// Accounts sharing a username keep the oldest as is and get a numeric suffix.
+// Every action is passed to logf.
+func ResolveDuplicateUsers(ctx context.Context, db *mongo.Database, logf func(format string, args ...any)) error {
+	users := db.Collection("users")
+
+	groups, err := FindDuplicateUsers(ctx, db)
+	if err != nil {
+		return err
+	}
+	for _, g := range groups {
+		if g.Field != "email" {
+			continue
+		}
+		keep := g.Users[0]
+		for _, dup := range g.Users[1:] {
+			var moved int64
+			for _, step := range mergeSteps(dup.ID, keep.ID) {
+				n, err := step.apply(ctx, db)
+				if err != nil {
+					return fmt.Errorf("merging user %s into %s: %s: %w", dup.ID.Hex(), keep.ID.Hex(), step.collection, err)
+				}
+				if step.collection == "todos" && step.filter["userId"] == dup.ID {
+					moved = n
+				}
+			}
+			logf("merged user %s (%s) into %s (%s), moved %d todos",
+				dup.ID.Hex(), dup.Email, keep.ID.Hex(), keep.Email, moved)
+		}
+	}
+
+	// merging may have solved some username clashes, look again
+	groups, err = FindDuplicateUsers(ctx, db)
+	if err != nil {
+		return err
+	}
+	taken := map[string]bool{}
+	var names []string
+	if err := users.Distinct(ctx, "username", bson.M{}).Decode(&names); err != nil {
+		return err
+	}
+	for _, name := range names {
+		taken[utils.NormalizeUsername(name)] = true
+	}
+
+	for _, g := range groups {
+		if g.Field != "username" {
+			continue
+		}
+		for _, dup := range g.Users[1:] {
+			renamed := dup.Username
+			for n := 2; taken[utils.NormalizeUsername(renamed)]; n++ {
+				renamed = fmt.Sprintf("%s-%d", dup.Username, n)
+			}
+			taken[utils.NormalizeUsername(renamed)] = true
+
+			_, err := users.UpdateOne(ctx, bson.M{"_id": dup.ID}, bson.M{"$set": bson.M{
+				"username":    renamed,
+				"usernameKey": utils.NormalizeUsername(renamed),
+				"updatedAt":   time.Now().UTC(),
+			}})
+			if err != nil {
+				return err
+			}
+			logf("renamed user %s from %q to %q", dup.ID.Hex(), dup.Username, renamed)
+		}
+	}
+	return nil
+}
+
+// mergeStep updates, deletes or aggregates the documents of a collection
+// matching filter
+type mergeStep struct {
+	collection string
+	filter     bson.M
+	// update is applied to every match, nil deletes them
+	update bson.M
+	// pipeline runs after matching the filter, instead of an update
+	pipeline []bson.M
+}
+
+func (s mergeStep) apply(ctx context.Context, db *mongo.Database) (int64, error) {
+	collection := db.Collection(s.collection)
+	switch {
+	case s.pipeline != nil:
+		cursor, err := collection.Aggregate(ctx, append([]bson.M{{"$match": s.filter}}, s.pipeline...))
+		if err != nil {
+			return 0, err
+		}
+		return 0, cursor.Close(ctx)
+	case s.update == nil:
+		result, err := collection.DeleteMany(ctx, s.filter)
+		if err != nil {
+			return 0, err
+		}
+		return result.DeletedCount, nil
+	}
+	result, err := collection.UpdateMany(ctx, s.filter, s.update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// mergeSteps moves everything of the dup account over to keep, in order:
+// its todos and lists, its shares (where keep already has access, keep's
+// own role stays), invitations, reminders, attachments and storage usage.
+// Pending invitations to dup are dropped, they could clash with keep's.
+// Its sessions and the account itself are deleted last.
+func mergeSteps(dup, keep primitive.ObjectID) []mergeStep {
+	steps := []mergeStep{
+		{collection: "todos", filter: bson.M{"userId": dup}, update: bson.M{"$set": bson.M{"userId": keep}}},
+		{collection: "lists", filter: bson.M{"userId": dup}, update: bson.M{"$set": bson.M{"userId": keep}}},
+	}
+	for _, collection := range []string{"todos", "lists"} {
+		steps = append(steps,
+			// owners aren't collaborators on their own todos and lists
+			mergeStep{
+				collection: collection,
+				filter:     bson.M{"userId": keep, "collaborators.userId": bson.M{"$in": bson.A{dup, keep}}},
+				update:     bson.M{"$pull": bson.M{"collaborators": bson.M{"userId": bson.M{"$in": bson.A{dup, keep}}}}},
+			},
+			mergeStep{
+				collection: collection,
+				filter:     bson.M{"collaborators.userId": bson.M{"$all": bson.A{dup, keep}}},
+				update:     bson.M{"$pull": bson.M{"collaborators": bson.M{"userId": dup}}},
+			},
+			mergeStep{
+				collection: collection,
+				filter:     bson.M{"collaborators.userId": dup},
+				update:     bson.M{"$set": bson.M{"collaborators.$.userId": keep}},
+			},
+		)
+	}
+	return append(steps,
+		mergeStep{collection: "invitations", filter: bson.M{"userId": dup, "status": "pending"}},
+		mergeStep{collection: "invitations", filter: bson.M{"userId": dup}, update: bson.M{"$set": bson.M{"userId": keep}}},
+		mergeStep{collection: "invitations", filter: bson.M{"invitedBy": dup}, update: bson.M{"$set": bson.M{"invitedBy": keep}}},
+		mergeStep{collection: "reminders", filter: bson.M{"userId": dup}, update: bson.M{"$set": bson.M{"userId": keep}}},
+		mergeStep{collection: "attachments", filter: bson.M{"uploadedBy": dup}, update: bson.M{"$set": bson.M{"uploadedBy": keep}}},
+		// the attachments count towards keep's quota from now on
+		mergeStep{collection: "storage_usage", filter: bson.M{"_id": dup}, pipeline: []bson.M{
+			{"$project": bson.M{"_id": bson.M{"$literal": keep}, "bytes": 1}},
+			{"$merge": bson.M{
+				"into": "storage_usage", "on": "_id",
+				"whenMatched":    bson.A{bson.M{"$set": bson.M{"bytes": bson.M{"$add": bson.A{"$bytes", "$$new.bytes"}}}}},
+				"whenNotMatched": "insert",
+			}},
+		}},
+		mergeStep{collection: "storage_usage", filter: bson.M{"_id": dup}},
+		mergeStep{collection: "sessions", filter: bson.M{"userId": dup}},
+		mergeStep{collection: "users", filter: bson.M{"_id": dup}},
+	)
+}
+
+// normalizeUsers stores every email normalized and sets usernameKey,
+// it refuses to run while duplicates would make the unique indexes fail
+func normalizeUsers(ctx context.Context, db *mongo.Database) error {
+	groups, err := FindDuplicateUsers(ctx, db)
+	if err != nil {
+		return err
+	}
+	if len(groups) > 0 {
+		return fmt.Errorf("%d groups of users share an email or username, run `dedupe-users` to review and resolve them", len(groups))
+	}
+
+	users := db.Collection("users")
+	cursor, err := users.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"username": 1, "email": 1}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var u DuplicateUser
+		if err := cursor.Decode(&u); err != nil {
+			return err
+		}
+		_, err := users.UpdateOne(ctx, bson.M{"_id": u.ID}, bson.M{"$set": bson.M{
+			"email":       utils.NormalizeEmail(u.Email),
+			"usernameKey": utils.NormalizeUsername(u.Username),
+		}})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
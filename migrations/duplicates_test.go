@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// refersTo reports whether the filter selects documents holding id in field
+func refersTo(filter bson.M, field string, id primitive.ObjectID) bool {
+	switch v := filter[field].(type) {
+	case primitive.ObjectID:
+		return v == id
+	case bson.M:
+		for _, op := range []string{"$in", "$all"} {
+			for _, value := range asArray(v[op]) {
+				if value == id {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func asArray(v any) bson.A {
+	a, _ := v.(bson.A)
+	return a
+}
+
+func TestMergeStepsCoverEveryUserReference(t *testing.T) {
+	dup, keep := primitive.NewObjectID(), primitive.NewObjectID()
+	steps := mergeSteps(dup, keep)
+
+	// every field holding a user id, a merged account must not be left in any
+	refs := []struct{ collection, field string }{
+		{"todos", "userId"},
+		{"todos", "collaborators.userId"},
+		{"lists", "userId"},
+		{"lists", "collaborators.userId"},
+		{"invitations", "userId"},
+		{"invitations", "invitedBy"},
+		{"reminders", "userId"},
+		{"attachments", "uploadedBy"},
+		{"storage_usage", "_id"},
+		{"sessions", "userId"},
+		{"users", "_id"},
+	}
+	for _, ref := range refs {
+		// the last step on the field has to move or delete every match
+		var last *mergeStep
+		for i, step := range steps {
+			if step.collection == ref.collection && refersTo(step.filter, ref.field, dup) {
+				last = &steps[i]
+			}
+		}
+		if last == nil {
+			t.Errorf("%s.%s is not merged", ref.collection, ref.field)
+			continue
+		}
+		if len(last.filter) != 1 || last.filter[ref.field] != dup {
+			t.Errorf("%s.%s: the last step only handles some documents, filter %v", ref.collection, ref.field, last.filter)
+		}
+		if last.update != nil {
+			set, _ := last.update["$set"].(bson.M)
+			moved := false
+			for field, value := range set {
+				moved = moved || (value == keep && (field == ref.field || field == "collaborators.$.userId"))
+			}
+			if !moved {
+				t.Errorf("%s.%s: expected the id replaced by keep, got %v", ref.collection, ref.field, last.update)
+			}
+		}
+	}
+
+	// the account goes last, so a failed merge can be run again
+	if last := steps[len(steps)-1]; last.collection != "users" || last.update != nil {
+		t.Fatalf("expected the user deleted last, got %+v", last)
+	}
+}
@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+type memoryStore struct {
+	mu        sync.Mutex
+	records   []Record
+	owner     string
+	expiresAt time.Time
+}
+
+// NewMemoryStore returns a Store kept in memory, useful for tests
+func NewMemoryStore() Store {
+	return &memoryStore{}
+}
+
+func (s *memoryStore) Applied(ctx context.Context) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := slices.Clone(s.records)
+	slices.SortFunc(records, func(a, b Record) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return records, nil
+}
+
+func (s *memoryStore) Insert(ctx context.Context, record Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = slices.DeleteFunc(s.records, func(r Record) bool { return r.Version == version })
+	return nil
+}
+
+func (s *memoryStore) Lock(ctx context.Context, owner string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.owner != "" && s.owner != owner && now.Before(s.expiresAt) {
+		return ErrLocked
+	}
+	s.owner = owner
+	s.expiresAt = now.Add(ttl)
+	return nil
+}
+
+func (s *memoryStore) Unlock(ctx context.Context, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.owner == owner {
+		s.owner = ""
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	bsonv2 "go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// All returns every migration of the application.
+// Append new migrations with the next version, never edit applied ones.
+func All() []Migration {
+	return []Migration{
+		{
+			Version: 1,
+			Name:    "users_email_unique",
+			Up: createIndex("users", mongo.IndexModel{
+				Keys:    bsonv2.D{{Key: "email", Value: 1}},
+				Options: options.Index().SetName("email_unique").SetUnique(true),
+			}),
+			Down: dropIndex("users", "email_unique"),
+		},
+		{
+			Version: 2,
+			Name:    "todos_user_id",
+			Up: createIndex("todos", mongo.IndexModel{
+				Keys:    bsonv2.D{{Key: "userId", Value: 1}},
+				Options: options.Index().SetName("userId"),
+			}),
+			Down: dropIndex("todos", "userId"),
+		},
+		{
+			Version: 3,
+			Name:    "backfill_timestamps",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := BackfillTimestamps(ctx, db)
+				return err
+			},
+			// the backfilled timestamps are valid data, nothing to undo
+			Down: func(ctx context.Context, db *mongo.Database) error { return nil },
+		},
+		{
+			// emails are stored lower cased so email_unique becomes case-insensitive,
+			// usernames keep their case and are made unique through usernameKey
+			Version: 4,
+			Name:    "users_normalized_unique",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				if err := normalizeUsers(ctx, db); err != nil {
+					return err
+				}
+				return createIndex("users", mongo.IndexModel{
+					Keys:    bsonv2.D{{Key: "usernameKey", Value: 1}},
+					Options: options.Index().SetName("usernameKey_unique").SetUnique(true),
+				})(ctx, db)
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				if err := dropIndex("users", "usernameKey_unique")(ctx, db); err != nil {
+					return err
+				}
+				_, err := db.Collection("users").UpdateMany(ctx, bson.M{}, bson.M{"$unset": bson.M{"usernameKey": ""}})
+				return err
+			},
+		},
+		{
+			// todo images used to be paths under uploads/, they are now keys
+			// relative to the storage root (which defaults to uploads/)
+			Version: 5,
+			Name:    "todo_image_keys",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection("todos").UpdateMany(ctx,
+					bson.M{"image": bson.M{"$regex": "^uploads/"}},
+					[]bson.M{{"$set": bson.M{"image": bson.M{"$substrCP": bson.A{"$image", 8, bson.M{"$strLenCP": "$image"}}}}}},
+				)
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection("todos").UpdateMany(ctx,
+					bson.M{"image": bson.M{"$nin": bson.A{"", nil}, "$not": bson.M{"$regex": "^todos/"}}},
+					[]bson.M{{"$set": bson.M{"image": bson.M{"$concat": bson.A{"uploads/", "$image"}}}}},
+				)
+				return err
+			},
+		},
+		{
+			Version: 6,
+			Name:    "attachments_indexes",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection("attachments").Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{Keys: bsonv2.D{{Key: "todoId", Value: 1}}, Options: options.Index().SetName("todoId")},
+					{Keys: bsonv2.D{{Key: "key", Value: 1}}, Options: options.Index().SetName("key_unique").SetUnique(true)},
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				if err := dropIndex("attachments", "todoId")(ctx, db); err != nil {
+					return err
+				}
+				return dropIndex("attachments", "key_unique")(ctx, db)
+			},
+		},
+		{
+			Version: 7,
+			Name:    "todos_details_indexes",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection("todos").Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{Keys: bsonv2.D{{Key: "userId", Value: 1}, {Key: "dueAt", Value: 1}}, Options: options.Index().SetName("userId_dueAt")},
+					{Keys: bsonv2.D{{Key: "userId", Value: 1}, {Key: "priority", Value: -1}}, Options: options.Index().SetName("userId_priority")},
+					{Keys: bsonv2.D{{Key: "userId", Value: 1}, {Key: "tags", Value: 1}}, Options: options.Index().SetName("userId_tags")},
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				for _, name := range []string{"userId_dueAt", "userId_priority", "userId_tags"} {
+					if err := dropIndex("todos", name)(ctx, db); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Version: 8,
+			Name:    "todos_text_index",
+			// full-text search over title and description, a collection has at most one
+			Up: createIndex("todos", mongo.IndexModel{
+				Keys:    bsonv2.D{{Key: "title", Value: "text"}, {Key: "description", Value: "text"}},
+				Options: options.Index().SetName("title_description_text").SetWeights(bsonv2.D{{Key: "title", Value: 3}, {Key: "description", Value: 1}}),
+			}),
+			Down: dropIndex("todos", "title_description_text"),
+		},
+		{
+			Version: 9,
+			Name:    "lists_indexes",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				if err := createIndex("lists", mongo.IndexModel{
+					Keys:    bsonv2.D{{Key: "userId", Value: 1}, {Key: "position", Value: 1}},
+					Options: options.Index().SetName("userId_position"),
+				})(ctx, db); err != nil {
+					return err
+				}
+				return createIndex("todos", mongo.IndexModel{
+					Keys:    bsonv2.D{{Key: "listId", Value: 1}},
+					Options: options.Index().SetName("listId"),
+				})(ctx, db)
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				if err := dropIndex("todos", "listId")(ctx, db); err != nil {
+					return err
+				}
+				return dropIndex("lists", "userId_position")(ctx, db)
+			},
+		},
+		{
+			Version: 10,
+			Name:    "sharing_indexes",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				for _, collection := range []string{"todos", "lists"} {
+					if err := createIndex(collection, mongo.IndexModel{
+						Keys:    bsonv2.D{{Key: "collaborators.userId", Value: 1}},
+						Options: options.Index().SetName("collaborators_userId"),
+					})(ctx, db); err != nil {
+						return err
+					}
+				}
+				_, err := db.Collection("invitations").Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{
+						// one pending invitation per user and resource
+						Keys: bsonv2.D{{Key: "resourceId", Value: 1}, {Key: "userId", Value: 1}},
+						Options: options.Index().SetName("resourceId_userId_pending").SetUnique(true).
+							SetPartialFilterExpression(bsonv2.D{{Key: "status", Value: "pending"}}),
+					},
+					{Keys: bsonv2.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}}, Options: options.Index().SetName("userId_createdAt")},
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				for _, collection := range []string{"todos", "lists"} {
+					if err := dropIndex(collection, "collaborators_userId")(ctx, db); err != nil {
+						return err
+					}
+				}
+				if err := dropIndex("invitations", "userId_createdAt")(ctx, db); err != nil {
+					return err
+				}
+				return dropIndex("invitations", "resourceId_userId_pending")(ctx, db)
+			},
+		},
+		{
+			Version: 11,
+			Name:    "reminders_indexes",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				_, err := db.Collection("reminders").Indexes().CreateMany(ctx, []mongo.IndexModel{
+					// the scheduler leases the earliest due pending reminder
+					{Keys: bsonv2.D{{Key: "status", Value: 1}, {Key: "fireAt", Value: 1}}, Options: options.Index().SetName("status_fireAt")},
+					{Keys: bsonv2.D{{Key: "todoId", Value: 1}, {Key: "userId", Value: 1}}, Options: options.Index().SetName("todoId_userId")},
+				})
+				return err
+			},
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				if err := dropIndex("reminders", "todoId_userId")(ctx, db); err != nil {
+					return err
+				}
+				return dropIndex("reminders", "status_fireAt")(ctx, db)
+			},
+		},
+		{
+			// todos are ordered by rank within a list or a user's inbox
+			Version: 12,
+			Name:    "todo_ranks",
+			Up: func(ctx context.Context, db *mongo.Database) error {
+				if _, err := BackfillRanks(ctx, db); err != nil {
+					return err
+				}
+				_, err := db.Collection("todos").Indexes().CreateMany(ctx, []mongo.IndexModel{
+					{Keys: bsonv2.D{{Key: "listId", Value: 1}, {Key: "rank", Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetName("listId_rank")},
+					{Keys: bsonv2.D{{Key: "userId", Value: 1}, {Key: "listId", Value: 1}, {Key: "rank", Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetName("userId_listId_rank")},
+				})
+				return err
+			},
+			// the ranks are valid data, only the indexes go
+			Down: func(ctx context.Context, db *mongo.Database) error {
+				if err := dropIndex("todos", "userId_listId_rank")(ctx, db); err != nil {
+					return err
+				}
+				return dropIndex("todos", "listId_rank")(ctx, db)
+			},
+		},
+	}
+}
+
+func createIndex(collection string, index mongo.IndexModel) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection(collection).Indexes().CreateOne(ctx, index)
+		return err
+	}
+}
+
+func dropIndex(collection, name string) func(context.Context, *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		return db.Collection(collection).Indexes().DropOne(ctx, name)
+	}
+}
@@ -0,0 +1,205 @@
+package migrations
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// ErrLocked is returned when another process holds the migration lock
+var ErrLocked = errors.New("migrations are locked by another process")
+
+// Migration is a versioned, reversible schema change.
+// Versions are applied in ascending order and must never be reused.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// Record marks a migration as applied, stored in schema_migrations
+type Record struct {
+	Version   int64     `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"appliedAt"`
+}
+
+// Status reports whether a known migration has been applied
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Store persists applied migrations and the migration lock
+type Store interface {
+	Applied(ctx context.Context) ([]Record, error)
+	Insert(ctx context.Context, record Record) error
+	Delete(ctx context.Context, version int64) error
+	// Lock takes or extends the lock for owner, returns ErrLocked while
+	// someone else holds an unexpired lock
+	Lock(ctx context.Context, owner string, ttl time.Duration) error
+	Unlock(ctx context.Context, owner string) error
+}
+
+// Migrator applies and rolls back migrations
+type Migrator struct {
+	db         *mongo.Database
+	store      Store
+	migrations []Migration
+	owner      string
+	// LockTTL bounds how long a crashed process can block others,
+	// the lock is extended after every migration
+	LockTTL time.Duration
+}
+
+// New returns a Migrator for every registered migration, tracked in db
+func New(db *mongo.Database) *Migrator {
+	return NewWithStore(db, NewMongoStore(db), All())
+}
+
+// NewWithStore returns a Migrator using the given store and migrations
+func NewWithStore(db *mongo.Database, store Store, migrations []Migration) *Migrator {
+	sorted := slices.Clone(migrations)
+	slices.SortFunc(sorted, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	host, _ := os.Hostname()
+	suffix, _ := utils.GenerateRandomToken()
+	return &Migrator{
+		db:         db,
+		store:      store,
+		migrations: sorted,
+		owner:      fmt.Sprintf("%s/%d/%.8s", host, os.Getpid(), suffix),
+		LockTTL:    10 * time.Minute,
+	}
+}
+
+func (m *Migrator) validate() error {
+	for i, mig := range m.migrations {
+		if mig.Version <= 0 || mig.Up == nil {
+			return fmt.Errorf("migration %d %q: version must be positive and Up set", mig.Version, mig.Name)
+		}
+		if i > 0 && m.migrations[i-1].Version == mig.Version {
+			return fmt.Errorf("duplicate migration version %d", mig.Version)
+		}
+	}
+	return nil
+}
+
+// withLock runs fn while holding the migration lock
+func (m *Migrator) withLock(ctx context.Context, fn func() error) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	if err := m.store.Lock(ctx, m.owner, m.LockTTL); err != nil {
+		return err
+	}
+	defer func() {
+		// release even if ctx has been cancelled
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		m.store.Unlock(unlockCtx, m.owner)
+	}()
+	return fn()
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]Record, error) {
+	records, err := m.store.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]Record, len(records))
+	for _, r := range records {
+		applied[r.Version] = r
+	}
+	return applied, nil
+}
+
+// Status lists every known migration in version order
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		r, ok := applied[mig.Version]
+		statuses = append(statuses, Status{Migration: mig, Applied: ok, AppliedAt: r.AppliedAt})
+	}
+	return statuses, nil
+}
+
+// Up applies pending migrations up to and including target, 0 means all.
+// It stops at the first failure, migrations applied before it stay recorded.
+func (m *Migrator) Up(ctx context.Context, target int64) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if target > 0 && mig.Version > target {
+				break
+			}
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if err := mig.Up(ctx, m.db); err != nil {
+				return fmt.Errorf("migration %d %s up: %w", mig.Version, mig.Name, err)
+			}
+			record := Record{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now().UTC()}
+			if err := m.store.Insert(ctx, record); err != nil {
+				return err
+			}
+			done = append(done, mig)
+			if err := m.store.Lock(ctx, m.owner, m.LockTTL); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the latest steps applied migrations, newest first
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func() error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == nil {
+				return fmt.Errorf("migration %d %s cannot be rolled back", mig.Version, mig.Name)
+			}
+			if err := mig.Down(ctx, m.db); err != nil {
+				return fmt.Errorf("migration %d %s down: %w", mig.Version, mig.Name, err)
+			}
+			if err := m.store.Delete(ctx, mig.Version); err != nil {
+				return err
+			}
+			done = append(done, mig)
+			if err := m.store.Lock(ctx, m.owner, m.LockTTL); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return done, err
+}
@@ -0,0 +1,170 @@
+package migrations_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/migrations"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// recorder builds migrations that log their calls instead of touching a database
+type recorder struct {
+	calls []string
+}
+
+func (r *recorder) migration(version int64, name string) migrations.Migration {
+	return migrations.Migration{
+		Version: version,
+		Name:    name,
+		Up: func(context.Context, *mongo.Database) error {
+			r.calls = append(r.calls, "up "+name)
+			return nil
+		},
+		Down: func(context.Context, *mongo.Database) error {
+			r.calls = append(r.calls, "down "+name)
+			return nil
+		},
+	}
+}
+
+func names(ms []migrations.Migration) []string {
+	out := []string{}
+	for _, m := range ms {
+		out = append(out, m.Name)
+	}
+	return out
+}
+
+func TestUpAppliesPendingInOrder(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	store := migrations.NewMemoryStore()
+	// registered out of order on purpose
+	all := []migrations.Migration{rec.migration(2, "b"), rec.migration(1, "a"), rec.migration(3, "c")}
+
+	done, err := migrations.NewWithStore(nil, store, all).Up(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(done); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("expected a, b up to target 2, got %v", got)
+	}
+
+	// a second process only applies what is left
+	done, err = migrations.NewWithStore(nil, store, all).Up(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(done); !slices.Equal(got, []string{"c"}) {
+		t.Fatalf("expected only c, got %v", got)
+	}
+	if want := []string{"up a", "up b", "up c"}; !slices.Equal(rec.calls, want) {
+		t.Fatalf("expected %v, got %v", want, rec.calls)
+	}
+
+	statuses, err := migrations.NewWithStore(nil, store, all).Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if !s.Applied || s.AppliedAt.IsZero() {
+			t.Fatalf("expected %s to be applied, got %+v", s.Name, s)
+		}
+	}
+}
+
+func TestDownRollsBackNewestFirst(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	m := migrations.NewWithStore(nil, migrations.NewMemoryStore(),
+		[]migrations.Migration{rec.migration(1, "a"), rec.migration(2, "b"), rec.migration(3, "c")})
+
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	done, err := m.Down(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(done); !slices.Equal(got, []string{"c", "b"}) {
+		t.Fatalf("expected c then b, got %v", got)
+	}
+
+	statuses, _ := m.Status(ctx)
+	if !statuses[0].Applied || statuses[1].Applied || statuses[2].Applied {
+		t.Fatalf("expected only a to stay applied, got %+v", statuses)
+	}
+}
+
+func TestUpStopsAtFailure(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	failing := rec.migration(2, "broken")
+	failing.Up = func(context.Context, *mongo.Database) error { return errors.New("boom") }
+	m := migrations.NewWithStore(nil, migrations.NewMemoryStore(),
+		[]migrations.Migration{rec.migration(1, "a"), failing, rec.migration(3, "c")})
+
+	done, err := m.Up(ctx, 0)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := names(done); !slices.Equal(got, []string{"a"}) {
+		t.Fatalf("expected only a applied, got %v", got)
+	}
+	statuses, _ := m.Status(ctx)
+	if !statuses[0].Applied || statuses[1].Applied || statuses[2].Applied {
+		t.Fatalf("expected only a recorded, got %+v", statuses)
+	}
+}
+
+func TestLockPreventsConcurrentRuns(t *testing.T) {
+	ctx := context.Background()
+	store := migrations.NewMemoryStore()
+	rec := &recorder{}
+	m := migrations.NewWithStore(nil, store, []migrations.Migration{rec.migration(1, "a")})
+
+	if err := store.Lock(ctx, "other-instance", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx, 0); !errors.Is(err, migrations.ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if len(rec.calls) != 0 {
+		t.Fatalf("expected nothing to run, got %v", rec.calls)
+	}
+
+	// an expired lock is taken over
+	if err := store.Lock(ctx, "other-instance", -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("expected expired lock to be taken over, got %v", err)
+	}
+	// and released afterwards
+	if err := store.Lock(ctx, "other-instance", time.Minute); err != nil {
+		t.Fatalf("expected lock to be released, got %v", err)
+	}
+}
+
+func TestDuplicateVersionsRejected(t *testing.T) {
+	rec := &recorder{}
+	m := migrations.NewWithStore(nil, migrations.NewMemoryStore(),
+		[]migrations.Migration{rec.migration(1, "a"), rec.migration(1, "b")})
+	if _, err := m.Up(context.Background(), 0); err == nil {
+		t.Fatal("expected duplicate versions to be rejected")
+	}
+}
+
+func TestRegisteredMigrationsAreValid(t *testing.T) {
+	seen := map[int64]bool{}
+	for _, m := range migrations.All() {
+		if m.Version <= 0 || m.Up == nil || m.Down == nil || m.Name == "" || seen[m.Version] {
+			t.Fatalf("invalid migration %+v", m)
+		}
+		seen[m.Version] = true
+	}
+}
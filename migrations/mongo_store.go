@@ -0,0 +1,76 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// lockID is the _id of the single lock document
+const lockID = "migrate"
+
+type mongoStore struct {
+	migrations *mongo.Collection
+	locks      *mongo.Collection
+}
+
+// NewMongoStore keeps applied migrations in schema_migrations and the
+// lock in schema_migrations_lock
+func NewMongoStore(db *mongo.Database) Store {
+	return &mongoStore{
+		migrations: db.Collection("schema_migrations"),
+		locks:      db.Collection("schema_migrations_lock"),
+	}
+}
+
+func (s *mongoStore) Applied(ctx context.Context) ([]Record, error) {
+	cursor, err := s.migrations.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	records := []Record{}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (s *mongoStore) Insert(ctx context.Context, record Record) error {
+	_, err := s.migrations.InsertOne(ctx, record)
+	return err
+}
+
+func (s *mongoStore) Delete(ctx context.Context, version int64) error {
+	_, err := s.migrations.DeleteOne(ctx, bson.M{"_id": version})
+	return err
+}
+
+func (s *mongoStore) Lock(ctx context.Context, owner string, ttl time.Duration) error {
+	now := time.Now().UTC()
+	// matches our own lock or an expired one, otherwise the upsert
+	// collides with the existing document on _id
+	filter := bson.M{
+		"_id": lockID,
+		"$or": []bson.M{
+			{"owner": owner},
+			{"expiresAt": bson.M{"$lt": now}},
+		},
+	}
+	update := bson.M{"$set": bson.M{"owner": owner, "lockedAt": now, "expiresAt": now.Add(ttl)}}
+
+	_, err := s.locks.UpdateOne(ctx, filter, update, options.UpdateOne().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrLocked
+	}
+	return err
+}
+
+func (s *mongoStore) Unlock(ctx context.Context, owner string) error {
+	_, err := s.locks.DeleteOne(ctx, bson.M{"_id": lockID, "owner": owner})
+	return err
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Attachment is a file attached to a todo, the content lives in storage under Key
+type Attachment struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TodoID      primitive.ObjectID `bson:"todoId" json:"todoId"`
+	Key         string             `bson:"key" json:"-"`
+	Name        string             `bson:"name" json:"name"` // original file name, cleaned
+	Size        int64              `bson:"size" json:"size"`
+	ContentType string             `bson:"contentType" json:"contentType"` // sniffed from the data
+	Checksum    string             `bson:"checksum" json:"checksum"`       // hex sha256 of the stored bytes
+	UploadedBy  primitive.ObjectID `bson:"uploadedBy" json:"uploadedBy"`
+	UploadedAt  time.Time          `bson:"uploadedAt" json:"uploadedAt"`
+	URL         string             `bson:"-" json:"url,omitempty"` // signed download URL, set on responses
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// List is a project grouping a user's todos, todos without one are in the inbox
+type List struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID        primitive.ObjectID `bson:"userId" json:"userId"`
+	Name          string             `bson:"name" json:"name"`
+	Color         string             `bson:"color,omitempty" json:"color,omitempty"` // #rrggbb
+	Archived      bool               `bson:"archived" json:"archived"`
+	Position      int                `bson:"position" json:"position"` // lists are ordered by ascending position
+	Collaborators []Collaborator     `bson:"collaborators,omitempty" json:"collaborators,omitempty"`
+	Audit         `bson:",inline"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// reminder statuses
+const (
+	ReminderPending   = "pending"
+	ReminderSent      = "sent"
+	ReminderFailed    = "failed"    // gave up after repeated delivery errors
+	ReminderCancelled = "cancelled" // the todo was completed or is no longer accessible
+)
+
+// reminder channels
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Reminder notifies a user about a todo at a set time, or some minutes
+// before the todo is due
+type Reminder struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TodoID        primitive.ObjectID `bson:"todoId" json:"todoId"`
+	UserID        primitive.ObjectID `bson:"userId" json:"userId"` // who is notified
+	Channel       string             `bson:"channel" json:"channel"`
+	At            *time.Time         `bson:"at,omitempty" json:"at,omitempty"`                       // absolute time
+	MinutesBefore *int               `bson:"minutesBefore,omitempty" json:"minutesBefore,omitempty"` // offset from the todo's dueAt
+	// FireAt is when the reminder is due, nil while an offset reminder's todo has no due date
+	FireAt    *time.Time `bson:"fireAt,omitempty" json:"fireAt,omitempty"`
+	Status    string     `bson:"status" json:"status"`
+	Attempts  int        `bson:"attempts" json:"attempts"`
+	LastError string     `bson:"lastError,omitempty" json:"lastError,omitempty"`
+	SentAt    *time.Time `bson:"sentAt,omitempty" json:"sentAt,omitempty"`
+	// the instance sending the reminder holds it until LeaseUntil, a failed
+	// delivery is retried once the lease runs out
+	LeaseOwner string     `bson:"leaseOwner,omitempty" json:"-"`
+	LeaseUntil *time.Time `bson:"leaseUntil,omitempty" json:"-"`
+	CreatedAt  time.Time  `bson:"createdAt" json:"createdAt"`
+}
+
+// FireTime is when the reminder is due for a todo due at dueAt, nil when
+// it depends on a due date the todo doesn't have
+func (r Reminder) FireTime(dueAt *time.Time) *time.Time {
+	if r.At != nil {
+		at := *r.At
+		return &at
+	}
+	if r.MinutesBefore == nil || dueAt == nil {
+		return nil
+	}
+	at := dueAt.Add(-time.Duration(*r.MinutesBefore) * time.Minute)
+	return &at
+}
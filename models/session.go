@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a refresh token family created on login.
+// The refresh token rotates on every use; previously used hashes are kept
+// so a replayed token can be detected and the whole family revoked.
+type Session struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID      primitive.ObjectID `bson:"userId" json:"userId"`
+	RefreshHash string             `bson:"refreshHash" json:"-"`
+	UsedHashes  []string           `bson:"usedHashes" json:"-"`
+	ExpiresAt   time.Time          `bson:"expiresAt" json:"expiresAt"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	LastUsedAt  time.Time          `bson:"lastUsedAt" json:"lastUsedAt"`
+	Revoked     bool               `bson:"revoked" json:"revoked"`
+	RevokedAt   *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
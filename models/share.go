@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// collaborator roles on a shared todo or list
+const (
+	ShareViewer = "viewer"
+	ShareEditor = "editor"
+)
+
+// Access is what a user may do with a todo or list, each level includes the ones below it
+type Access int
+
+const (
+	AccessNone Access = iota
+	AccessView
+	AccessEdit
+	AccessOwner
+)
+
+// Collaborator is a user a todo or list is shared with
+type Collaborator struct {
+	UserID  primitive.ObjectID `bson:"userId" json:"userId"`
+	Role    string             `bson:"role" json:"role"` // ShareViewer or ShareEditor
+	AddedAt time.Time          `bson:"addedAt" json:"addedAt"`
+}
+
+// roleAccess is the access a collaborator role grants
+func roleAccess(role string) Access {
+	switch role {
+	case ShareEditor:
+		return AccessEdit
+	case ShareViewer:
+		return AccessView
+	}
+	return AccessNone
+}
+
+// collaboratorAccess is the access collaborators grants to userID
+func collaboratorAccess(collaborators []Collaborator, userID primitive.ObjectID) Access {
+	for _, c := range collaborators {
+		if c.UserID == userID {
+			return roleAccess(c.Role)
+		}
+	}
+	return AccessNone
+}
+
+// Access is what userID may do with the list
+func (l List) Access(userID primitive.ObjectID) Access {
+	if l.UserID == userID {
+		return AccessOwner
+	}
+	return collaboratorAccess(l.Collaborators, userID)
+}
+
+// Access is what userID may do with the todo, list is the todo's list when
+// it has one: sharing a list shares its todos
+func (t Todo) Access(userID primitive.ObjectID, list *List) Access {
+	if t.UserID == userID {
+		return AccessOwner
+	}
+	access := collaboratorAccess(t.Collaborators, userID)
+	if list != nil {
+		// owning the list doesn't make a collaborator the owner of the todo
+		access = max(access, min(list.Access(userID), AccessEdit))
+	}
+	return access
+}
+
+// invitation statuses
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+)
+
+// shared resources
+const (
+	ResourceTodo = "todo"
+	ResourceList = "list"
+)
+
+// Invitation asks a user to collaborate on a todo or list,
+// accepting it adds them as a collaborator
+type Invitation struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Resource    string             `bson:"resource" json:"resource"` // ResourceTodo or ResourceList
+	ResourceID  primitive.ObjectID `bson:"resourceId" json:"resourceId"`
+	Name        string             `bson:"name" json:"name"` // title or name of the resource when invited
+	UserID      primitive.ObjectID `bson:"userId" json:"userId"`
+	Role        string             `bson:"role" json:"role"`
+	Status      string             `bson:"status" json:"status"`
+	InvitedBy   primitive.ObjectID `bson:"invitedBy" json:"invitedBy"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	RespondedAt *time.Time         `bson:"respondedAt,omitempty" json:"respondedAt,omitempty"`
+}
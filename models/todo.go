@@ -1,11 +1,92 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// priority levels, higher is more urgent
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
 
 type Todo struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
-	Title     string             `bson:"title" json:"title"`
-	Completed bool               `bson:"completed" json:"completed"`
-	Image     string             `bson:"image" json:"image"`
+	ID            primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID        primitive.ObjectID  `bson:"userId" json:"userId"`
+	ListID        *primitive.ObjectID `bson:"listId,omitempty" json:"listId,omitempty"` // nil for the inbox
+	Rank          string              `bson:"rank" json:"rank"`                         // orders the todos of a list or the inbox, see package rank
+	Title         string              `bson:"title" json:"title"`
+	Description   string              `bson:"description,omitempty" json:"description,omitempty"` // Markdown
+	Completed     bool                `bson:"completed" json:"completed"`
+	CompletedAt   *time.Time          `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+	DueAt         *time.Time          `bson:"dueAt,omitempty" json:"dueAt,omitempty"`
+	DueTimezone   string              `bson:"dueTimezone,omitempty" json:"dueTimezone,omitempty"` // IANA name, dueAt is rendered in it
+	Priority      int                 `bson:"priority" json:"priority"`                           // PriorityNone to PriorityHigh
+	Tags          []string            `bson:"tags,omitempty" json:"tags,omitempty"`               // normalized, lower case
+	Items         []ChecklistItem     `bson:"items,omitempty" json:"items,omitempty"`             // in display order
+	Collaborators []Collaborator      `bson:"collaborators,omitempty" json:"collaborators,omitempty"`
+	AutoComplete  bool                `bson:"autoComplete,omitempty" json:"autoComplete"` // completed follows the items
+	Recurrence    *Recurrence         `bson:"recurrence,omitempty" json:"recurrence,omitempty"`
+	Progress      int                 `bson:"-" json:"progress"`                                      // percent of items done, set on responses
+	Image         string              `bson:"image" json:"image"`                                     // storage key of the original
+	ImageVariants map[string]string   `bson:"imageVariants,omitempty" json:"imageVariants,omitempty"` // variant name -> storage key
+	ImageURLs     map[string]string   `bson:"-" json:"imageUrls,omitempty"`                           // signed URLs, set on responses
+	Audit         `bson:",inline"`
+}
+
+// ImageKeys lists the storage keys of the image and its variants
+func (t Todo) ImageKeys() []string {
+	if t.Image == "" {
+		return nil
+	}
+	keys := []string{t.Image}
+	for _, key := range t.ImageVariants {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Recurrence repeats a todo following an iCalendar RRULE, evaluated in the
+// todo's due timezone. Completing an occurrence creates the todo of the next one.
+type Recurrence struct {
+	Rule       string              `bson:"rule" json:"rule"`                                 // RRULE value, e.g. FREQ=WEEKLY;BYDAY=MO,TH
+	Start      time.Time           `bson:"start" json:"start"`                               // due date of the first occurrence (DTSTART)
+	Exceptions []time.Time         `bson:"exceptions,omitempty" json:"exceptions,omitempty"` // skipped occurrences (EXDATE)
+	NextID     *primitive.ObjectID `bson:"nextId,omitempty" json:"nextId,omitempty"`         // the todo of the next occurrence, once created
+}
+
+// ChecklistItem is a step of a todo
+type ChecklistItem struct {
+	ID          primitive.ObjectID `bson:"_id" json:"id"`
+	Title       string             `bson:"title" json:"title"`
+	Completed   bool               `bson:"completed" json:"completed"`
+	CompletedAt *time.Time         `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// ItemProgress is the percentage of completed items, rounded down.
+// Without items it is 100 for a completed todo and 0 otherwise.
+func (t Todo) ItemProgress() int {
+	if len(t.Items) == 0 {
+		if t.Completed {
+			return 100
+		}
+		return 0
+	}
+	done := 0
+	for _, item := range t.Items {
+		if item.Completed {
+			done++
+		}
+	}
+	return done * 100 / len(t.Items)
+}
+
+// ItemsDone reports whether the todo has items and all of them are completed
+func (t Todo) ItemsDone() bool {
+	return len(t.Items) > 0 && t.ItemProgress() == 100
 }
@@ -3,9 +3,11 @@ package models
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
 type User struct {
-	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Username string             `bson:"username" json:"username"`
-	Email    string             `bson:"email" json:"email"`
-	Password string             `bson:"password" json:"password"`
-	Role     string             `bson:"role" json:"role"`
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Username    string             `bson:"username" json:"username"`
+	UsernameKey string             `bson:"usernameKey" json:"-"` // normalized username, unique
+	Email       string             `bson:"email" json:"email"`
+	Password    string             `bson:"password" json:"password"`
+	Role        string             `bson:"role" json:"role"`
+	Audit       `bson:",inline"`
 }
@@ -0,0 +1,26 @@
+// Package notify delivers notifications to users over pluggable channels
+package notify
+
+import "context"
+
+// Recipient is the user a notification is for
+type Recipient struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// Notification is a message for one user. Text is the human readable
+// message, Data the details for machines (webhooks).
+type Notification struct {
+	To      Recipient `json:"to"`
+	Subject string    `json:"subject"`
+	Text    string    `json:"text"`
+	Data    any       `json:"data,omitempty"`
+}
+
+// Notifier sends notifications over one channel. An error means the
+// notification may not have been delivered and can be retried.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
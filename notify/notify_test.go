@@ -0,0 +1,189 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/notify"
+)
+
+// smtpServer is a minimal SMTP server accepting one message per connection
+type smtpServer struct {
+	listener net.Listener
+	messages chan smtpMessage
+	reject   string // reply to RCPT TO, 250 when empty
+}
+
+type smtpMessage struct {
+	from, to string
+	data     string
+}
+
+func newSMTPServer(t *testing.T, reject string) *smtpServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{listener: listener, messages: make(chan smtpMessage, 10), reject: reject}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	var msg smtpMessage
+	reply("220 localhost ESMTP test")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch {
+		case verb == "EHLO" || verb == "HELO":
+			reply("250-localhost")
+			reply("250 HELP")
+		case strings.HasPrefix(strings.ToUpper(line), "MAIL FROM:"):
+			msg.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+			reply("250 OK")
+		case strings.HasPrefix(strings.ToUpper(line), "RCPT TO:"):
+			if s.reject != "" {
+				reply(s.reject)
+				continue
+			}
+			msg.to = strings.Trim(line[len("RCPT TO:"):], "<>")
+			reply("250 OK")
+		case verb == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			msg.data = data.String()
+			s.messages <- msg
+			reply("250 OK")
+		case verb == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *smtpServer) notifier() *notify.SMTP {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return &notify.SMTP{Host: host, Port: p, From: "Todos <todos@example.com>"}
+}
+
+func TestSMTP(t *testing.T) {
+	server := newSMTPServer(t, "")
+	n := notify.Notification{
+		To:      notify.Recipient{ID: "1", Username: "bob", Email: "bob@example.com"},
+		Subject: "Reminder: pay\r\nBcc: eve@example.com rent – today",
+		Text:    "Pay the rent\nbefore noon",
+	}
+	if err := server.notifier().Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-server.messages
+	if msg.from != "todos@example.com" || msg.to != "bob@example.com" {
+		t.Fatalf("unexpected envelope %q -> %q", msg.from, msg.to)
+	}
+	header, body, _ := strings.Cut(msg.data, "\r\n\r\n")
+	if strings.Contains(header, "\r\nBcc:") {
+		t.Fatalf("header injected:\n%s", header)
+	}
+	var subject string
+	for _, line := range strings.Split(header, "\r\n") {
+		if value, ok := strings.CutPrefix(line, "Subject: "); ok {
+			subject, _ = new(mime.WordDecoder).DecodeHeader(value)
+		}
+	}
+	if subject != "Reminder: pay Bcc: eve@example.com rent – today" {
+		t.Fatalf("unexpected subject %q", subject)
+	}
+	if !strings.Contains(header, `To: "bob" <bob@example.com>`) || body != "Pay the rent\r\nbefore noon\r\n" {
+		t.Fatalf("unexpected message:\n%s", msg.data)
+	}
+
+	// a rejected recipient fails the delivery
+	if err := newSMTPServer(t, "550 no such user").notifier().Notify(context.Background(), n); err == nil {
+		t.Fatal("expected an error for a rejected recipient")
+	}
+	n.To.Email = "bob@example.com>\r\nRCPT TO:<eve@example.com"
+	if err := server.notifier().Notify(context.Background(), n); err == nil {
+		t.Fatal("expected an error for an invalid recipient")
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got notify.Notification
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" ||
+			r.Header.Get("X-Signature") != notify.Sign("secret", body) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.Unmarshal(body, &got)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	webhook := &notify.Webhook{URL: server.URL, Secret: "secret"}
+	n := notify.Notification{
+		To:      notify.Recipient{ID: "1", Username: "bob", Email: "bob@example.com"},
+		Subject: "Reminder: pay rent",
+		Data:    map[string]string{"todoId": "42"},
+	}
+	if err := webhook.Notify(context.Background(), n); err != nil {
+		t.Fatal(err)
+	}
+	if got.To.Username != "bob" || got.Subject != n.Subject || got.Data.(map[string]any)["todoId"] != "42" {
+		t.Fatalf("unexpected payload %+v", got)
+	}
+
+	status = http.StatusServiceUnavailable
+	if err := webhook.Notify(context.Background(), n); err == nil {
+		t.Fatal("expected an error for a 503")
+	}
+	webhook.Secret = "wrong"
+	status = http.StatusOK
+	if err := webhook.Notify(context.Background(), n); err == nil {
+		t.Fatal("expected the signature to be checked")
+	}
+}
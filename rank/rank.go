@@ -0,0 +1,130 @@
+// Package rank builds lexicographic keys for manually ordered items.
+// A key is a base-62 fraction (the digits after the point), so a key can
+// always be made between two others and keys compare as plain strings,
+// byte by byte, in MongoDB as in Go.
+package rank
+
+import (
+	"errors"
+	"strings"
+)
+
+// digits in ascending byte order
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const base = len(digits)
+
+// MaxLength is the length past which keys should be spread out again,
+// inserting at the same place over and over grows them by about one
+// digit every six moves
+const MaxLength = 24
+
+var (
+	// ErrInvalid is returned for a key with other characters than 0-9, A-Z
+	// and a-z, or ending in 0 (which leaves no key between it and its prefix)
+	ErrInvalid = errors.New("invalid rank")
+	// ErrOrder is returned when the lower key isn't below the upper one
+	ErrOrder = errors.New("ranks out of order")
+)
+
+// Valid reports whether key is a usable non-empty key
+func Valid(key string) bool {
+	if key == "" || key[len(key)-1] == '0' {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Between returns a key sorting after lo and before hi. An empty lo is the
+// start and an empty hi the end, so Between("", "") is a first key.
+func Between(lo, hi string) (string, error) {
+	if (lo != "" && !Valid(lo)) || (hi != "" && !Valid(hi)) {
+		return "", ErrInvalid
+	}
+	if hi != "" && lo >= hi {
+		return "", ErrOrder
+	}
+	return midpoint(lo, hi), nil
+}
+
+// After returns a key sorting after key, an empty key being the start
+func After(key string) (string, error) {
+	return Between(key, "")
+}
+
+// digit is the value of the key's i-th digit, keys end in zeros
+func digit(key string, i int) int {
+	if i >= len(key) {
+		return 0
+	}
+	return strings.IndexByte(digits, key[i])
+}
+
+// midpoint returns a key between lo and hi (lo < hi, "" hi is the end),
+// keeping it short: the common prefix and then as few digits as possible
+func midpoint(lo, hi string) string {
+	if hi != "" {
+		n := 0
+		for n < len(hi) && digit(lo, n) == digit(hi, n) {
+			n++
+		}
+		if n > 0 {
+			return hi[:n] + midpoint(tail(lo, n), hi[n:])
+		}
+	}
+
+	// the first digits differ
+	dlo, dhi := digit(lo, 0), base
+	if hi != "" {
+		dhi = digit(hi, 0)
+	}
+	if dhi-dlo > 1 {
+		return string(digits[(dlo+dhi)/2])
+	}
+	// consecutive digits: hi's first digit alone is above lo and below a longer hi
+	if len(hi) > 1 {
+		return hi[:1]
+	}
+	return string(digits[dlo]) + midpoint(tail(lo, 1), "")
+}
+
+// tail is what follows the first n digits of key
+func tail(key string, n int) string {
+	if n >= len(key) {
+		return ""
+	}
+	return key[n:]
+}
+
+// Spread returns n ascending keys evenly spaced over the whole range, as
+// short as they can be while leaving room for moves between them
+func Spread(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	// about base free keys around each one, up to 10 digits (62^10 < 2^63)
+	width, size := 1, uint64(base)
+	for size < uint64(base)*uint64(n+1) && width < 10 {
+		width++
+		size *= uint64(base)
+	}
+	step := size / uint64(n+1)
+
+	keys := make([]string, n)
+	buf := make([]byte, width)
+	for i := range keys {
+		v := step * uint64(i+1)
+		for j := width - 1; j >= 0; j-- {
+			buf[j] = digits[v%uint64(base)]
+			v /= uint64(base)
+		}
+		// trailing zeros don't change the order but would make the key invalid
+		keys[i] = strings.TrimRight(string(buf), "0")
+	}
+	return keys
+}
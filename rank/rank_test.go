@@ -0,0 +1,117 @@
+package rank_test
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/rank"
+)
+
+func TestBetween(t *testing.T) {
+	for _, tt := range []struct {
+		lo, hi, want string
+	}{
+		{"", "", "V"},
+		{"V", "", "k"},
+		{"", "V", "F"},
+		{"V", "W", "VV"},
+		{"V", "V1", "V0V"},
+		{"Vz", "W", "VzV"},
+		{"z", "", "zV"},
+		{"", "1", "0V"},
+		{"A1", "A2", "A1V"},
+		{"A", "B5", "B"},
+	} {
+		got, err := rank.Between(tt.lo, tt.hi)
+		if err != nil || got != tt.want {
+			t.Errorf("Between(%q, %q) = %q, %v, want %q", tt.lo, tt.hi, got, err, tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		lo, hi string
+		err    error
+	}{
+		{"V", "V", rank.ErrOrder},
+		{"W", "V", rank.ErrOrder},
+		{"V0", "", rank.ErrInvalid},
+		{"", "a-b", rank.ErrInvalid},
+	} {
+		if _, err := rank.Between(tt.lo, tt.hi); !errors.Is(err, tt.err) {
+			t.Errorf("Between(%q, %q) error = %v, want %v", tt.lo, tt.hi, err, tt.err)
+		}
+	}
+}
+
+// TestRandomMoves inserts keys at random places and checks they stay
+// ordered, valid and short
+func TestRandomMoves(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	keys := []string{}
+	for range 2000 {
+		i := rng.Intn(len(keys) + 1)
+		lo, hi := "", ""
+		if i > 0 {
+			lo = keys[i-1]
+		}
+		if i < len(keys) {
+			hi = keys[i]
+		}
+		key, err := rank.Between(lo, hi)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rank.Valid(key) || (lo != "" && key <= lo) || (hi != "" && key >= hi) {
+			t.Fatalf("Between(%q, %q) = %q", lo, hi, key)
+		}
+		keys = slices.Insert(keys, i, key)
+	}
+	for _, key := range keys {
+		if len(key) > 8 {
+			t.Fatalf("expected random inserts to keep keys short, got %q", key)
+		}
+	}
+
+	// always inserting at the front grows the keys slowly
+	key := ""
+	first := "V"
+	for range 100 {
+		var err error
+		if key, err = rank.Between("", first); err != nil {
+			t.Fatal(err)
+		}
+		first = key
+	}
+	if len(key) > rank.MaxLength {
+		t.Fatalf("expected %d moves to stay under MaxLength, got %q", 100, key)
+	}
+}
+
+func TestSpread(t *testing.T) {
+	for _, n := range []int{1, 2, 61, 62, 1000, 100000} {
+		keys := spread(t, n)
+		if len(keys) != n {
+			t.Fatalf("Spread(%d) returned %d keys", n, len(keys))
+		}
+	}
+	if keys := rank.Spread(3); !slices.Equal(keys, []string{"FV", "V", "kV"}) {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	if keys := rank.Spread(1000); len(keys[0]) > 3 {
+		t.Fatalf("expected short keys, got %q", keys[0])
+	}
+}
+
+// spread checks rank.Spread(n) is ascending and valid
+func spread(t *testing.T, n int) []string {
+	t.Helper()
+	keys := rank.Spread(n)
+	for i, key := range keys {
+		if !rank.Valid(key) || (i > 0 && key <= keys[i-1]) {
+			t.Fatalf("Spread(%d)[%d] = %q after %q", n, i, key, keys[max(i-1, 0)])
+		}
+	}
+	return keys
+}
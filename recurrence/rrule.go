@@ -0,0 +1,255 @@
+// Package recurrence expands iCalendar (RFC 5545) recurrence rules.
+package recurrence
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the RRULE FREQ part
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// WeekdayNum is a BYDAY entry: a weekday, optionally the Nth one of the
+// month or year (negative counts from the end, 0 means every one)
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+var weekdayNames = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+func (w WeekdayNum) String() string {
+	if w.N == 0 {
+		return weekdayNames[w.Weekday]
+	}
+	return strconv.Itoa(w.N) + weekdayNames[w.Weekday]
+}
+
+// Rule is a parsed RRULE. The supported parts are FREQ (DAILY, WEEKLY,
+// MONTHLY or YEARLY), INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH
+// and WKST.
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	Count    int
+	// Until is the last possible occurrence, zero for none. When UntilLocal
+	// is set it has no zone (a date or a floating date-time) and is read in
+	// the series' timezone.
+	Until      time.Time
+	UntilLocal bool
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+// UNTIL layouts, a trailing Z is UTC
+var untilLayouts = []string{"20060102T150405Z", "20060102T150405", "20060102"}
+
+// Parse reads an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,WE", with or
+// without the "RRULE:" prefix
+func Parse(value string) (Rule, error) {
+	rule := Rule{Interval: 1, WeekStart: time.Monday}
+	value = strings.TrimSpace(value)
+	if len(value) >= 6 && strings.EqualFold(value[:6], "RRULE:") {
+		value = value[6:]
+	}
+	if value == "" {
+		return rule, fmt.Errorf("empty rule")
+	}
+
+	seen := map[string]bool{}
+	for _, part := range strings.Split(value, ";") {
+		name, val, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		val = strings.ToUpper(strings.TrimSpace(val))
+		if !ok || val == "" {
+			return rule, fmt.Errorf("invalid part %q", part)
+		}
+		if seen[name] {
+			return rule, fmt.Errorf("%s is given twice", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			rule.Freq = Frequency(val)
+			switch rule.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				err = fmt.Errorf("unsupported FREQ %s, expected DAILY, WEEKLY, MONTHLY or YEARLY", val)
+			}
+		case "INTERVAL":
+			rule.Interval, err = number(val, 1, 1000)
+		case "COUNT":
+			rule.Count, err = number(val, 1, 10000)
+		case "UNTIL":
+			err = fmt.Errorf("invalid UNTIL %s", val)
+			for _, layout := range untilLayouts {
+				if t, perr := time.Parse(layout, val); perr == nil {
+					rule.Until, rule.UntilLocal, err = t, !strings.HasSuffix(layout, "Z"), nil
+					if layout == "20060102" {
+						rule.Until = rule.Until.Add(24*time.Hour - time.Second)
+					}
+					break
+				}
+			}
+		case "BYDAY":
+			for _, v := range strings.Split(val, ",") {
+				day, derr := parseWeekdayNum(v)
+				if derr != nil {
+					err = derr
+					break
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, v := range strings.Split(val, ",") {
+				day, derr := number(strings.TrimPrefix(v, "-"), 1, 31)
+				if derr != nil {
+					err = fmt.Errorf("invalid BYMONTHDAY %s", v)
+					break
+				}
+				if strings.HasPrefix(v, "-") {
+					day = -day
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, day)
+			}
+		case "BYMONTH":
+			for _, v := range strings.Split(val, ",") {
+				month, merr := number(v, 1, 12)
+				if merr != nil {
+					err = fmt.Errorf("invalid BYMONTH %s", v)
+					break
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(month))
+			}
+		case "WKST":
+			day, ok := weekdays[val]
+			if !ok {
+				err = fmt.Errorf("invalid WKST %s", val)
+			}
+			rule.WeekStart = day
+		default:
+			err = fmt.Errorf("unsupported part %s", name)
+		}
+		if err != nil {
+			return rule, err
+		}
+	}
+
+	switch {
+	case rule.Freq == "":
+		return rule, fmt.Errorf("FREQ is required")
+	case rule.Count > 0 && !rule.Until.IsZero():
+		return rule, fmt.Errorf("COUNT and UNTIL can't be combined")
+	case rule.Freq == Weekly && len(rule.ByMonthDay) > 0:
+		return rule, fmt.Errorf("BYMONTHDAY can't be used with FREQ=WEEKLY")
+	}
+	if rule.Freq != Monthly && rule.Freq != Yearly {
+		for _, day := range rule.ByDay {
+			if day.N != 0 {
+				return rule, fmt.Errorf("BYDAY %s needs FREQ=MONTHLY or YEARLY", day)
+			}
+		}
+	}
+	return rule, nil
+}
+
+func number(value string, min, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%s is not a number from %d to %d", value, min, max)
+	}
+	return n, nil
+}
+
+func parseWeekdayNum(value string) (WeekdayNum, error) {
+	if len(value) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %s", value)
+	}
+	day, ok := weekdays[value[len(value)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid BYDAY %s", value)
+	}
+	num := WeekdayNum{Weekday: day}
+	if prefix := strings.TrimPrefix(value[:len(value)-2], "+"); prefix != "" {
+		n, err := number(strings.TrimPrefix(prefix, "-"), 1, 53)
+		if err != nil {
+			return WeekdayNum{}, fmt.Errorf("invalid BYDAY %s", value)
+		}
+		if strings.HasPrefix(prefix, "-") {
+			n = -n
+		}
+		num.N = n
+	}
+	return num, nil
+}
+
+// String formats the rule as an RRULE value, parts in a fixed order
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		layout := untilLayouts[0]
+		if r.UntilLocal {
+			layout = untilLayouts[1]
+		}
+		parts = append(parts, "UNTIL="+r.Until.Format(layout))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = day.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]int, len(r.ByMonth))
+		for i, month := range r.ByMonth {
+			months[i] = int(month)
+		}
+		parts = append(parts, "BYMONTH="+joinInts(months))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+func joinInts(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ",")
+}
+
+// matchesWeekday reports whether BYDAY allows the weekday, ignoring numbers
+func (r Rule) matchesWeekday(day time.Weekday) bool {
+	return len(r.ByDay) == 0 || slices.ContainsFunc(r.ByDay, func(w WeekdayNum) bool { return w.Weekday == day })
+}
@@ -0,0 +1,247 @@
+package recurrence
+
+import (
+	"slices"
+	"time"
+)
+
+// maxPeriods bounds the search for rules that rarely or never match, such
+// as the 30th of February
+const maxPeriods = 50000
+
+// Series is a rule anchored at its first occurrence, Start. Occurrences
+// keep Start's wall clock time in Start's location, across DST changes.
+type Series struct {
+	Rule  Rule
+	Start time.Time
+	// Exceptions are occurrences left out of the series (EXDATE), they
+	// still count towards COUNT
+	Exceptions []time.Time
+}
+
+// Next returns the first occurrence after t, false when the series has ended
+func (s Series) Next(t time.Time) (time.Time, bool) {
+	var next time.Time
+	s.each(func(o time.Time) bool {
+		if o.After(t) {
+			next = o
+			return false
+		}
+		return true
+	})
+	return next, !next.IsZero()
+}
+
+// Between returns up to limit occurrences from from (inclusive) to to (exclusive)
+func (s Series) Between(from, to time.Time, limit int) []time.Time {
+	occurrences := []time.Time{}
+	s.each(func(o time.Time) bool {
+		if !o.Before(to) || len(occurrences) >= limit {
+			return false
+		}
+		if !o.Before(from) {
+			occurrences = append(occurrences, o)
+		}
+		return true
+	})
+	return occurrences
+}
+
+// On returns the occurrence on the date in Start's location, exceptions
+// included, false when there is none
+func (s Series) On(year int, month time.Month, day int) (time.Time, bool) {
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	var on time.Time
+	Series{Rule: s.Rule, Start: s.Start}.each(func(o time.Time) bool {
+		switch c := civil(o); {
+		case c.Equal(date):
+			on = o
+			return false
+		case c.After(date):
+			return false
+		}
+		return true
+	})
+	return on, !on.IsZero()
+}
+
+// each calls fn with the occurrences in order, Start first, until fn
+// returns false or the series ends
+func (s Series) each(fn func(time.Time) bool) {
+	loc := s.Start.Location()
+	until := s.Rule.Until
+	if s.Rule.UntilLocal {
+		until = localTime(until, until, loc)
+	}
+
+	count := 0
+	emit := func(t time.Time) bool {
+		if !until.IsZero() && t.After(until) {
+			return false
+		}
+		count++
+		if !slices.ContainsFunc(s.Exceptions, t.Equal) && !fn(t) {
+			return false
+		}
+		return s.Rule.Count == 0 || count < s.Rule.Count
+	}
+
+	// DTSTART is always the first occurrence
+	if !emit(s.Start) {
+		return
+	}
+	first := civil(s.Start)
+	for i := 0; i < maxPeriods; i++ {
+		for _, day := range s.Rule.period(first, i) {
+			t := localTime(day, s.Start, loc)
+			if !t.After(s.Start) {
+				continue
+			}
+			if !emit(t) {
+				return
+			}
+		}
+	}
+}
+
+// civil is t's calendar date as midnight UTC, so days can be added without
+// running into DST changes
+func civil(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// localTime is day's date at clock's wall clock time in loc. As RFC 5545
+// asks, a time skipped by a DST change is read with the offset from before
+// the change, and a time that happens twice is the first of the two.
+func localTime(day, clock time.Time, loc *time.Location) time.Time {
+	y, m, d := day.Date()
+	h, mi, sec := clock.Clock()
+	wall := time.Date(y, m, d, h, mi, sec, 0, time.UTC)
+
+	// zones change at most once around a given day
+	_, before := wall.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := wall.Add(24 * time.Hour).In(loc).Zone()
+	for _, offset := range []int{max(before, after), min(before, after)} {
+		t := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		if t.Hour() == h && t.Minute() == mi && t.Second() == sec {
+			return t
+		}
+	}
+	return wall.Add(-time.Duration(before) * time.Second).In(loc)
+}
+
+// period returns the candidate days of the i-th period after the one
+// holding first, in order
+func (r Rule) period(first time.Time, i int) []time.Time {
+	step := i * r.Interval
+	switch r.Freq {
+	case Daily:
+		day := first.AddDate(0, 0, step)
+		if r.inMonth(day.Month()) && r.onMonthDay(day) && r.matchesWeekday(day.Weekday()) {
+			return []time.Time{day}
+		}
+	case Weekly:
+		weekStart := first.AddDate(0, 0, -int((first.Weekday()-r.WeekStart+7)%7)+7*step)
+		var days []time.Time
+		for d := 0; d < 7; d++ {
+			day := weekStart.AddDate(0, 0, d)
+			if !r.inMonth(day.Month()) {
+				continue
+			}
+			if len(r.ByDay) == 0 && day.Weekday() == first.Weekday() || len(r.ByDay) > 0 && r.matchesWeekday(day.Weekday()) {
+				days = append(days, day)
+			}
+		}
+		return days
+	case Monthly:
+		month := time.Date(first.Year(), first.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		if r.inMonth(month.Month()) {
+			return r.monthDays(month, first.Day())
+		}
+	case Yearly:
+		year := first.Year() + step
+		if len(r.ByDay) > 0 && len(r.ByMonth) == 0 {
+			// numbered weekdays count through the whole year
+			return r.selectDays(daysBetween(time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(year+1, 1, 1, 0, 0, 0, 0, time.UTC)))
+		}
+		months := slices.Clone(r.ByMonth)
+		switch {
+		case len(months) == 0 && len(r.ByMonthDay) == 0:
+			months = []time.Month{first.Month()}
+		case len(months) == 0:
+			for m := time.January; m <= time.December; m++ {
+				months = append(months, m)
+			}
+		}
+		slices.Sort(months)
+		var days []time.Time
+		for _, m := range slices.Compact(months) {
+			days = append(days, r.monthDays(time.Date(year, m, 1, 0, 0, 0, 0, time.UTC), first.Day())...)
+		}
+		return days
+	}
+	return nil
+}
+
+// monthDays returns the days of month matching BYMONTHDAY and BYDAY, or
+// the day-th without them; months without that day are skipped
+func (r Rule) monthDays(month time.Time, day int) []time.Time {
+	if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+		d := month.AddDate(0, 0, day-1)
+		if d.Month() != month.Month() {
+			return nil
+		}
+		return []time.Time{d}
+	}
+	return r.selectDays(daysBetween(month, month.AddDate(0, 1, 0)))
+}
+
+func daysBetween(from, to time.Time) []time.Time {
+	var days []time.Time
+	for d := from; d.Before(to); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+	return days
+}
+
+// selectDays keeps the days matching BYMONTHDAY and BYDAY, numbered
+// weekdays count within days
+func (r Rule) selectDays(days []time.Time) []time.Time {
+	total := map[time.Weekday]int{}
+	for _, day := range days {
+		total[day.Weekday()]++
+	}
+
+	seen := map[time.Weekday]int{}
+	var selected []time.Time
+	for _, day := range days {
+		seen[day.Weekday()]++
+		if !r.onMonthDay(day) {
+			continue
+		}
+		nth, fromEnd := seen[day.Weekday()], seen[day.Weekday()]-total[day.Weekday()]-1
+		if len(r.ByDay) == 0 || slices.ContainsFunc(r.ByDay, func(w WeekdayNum) bool {
+			return w.Weekday == day.Weekday() && (w.N == 0 || w.N == nth || w.N == fromEnd)
+		}) {
+			selected = append(selected, day)
+		}
+	}
+	return selected
+}
+
+func (r Rule) inMonth(month time.Month) bool {
+	return len(r.ByMonth) == 0 || slices.Contains(r.ByMonth, month)
+}
+
+// onMonthDay reports whether BYMONTHDAY allows the day, negative days count
+// from the end of the month
+func (r Rule) onMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	return slices.ContainsFunc(r.ByMonthDay, func(d int) bool {
+		return d == day.Day() || d < 0 && last+d+1 == day.Day()
+	})
+}
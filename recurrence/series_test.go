@@ -0,0 +1,193 @@
+package recurrence_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/recurrence"
+)
+
+func location(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func series(t *testing.T, rule string, start time.Time, exceptions ...time.Time) recurrence.Series {
+	t.Helper()
+	r, err := recurrence.Parse(rule)
+	if err != nil {
+		t.Fatalf("parse %s: %v", rule, err)
+	}
+	return recurrence.Series{Rule: r, Start: start, Exceptions: exceptions}
+}
+
+// format lists occurrences in their location, in a compact layout
+func format(times []time.Time) string {
+	s := make([]string, len(times))
+	for i, t := range times {
+		s[i] = t.Format("2006-01-02 15:04 MST")
+	}
+	return strings.Join(s, ", ")
+}
+
+func TestParse(t *testing.T) {
+	for rule, want := range map[string]string{
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,WE":          "FREQ=WEEKLY;BYDAY=MO,WE",
+		"freq=monthly;byday=-1fr;interval=2":     "FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR",
+		"FREQ=DAILY;UNTIL=20260501":              "FREQ=DAILY;UNTIL=20260501T235959",
+		"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=-1":    "FREQ=YEARLY;BYMONTHDAY=-1;BYMONTH=2",
+		"FREQ=WEEKLY;INTERVAL=1;WKST=SU;COUNT=3": "FREQ=WEEKLY;COUNT=3;WKST=SU",
+	} {
+		r, err := recurrence.Parse(rule)
+		if err != nil {
+			t.Fatalf("parse %s: %v", rule, err)
+		}
+		if r.String() != want {
+			t.Errorf("%s: expected %s, got %s", rule, want, r)
+		}
+	}
+
+	for _, rule := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20260501",
+		"FREQ=DAILY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYSETPOS=1",
+		"FREQ=DAILY;FREQ=WEEKLY",
+	} {
+		if _, err := recurrence.Parse(rule); err == nil {
+			t.Errorf("expected %q to be rejected", rule)
+		}
+	}
+}
+
+func TestSeries(t *testing.T) {
+	utc := time.UTC
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, utc)
+	to := time.Date(2027, 1, 1, 0, 0, 0, 0, utc)
+
+	for _, tc := range []struct {
+		rule  string
+		start time.Time
+		want  string
+	}{
+		{"FREQ=DAILY;INTERVAL=2;COUNT=3", time.Date(2026, 1, 30, 9, 0, 0, 0, utc),
+			"2026-01-30 09:00 UTC, 2026-02-01 09:00 UTC, 2026-02-03 09:00 UTC"},
+		{"FREQ=WEEKLY;BYDAY=MO,FR;UNTIL=20260112", time.Date(2026, 1, 2, 18, 30, 0, 0, utc),
+			"2026-01-02 18:30 UTC, 2026-01-05 18:30 UTC, 2026-01-09 18:30 UTC, 2026-01-12 18:30 UTC"},
+		{"FREQ=WEEKLY;INTERVAL=2;COUNT=3", time.Date(2026, 1, 7, 8, 0, 0, 0, utc),
+			"2026-01-07 08:00 UTC, 2026-01-21 08:00 UTC, 2026-02-04 08:00 UTC"},
+		// months without the 31st are skipped
+		{"FREQ=MONTHLY;COUNT=4", time.Date(2026, 1, 31, 12, 0, 0, 0, utc),
+			"2026-01-31 12:00 UTC, 2026-03-31 12:00 UTC, 2026-05-31 12:00 UTC, 2026-07-31 12:00 UTC"},
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", time.Date(2026, 1, 30, 17, 0, 0, 0, utc),
+			"2026-01-30 17:00 UTC, 2026-02-27 17:00 UTC, 2026-03-27 17:00 UTC"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1;COUNT=4", time.Date(2026, 2, 1, 7, 0, 0, 0, utc),
+			"2026-02-01 07:00 UTC, 2026-02-28 07:00 UTC, 2026-03-01 07:00 UTC, 2026-03-31 07:00 UTC"},
+		{"FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;COUNT=2", time.Date(2026, 11, 26, 15, 0, 0, 0, utc),
+			"2026-11-26 15:00 UTC, 2027-11-25 15:00 UTC"},
+		{"FREQ=YEARLY;COUNT=2", time.Date(2024, 2, 29, 0, 0, 0, 0, utc),
+			"2024-02-29 00:00 UTC, 2028-02-29 00:00 UTC"},
+		{"FREQ=DAILY;BYDAY=SA,SU;COUNT=3", time.Date(2026, 1, 3, 10, 0, 0, 0, utc),
+			"2026-01-03 10:00 UTC, 2026-01-04 10:00 UTC, 2026-01-10 10:00 UTC"},
+	} {
+		s := series(t, tc.rule, tc.start)
+		if got := format(s.Between(tc.start, tc.start.AddDate(5, 0, 0), 100)); got != tc.want {
+			t.Errorf("%s:\nexpected %s\n     got %s", tc.rule, tc.want, got)
+		}
+	}
+
+	// a rule that never matches ends after a bounded search
+	s := series(t, "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", time.Date(2026, 1, 1, 0, 0, 0, 0, utc))
+	if got := s.Between(from, to, 10); len(got) != 1 {
+		t.Fatalf("expected only the start, got %s", format(got))
+	}
+}
+
+func TestSeriesNextAndExceptions(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	skipped := start.AddDate(0, 0, 7)
+	s := series(t, "FREQ=WEEKLY;COUNT=4", start, skipped)
+
+	next, ok := s.Next(start)
+	if !ok || !next.Equal(start.AddDate(0, 0, 14)) {
+		t.Fatalf("expected the skipped week left out, got %v", next)
+	}
+	// exceptions count towards COUNT
+	if got := s.Between(start, start.AddDate(1, 0, 0), 100); len(got) != 3 {
+		t.Fatalf("expected 3 occurrences, got %s", format(got))
+	}
+	if _, ok := s.Next(start.AddDate(0, 0, 21)); ok {
+		t.Fatal("expected the series to end")
+	}
+	if got := s.Between(start, start.AddDate(1, 0, 0), 2); len(got) != 2 {
+		t.Fatalf("expected the limit to apply, got %s", format(got))
+	}
+
+	if on, ok := s.On(2026, time.March, 9); !ok || !on.Equal(skipped) {
+		t.Fatalf("expected the occurrence on the 9th, got %v", on)
+	}
+	if _, ok := s.On(2026, time.March, 10); ok {
+		t.Fatal("expected no occurrence on the 10th")
+	}
+}
+
+func TestSeriesDST(t *testing.T) {
+	berlin := location(t, "Europe/Berlin")
+	newYork := location(t, "America/New_York")
+
+	// the wall clock time holds across both changes
+	s := series(t, "FREQ=DAILY", time.Date(2026, 3, 28, 9, 0, 0, 0, berlin))
+	got := s.Between(s.Start, time.Date(2026, 3, 31, 0, 0, 0, 0, berlin), 10)
+	if format(got) != "2026-03-28 09:00 CET, 2026-03-29 09:00 CEST, 2026-03-30 09:00 CEST" {
+		t.Fatalf("unexpected spring occurrences %s", format(got))
+	}
+	if got[1].Sub(got[0]) != 23*time.Hour {
+		t.Fatalf("expected a 23 hour day, got %v", got[1].Sub(got[0]))
+	}
+	s = series(t, "FREQ=WEEKLY", time.Date(2026, 10, 18, 9, 0, 0, 0, berlin))
+	next, _ := s.Next(s.Start)
+	if next.Format(time.RFC3339) != "2026-10-25T09:00:00+01:00" {
+		t.Fatalf("unexpected autumn occurrence %s", next.Format(time.RFC3339))
+	}
+
+	// a skipped time is read with the offset from before the change
+	s = series(t, "FREQ=DAILY;COUNT=3", time.Date(2026, 3, 7, 2, 30, 0, 0, newYork))
+	if got := format(s.Between(s.Start, s.Start.AddDate(0, 0, 5), 10)); got != "2026-03-07 02:30 EST, 2026-03-08 03:30 EDT, 2026-03-09 02:30 EDT" {
+		t.Fatalf("unexpected occurrences around the gap %s", got)
+	}
+	s = series(t, "FREQ=DAILY;COUNT=2", time.Date(2026, 3, 28, 2, 30, 0, 0, berlin))
+	if got := format(s.Between(s.Start, s.Start.AddDate(0, 0, 5), 10)); got != "2026-03-28 02:30 CET, 2026-03-29 03:30 CEST" {
+		t.Fatalf("unexpected occurrences around the gap %s", got)
+	}
+
+	// a repeated time is the first of the two
+	s = series(t, "FREQ=WEEKLY;BYDAY=SA,SU;COUNT=2", time.Date(2026, 10, 24, 2, 30, 0, 0, berlin))
+	if got := format(s.Between(s.Start, s.Start.AddDate(0, 0, 5), 10)); got != "2026-10-24 02:30 CEST, 2026-10-25 02:30 CEST" {
+		t.Fatalf("unexpected occurrences around the overlap %s", got)
+	}
+	s = series(t, "FREQ=DAILY;COUNT=2", time.Date(2026, 10, 31, 1, 30, 0, 0, newYork))
+	if got := format(s.Between(s.Start, s.Start.AddDate(0, 0, 5), 10)); got != "2026-10-31 01:30 EDT, 2026-11-01 01:30 EDT" {
+		t.Fatalf("unexpected occurrences around the overlap %s", got)
+	}
+
+	// a UTC until is compared as an instant, a floating one in the series' zone
+	s = series(t, "FREQ=DAILY;UNTIL=20260330T070000Z", time.Date(2026, 3, 28, 9, 0, 0, 0, berlin))
+	if got := s.Between(s.Start, s.Start.AddDate(0, 1, 0), 10); len(got) != 3 {
+		t.Fatalf("expected 3 occurrences until 09:00 CEST, got %s", format(got))
+	}
+	s = series(t, "FREQ=DAILY;UNTIL=20260329", time.Date(2026, 3, 28, 9, 0, 0, 0, berlin))
+	if got := s.Between(s.Start, s.Start.AddDate(0, 1, 0), 10); len(got) != 2 {
+		t.Fatalf("expected 2 occurrences until the end of the 29th, got %s", format(got))
+	}
+}
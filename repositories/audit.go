@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+)
+
+// now is truncated to milliseconds, the precision MongoDB stores dates with,
+// so memory and mongo repositories return the same timestamps
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+// stampCreated fills the audit fields of a document about to be inserted
+func stampCreated(audit *models.Audit) {
+	if audit.CreatedAt.IsZero() {
+		audit.CreatedAt = now()
+	}
+	audit.UpdatedAt = audit.CreatedAt
+	if audit.UpdatedBy == nil {
+		audit.UpdatedBy = audit.CreatedBy
+	}
+}
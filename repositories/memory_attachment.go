@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryAttachmentRepository struct {
+	mu          sync.RWMutex
+	attachments map[primitive.ObjectID]models.Attachment
+	order       []primitive.ObjectID // insertion order
+	usage       map[primitive.ObjectID]int64
+}
+
+// NewMemoryAttachmentRepository returns an AttachmentRepository kept in memory
+func NewMemoryAttachmentRepository() AttachmentRepository {
+	return &memoryAttachmentRepository{
+		attachments: map[primitive.ObjectID]models.Attachment{},
+		usage:       map[primitive.ObjectID]int64{},
+	}
+}
+
+func (r *memoryAttachmentRepository) Create(ctx context.Context, attachment *models.Attachment, quota int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if quota > 0 && r.usage[attachment.UploadedBy]+attachment.Size > quota {
+		return ErrQuotaExceeded
+	}
+	if attachment.ID.IsZero() {
+		attachment.ID = primitive.NewObjectID()
+	}
+	if attachment.UploadedAt.IsZero() {
+		attachment.UploadedAt = now()
+	}
+	r.usage[attachment.UploadedBy] += attachment.Size
+	r.attachments[attachment.ID] = *attachment
+	r.order = append(r.order, attachment.ID)
+	return nil
+}
+
+func (r *memoryAttachmentRepository) filter(match func(models.Attachment) bool) []models.Attachment {
+	attachments := []models.Attachment{}
+	for _, id := range r.order {
+		if a := r.attachments[id]; match(a) {
+			attachments = append(attachments, a)
+		}
+	}
+	return attachments
+}
+
+func (r *memoryAttachmentRepository) FindAll(ctx context.Context) ([]models.Attachment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filter(func(models.Attachment) bool { return true }), nil
+}
+
+func (r *memoryAttachmentRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Attachment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	attachment, ok := r.attachments[id]
+	if !ok {
+		return models.Attachment{}, ErrNotFound
+	}
+	return attachment, nil
+}
+
+func (r *memoryAttachmentRepository) FindByTodoID(ctx context.Context, todoID primitive.ObjectID) ([]models.Attachment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filter(func(a models.Attachment) bool { return a.TodoID == todoID }), nil
+}
+
+func (r *memoryAttachmentRepository) FindPageByTodoID(ctx context.Context, todoID primitive.ObjectID, page PageRequest) (Page[models.Attachment], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	attachments := r.filter(func(a models.Attachment) bool { return a.TodoID == todoID })
+	return paginate(attachments, attachmentKeys, page, func(a models.Attachment) ([]any, primitive.ObjectID) {
+		return attachmentSortValues(a, 0)
+	})
+}
+
+func (r *memoryAttachmentRepository) FindByKey(ctx context.Context, key string) (models.Attachment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	found := r.filter(func(a models.Attachment) bool { return a.Key == key })
+	if len(found) == 0 {
+		return models.Attachment{}, ErrNotFound
+	}
+	return found[0], nil
+}
+
+func (r *memoryAttachmentRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attachment, ok := r.attachments[id]
+	if !ok {
+		return ErrNotFound
+	}
+	r.usage[attachment.UploadedBy] -= attachment.Size
+	delete(r.attachments, id)
+	for i, oid := range r.order {
+		if oid == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *memoryAttachmentRepository) Usage(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.usage[userID], nil
+}
@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryInvitationRepository struct {
+	mu          sync.RWMutex
+	invitations map[primitive.ObjectID]models.Invitation
+	order       []primitive.ObjectID // insertion order
+}
+
+// NewMemoryInvitationRepository returns an InvitationRepository kept in memory
+func NewMemoryInvitationRepository() InvitationRepository {
+	return &memoryInvitationRepository{invitations: map[primitive.ObjectID]models.Invitation{}}
+}
+
+func (r *memoryInvitationRepository) filter(match func(models.Invitation) bool) []models.Invitation {
+	invitations := []models.Invitation{}
+	for _, id := range r.order {
+		if i := r.invitations[id]; match(i) {
+			invitations = append(invitations, i)
+		}
+	}
+	return invitations
+}
+
+func (r *memoryInvitationRepository) Create(ctx context.Context, invitation *models.Invitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if invitation.Status == "" {
+		invitation.Status = models.InvitationPending
+	}
+	if invitation.Status == models.InvitationPending {
+		pending := r.filter(func(i models.Invitation) bool {
+			return i.Status == models.InvitationPending && i.ResourceID == invitation.ResourceID && i.UserID == invitation.UserID
+		})
+		if len(pending) > 0 {
+			return &DuplicateError{Field: "user"}
+		}
+	}
+	if invitation.ID.IsZero() {
+		invitation.ID = primitive.NewObjectID()
+	}
+	if invitation.CreatedAt.IsZero() {
+		invitation.CreatedAt = now()
+	}
+	r.invitations[invitation.ID] = *invitation
+	r.order = append(r.order, invitation.ID)
+	return nil
+}
+
+func (r *memoryInvitationRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Invitation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	invitation, ok := r.invitations[id]
+	if !ok {
+		return models.Invitation{}, ErrNotFound
+	}
+	return invitation, nil
+}
+
+func (r *memoryInvitationRepository) FindPage(ctx context.Context, query InvitationQuery, page PageRequest) (Page[models.Invitation], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	invitations := r.filter(func(i models.Invitation) bool {
+		return i.UserID == query.UserID && (query.Status == "" || i.Status == query.Status)
+	})
+	return paginate(invitations, invitationKeys, page, func(i models.Invitation) ([]any, primitive.ObjectID) {
+		return invitationSortValues(i, 0)
+	})
+}
+
+func (r *memoryInvitationRepository) FindPending(ctx context.Context, resourceID primitive.ObjectID) ([]models.Invitation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filter(func(i models.Invitation) bool {
+		return i.ResourceID == resourceID && i.Status == models.InvitationPending
+	}), nil
+}
+
+func (r *memoryInvitationRepository) Respond(ctx context.Context, id primitive.ObjectID, status string) (models.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invitation, ok := r.invitations[id]
+	if !ok || invitation.Status != models.InvitationPending {
+		return models.Invitation{}, ErrNotFound
+	}
+	respondedAt := now()
+	invitation.Status = status
+	invitation.RespondedAt = &respondedAt
+	r.invitations[id] = invitation
+	return invitation, nil
+}
+
+func (r *memoryInvitationRepository) Reopen(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invitation, ok := r.invitations[id]
+	if !ok || invitation.Status == models.InvitationPending {
+		return ErrNotFound
+	}
+	invitation.Status = models.InvitationPending
+	invitation.RespondedAt = nil
+	r.invitations[id] = invitation
+	return nil
+}
+
+func (r *memoryInvitationRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.invitations[id]; !ok {
+		return ErrNotFound
+	}
+	r.remove(func(i models.Invitation) bool { return i.ID == id })
+	return nil
+}
+
+func (r *memoryInvitationRepository) DeleteByResource(ctx context.Context, resourceID primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.remove(func(i models.Invitation) bool { return i.ResourceID == resourceID })
+	return nil
+}
+
+// remove deletes the matching invitations, the caller holds the lock
+func (r *memoryInvitationRepository) remove(match func(models.Invitation) bool) {
+	kept := r.order[:0]
+	for _, id := range r.order {
+		if match(r.invitations[id]) {
+			delete(r.invitations, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	r.order = kept
+}
@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryListRepository struct {
+	mu    sync.RWMutex
+	lists map[primitive.ObjectID]models.List
+	order []primitive.ObjectID // insertion order
+}
+
+// NewMemoryListRepository returns a ListRepository kept in memory
+func NewMemoryListRepository() ListRepository {
+	return &memoryListRepository{lists: map[primitive.ObjectID]models.List{}}
+}
+
+func (r *memoryListRepository) Create(ctx context.Context, list *models.List) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if list.ID.IsZero() {
+		list.ID = primitive.NewObjectID()
+	}
+	if list.Position < 0 {
+		list.Position = 0
+		for _, l := range r.lists {
+			if l.UserID == list.UserID && l.Position >= list.Position {
+				list.Position = l.Position + 1
+			}
+		}
+	}
+	stampCreated(&list.Audit)
+	r.lists[list.ID] = *list
+	r.order = append(r.order, list.ID)
+	return nil
+}
+
+func (r *memoryListRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.List, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list, ok := r.lists[id]
+	if !ok {
+		return models.List{}, ErrNotFound
+	}
+	return list, nil
+}
+
+func (r *memoryListRepository) FindPage(ctx context.Context, query ListQuery, page PageRequest) (Page[models.List], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	lists := []models.List{}
+	for _, id := range r.order {
+		list := r.lists[id]
+		member := list.UserID == query.UserID || (query.Shared && list.Access(query.UserID) > models.AccessNone)
+		if !member || (query.Archived != nil && list.Archived != *query.Archived) {
+			continue
+		}
+		lists = append(lists, list)
+	}
+	return paginate(lists, listKeys, page, func(l models.List) ([]any, primitive.ObjectID) {
+		return listSortValues(l, 0)
+	})
+}
+
+func (r *memoryListRepository) FindSharedWith(ctx context.Context, userID primitive.ObjectID) ([]models.List, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	lists := []models.List{}
+	for _, id := range r.order {
+		if list := r.lists[id]; list.UserID != userID && list.Access(userID) > models.AccessNone {
+			lists = append(lists, list)
+		}
+	}
+	return lists, nil
+}
+
+func (r *memoryListRepository) Update(ctx context.Context, id primitive.ObjectID, update ListUpdate) (models.List, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list, ok := r.lists[id]
+	if !ok {
+		return models.List{}, ErrNotFound
+	}
+	if update.Name != nil {
+		list.Name = *update.Name
+	}
+	if update.Color != nil {
+		list.Color = *update.Color
+	}
+	if update.Archived != nil {
+		list.Archived = *update.Archived
+	}
+	if update.Position != nil {
+		list.Position = *update.Position
+	}
+	list.UpdatedAt = now()
+	if update.UpdatedBy != nil {
+		list.UpdatedBy = update.UpdatedBy
+	}
+	r.lists[id] = list
+	return list, nil
+}
+
+func (r *memoryListRepository) SetCollaborator(ctx context.Context, listID primitive.ObjectID, collaborator models.Collaborator) (models.List, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list, ok := r.lists[listID]
+	if !ok {
+		return models.List{}, ErrNotFound
+	}
+	list.Collaborators = setCollaborator(list.Collaborators, collaborator)
+	r.lists[listID] = list
+	return list, nil
+}
+
+func (r *memoryListRepository) RemoveCollaborator(ctx context.Context, listID, userID primitive.ObjectID) (models.List, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list, ok := r.lists[listID]
+	if !ok {
+		return models.List{}, ErrNotFound
+	}
+	list.Collaborators = removeCollaborator(list.Collaborators, userID)
+	r.lists[listID] = list
+	return list, nil
+}
+
+func (r *memoryListRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.lists[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.lists, id)
+	for i, oid := range r.order {
+		if oid == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
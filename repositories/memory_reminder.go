@@ -0,0 +1,158 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryReminderRepository struct {
+	mu        sync.Mutex
+	reminders map[primitive.ObjectID]models.Reminder
+	order     []primitive.ObjectID // insertion order
+}
+
+// NewMemoryReminderRepository returns a ReminderRepository kept in memory
+func NewMemoryReminderRepository() ReminderRepository {
+	return &memoryReminderRepository{reminders: map[primitive.ObjectID]models.Reminder{}}
+}
+
+func (r *memoryReminderRepository) Create(ctx context.Context, reminder *models.Reminder) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if reminder.ID.IsZero() {
+		reminder.ID = primitive.NewObjectID()
+	}
+	if reminder.Status == "" {
+		reminder.Status = models.ReminderPending
+	}
+	if reminder.CreatedAt.IsZero() {
+		reminder.CreatedAt = now()
+	}
+	r.reminders[reminder.ID] = *reminder
+	r.order = append(r.order, reminder.ID)
+	return nil
+}
+
+func (r *memoryReminderRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Reminder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reminder, ok := r.reminders[id]
+	if !ok {
+		return models.Reminder{}, ErrNotFound
+	}
+	return reminder, nil
+}
+
+func (r *memoryReminderRepository) FindByTodo(ctx context.Context, todoID, userID primitive.ObjectID) ([]models.Reminder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reminders := []models.Reminder{}
+	for _, id := range r.order {
+		if reminder, ok := r.reminders[id]; ok && reminder.TodoID == todoID && (userID.IsZero() || reminder.UserID == userID) {
+			reminders = append(reminders, reminder)
+		}
+	}
+	return reminders, nil
+}
+
+func (r *memoryReminderRepository) Reschedule(ctx context.Context, todoID primitive.ObjectID, dueAt *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, reminder := range r.reminders {
+		if reminder.TodoID != todoID || reminder.MinutesBefore == nil {
+			continue
+		}
+		fireAt := reminder.FireTime(dueAt)
+		switch {
+		case reminder.Status == models.ReminderPending:
+			reminder.FireAt = fireAt
+		case reminder.Status == models.ReminderSent && fireAt != nil && fireAt.After(now()):
+			reminder.FireAt, reminder.Status, reminder.Attempts = fireAt, models.ReminderPending, 0
+			reminder.SentAt, reminder.LastError = nil, ""
+		default:
+			continue
+		}
+		r.reminders[id] = reminder
+	}
+	return nil
+}
+
+func (r *memoryReminderRepository) Lease(ctx context.Context, at time.Time, owner string, until time.Time) (models.Reminder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due *models.Reminder
+	for _, id := range r.order {
+		reminder, ok := r.reminders[id]
+		if !ok || reminder.Status != models.ReminderPending || reminder.FireAt == nil || reminder.FireAt.After(at) {
+			continue
+		}
+		if reminder.LeaseUntil != nil && reminder.LeaseUntil.After(at) {
+			continue
+		}
+		if due == nil || reminder.FireAt.Before(*due.FireAt) {
+			due = &reminder
+		}
+	}
+	if due == nil {
+		return models.Reminder{}, ErrNotFound
+	}
+	due.LeaseOwner, due.LeaseUntil = owner, &until
+	due.Attempts++
+	r.reminders[due.ID] = *due
+	return *due, nil
+}
+
+func (r *memoryReminderRepository) Finish(ctx context.Context, id primitive.ObjectID, owner string, result ReminderResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reminder, ok := r.reminders[id]
+	if !ok || reminder.LeaseOwner != owner {
+		return ErrNotFound
+	}
+	reminder.Status, reminder.LastError = result.Status, result.Error
+	reminder.LeaseOwner, reminder.LeaseUntil = "", nil
+	switch result.Status {
+	case models.ReminderSent:
+		sentAt := now()
+		reminder.SentAt = &sentAt
+	case models.ReminderPending:
+		// the lease doubles as the retry delay
+		retryAt := result.RetryAt
+		reminder.LeaseUntil = &retryAt
+	}
+	r.reminders[id] = reminder
+	return nil
+}
+
+func (r *memoryReminderRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.reminders[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.reminders, id)
+	return nil
+}
+
+func (r *memoryReminderRepository) DeleteByTodo(ctx context.Context, todoID primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, reminder := range r.reminders {
+		if reminder.TodoID == todoID {
+			delete(r.reminders, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memorySessionRepository struct {
+	mu       sync.RWMutex
+	sessions map[primitive.ObjectID]models.Session
+}
+
+// NewMemorySessionRepository returns a SessionRepository kept in memory
+func NewMemorySessionRepository() SessionRepository {
+	return &memorySessionRepository{sessions: map[primitive.ObjectID]models.Session{}}
+}
+
+func (r *memorySessionRepository) Create(ctx context.Context, session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if session.ID.IsZero() {
+		session.ID = primitive.NewObjectID()
+	}
+	s := *session
+	s.UsedHashes = slices.Clone(session.UsedHashes)
+	r.sessions[s.ID] = s
+	return nil
+}
+
+func (r *memorySessionRepository) findOne(match func(models.Session) bool) (models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, s := range r.sessions {
+		if match(s) {
+			s.UsedHashes = slices.Clone(s.UsedHashes)
+			return s, nil
+		}
+	}
+	return models.Session{}, ErrNotFound
+}
+
+func (r *memorySessionRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Session, error) {
+	return r.findOne(func(s models.Session) bool { return s.ID == id })
+}
+
+func (r *memorySessionRepository) FindByRefreshHash(ctx context.Context, hash string) (models.Session, error) {
+	return r.findOne(func(s models.Session) bool { return s.RefreshHash == hash })
+}
+
+func (r *memorySessionRepository) FindByUsedHash(ctx context.Context, hash string) (models.Session, error) {
+	return r.findOne(func(s models.Session) bool { return slices.Contains(s.UsedHashes, hash) })
+}
+
+func (r *memorySessionRepository) Rotate(ctx context.Context, id primitive.ObjectID, oldHash, newHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[id]
+	if !ok || s.Revoked || s.RefreshHash != oldHash {
+		return ErrNotFound
+	}
+	s.UsedHashes = append(slices.Clone(s.UsedHashes), oldHash)
+	s.RefreshHash = newHash
+	s.LastUsedAt = time.Now()
+	r.sessions[id] = s
+	return nil
+}
+
+func (r *memorySessionRepository) revoke(match func(models.Session) bool) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var revoked int64
+	now := time.Now()
+	for id, s := range r.sessions {
+		if !s.Revoked && match(s) {
+			s.Revoked = true
+			s.RevokedAt = &now
+			r.sessions[id] = s
+			revoked++
+		}
+	}
+	return revoked
+}
+
+func (r *memorySessionRepository) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	r.revoke(func(s models.Session) bool { return s.ID == id })
+	return nil
+}
+
+func (r *memorySessionRepository) RevokeAllForUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	return r.revoke(func(s models.Session) bool { return s.UserID == userID }), nil
+}
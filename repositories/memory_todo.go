@@ -0,0 +1,508 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/rank"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryTodoRepository struct {
+	mu    sync.RWMutex
+	todos map[primitive.ObjectID]models.Todo
+	order []primitive.ObjectID // insertion order
+}
+
+// NewMemoryTodoRepository returns a TodoRepository kept in memory
+func NewMemoryTodoRepository() TodoRepository {
+	return &memoryTodoRepository{todos: map[primitive.ObjectID]models.Todo{}}
+}
+
+func (r *memoryTodoRepository) Create(ctx context.Context, todo *models.Todo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if todo.ID.IsZero() {
+		todo.ID = primitive.NewObjectID()
+	}
+	stampCreated(&todo.Audit)
+	r.todos[todo.ID] = *todo
+	r.order = append(r.order, todo.ID)
+	return nil
+}
+
+func (r *memoryTodoRepository) filter(match func(models.Todo) bool) []models.Todo {
+	todos := []models.Todo{}
+	for _, id := range r.order {
+		if todo := r.todos[id]; match(todo) {
+			todos = append(todos, todo)
+		}
+	}
+	return todos
+}
+
+func (r *memoryTodoRepository) FindAll(ctx context.Context) ([]models.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filter(func(models.Todo) bool { return true }), nil
+}
+
+func (r *memoryTodoRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, ok := r.todos[id]
+	if !ok {
+		return models.Todo{}, ErrNotFound
+	}
+	return todo, nil
+}
+
+func (r *memoryTodoRepository) FindByUserID(ctx context.Context, userID primitive.ObjectID) ([]models.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filter(func(t models.Todo) bool { return t.UserID == userID }), nil
+}
+
+func (r *memoryTodoRepository) FindByListID(ctx context.Context, listID primitive.ObjectID) ([]models.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filter(func(t models.Todo) bool { return sameList(t.ListID, listID) }), nil
+}
+
+// visible reports whether the user owns or collaborates on the todo,
+// or the todo is in one of sharedLists
+func visible(todo models.Todo, userID primitive.ObjectID, sharedLists []primitive.ObjectID) bool {
+	if todo.UserID == userID || slices.ContainsFunc(todo.Collaborators, func(c models.Collaborator) bool { return c.UserID == userID }) {
+		return true
+	}
+	return todo.ListID != nil && slices.Contains(sharedLists, *todo.ListID)
+}
+
+// sameList reports whether a todo's list is listID, a zero listID is the inbox
+func sameList(list *primitive.ObjectID, listID primitive.ObjectID) bool {
+	if list == nil {
+		return listID.IsZero()
+	}
+	return *list == listID
+}
+
+func (r *memoryTodoRepository) Find(ctx context.Context, query TodoQuery, page PageRequest) (Page[models.Todo], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	terms := strings.Fields(strings.ToLower(query.Search))
+	scores := map[primitive.ObjectID]int{}
+	todos := r.filter(func(t models.Todo) bool {
+		if query.UserID != nil && t.UserID != *query.UserID {
+			return false
+		}
+		if query.VisibleTo != nil && !visible(t, *query.VisibleTo, query.SharedLists) {
+			return false
+		}
+		if query.ListID != nil && !sameList(t.ListID, *query.ListID) {
+			return false
+		}
+		if query.Completed != nil && t.Completed != *query.Completed {
+			return false
+		}
+		for _, tag := range query.Tags {
+			if !slices.Contains(t.Tags, tag) {
+				return false
+			}
+		}
+		if len(query.Priorities) > 0 && !slices.Contains(query.Priorities, t.Priority) {
+			return false
+		}
+		if query.DueAfter != nil && (t.DueAt == nil || t.DueAt.Before(*query.DueAfter)) {
+			return false
+		}
+		if query.DueBefore != nil && (t.DueAt == nil || !t.DueAt.Before(*query.DueBefore)) {
+			return false
+		}
+		if len(terms) > 0 {
+			scores[t.ID] = searchScore(t, terms)
+			return scores[t.ID] > 0
+		}
+		return true
+	})
+
+	keys := todoKeys(query)
+	values := todoSortValues(keys)
+	return paginate(todos, keys, page, func(t models.Todo) ([]any, primitive.ObjectID) {
+		return values(t, float64(scores[t.ID]))
+	})
+}
+
+// searchScore approximates the text index: the number of terms that start
+// a word of the title or description, ignoring case
+func searchScore(todo models.Todo, terms []string) int {
+	words := strings.FieldsFunc(strings.ToLower(todo.Title+" "+todo.Description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	score := 0
+	for _, term := range terms {
+		if slices.ContainsFunc(words, func(w string) bool { return strings.HasPrefix(w, term) }) {
+			score++
+		}
+	}
+	return score
+}
+
+func (r *memoryTodoRepository) FindByImage(ctx context.Context, key string) (models.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, id := range r.order {
+		todo := r.todos[id]
+		if todo.Image == key {
+			return todo, nil
+		}
+		for _, variant := range todo.ImageVariants {
+			if variant == key {
+				return todo, nil
+			}
+		}
+	}
+	return models.Todo{}, ErrNotFound
+}
+
+func (r *memoryTodoRepository) Update(ctx context.Context, id primitive.ObjectID, update TodoUpdate) (models.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.todos[id]
+	if !ok {
+		return models.Todo{}, ErrNotFound
+	}
+	if update.Title != nil {
+		todo.Title = *update.Title
+	}
+	if update.Description != nil {
+		todo.Description = *update.Description
+	}
+	if update.Completed != nil {
+		if *update.Completed && !todo.Completed {
+			completedAt := now()
+			todo.CompletedAt = &completedAt
+		} else if !*update.Completed {
+			todo.CompletedAt = nil
+		}
+		todo.Completed = *update.Completed
+	}
+	if update.DueAt != nil {
+		todo.DueAt = nil
+		if !update.DueAt.IsZero() {
+			dueAt := *update.DueAt
+			todo.DueAt = &dueAt
+		}
+	}
+	if update.DueTimezone != nil {
+		todo.DueTimezone = *update.DueTimezone
+	}
+	if update.Priority != nil {
+		todo.Priority = *update.Priority
+	}
+	if update.Tags != nil {
+		todo.Tags = update.Tags
+	}
+	if update.AutoComplete != nil {
+		todo.AutoComplete = *update.AutoComplete
+	}
+	if update.Recurrence != nil {
+		todo.Recurrence = nil
+		if update.Recurrence.Rule != "" {
+			recurrence := *update.Recurrence
+			todo.Recurrence = &recurrence
+		}
+	}
+	if update.ListID != nil {
+		todo.ListID = nil
+		if !update.ListID.IsZero() {
+			listID := *update.ListID
+			todo.ListID = &listID
+		}
+	}
+	if update.Rank != nil {
+		todo.Rank = *update.Rank
+	}
+	if update.Image != nil {
+		todo.Image = *update.Image
+	}
+	if update.ImageVariants != nil {
+		todo.ImageVariants = update.ImageVariants
+	}
+	todo.UpdatedAt = now()
+	if update.UpdatedBy != nil {
+		todo.UpdatedBy = update.UpdatedBy
+	}
+	r.todos[id] = todo
+	return todo, nil
+}
+
+func (r *memoryTodoRepository) LinkNext(ctx context.Context, id, nextID primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.todos[id]
+	if !ok || todo.Recurrence == nil || todo.Recurrence.NextID != nil {
+		return ErrNotFound
+	}
+	recurrence := *todo.Recurrence
+	recurrence.NextID = &nextID
+	todo.Recurrence = &recurrence
+	todo.UpdatedAt = now()
+	r.todos[id] = todo
+	return nil
+}
+
+// changeItems applies fn to a copy of the todo's checklist and stamps the todo
+func (r *memoryTodoRepository) changeItems(id primitive.ObjectID, by *primitive.ObjectID, fn func(items []models.ChecklistItem) ([]models.ChecklistItem, error)) (models.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.todos[id]
+	if !ok {
+		return models.Todo{}, ErrNotFound
+	}
+	items, err := fn(slices.Clone(todo.Items))
+	if err != nil {
+		return models.Todo{}, err
+	}
+	todo.Items = items
+	todo.UpdatedAt = now()
+	if by != nil {
+		todo.UpdatedBy = by
+	}
+	r.todos[id] = todo
+	return todo, nil
+}
+
+func (r *memoryTodoRepository) AddItem(ctx context.Context, todoID primitive.ObjectID, item *models.ChecklistItem, position int, by *primitive.ObjectID) (models.Todo, error) {
+	if item.ID.IsZero() {
+		item.ID = primitive.NewObjectID()
+	}
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = now()
+	}
+	return r.changeItems(todoID, by, func(items []models.ChecklistItem) ([]models.ChecklistItem, error) {
+		if position < 0 || position > len(items) {
+			position = len(items)
+		}
+		return slices.Insert(items, position, *item), nil
+	})
+}
+
+func (r *memoryTodoRepository) UpdateItem(ctx context.Context, todoID, itemID primitive.ObjectID, update ItemUpdate) (models.Todo, error) {
+	return r.changeItems(todoID, update.UpdatedBy, func(items []models.ChecklistItem) ([]models.ChecklistItem, error) {
+		i := slices.IndexFunc(items, func(item models.ChecklistItem) bool { return item.ID == itemID })
+		if i < 0 {
+			return nil, ErrNotFound
+		}
+		item := items[i]
+		if update.Title != nil {
+			item.Title = *update.Title
+		}
+		if update.Completed != nil {
+			if *update.Completed && !item.Completed {
+				completedAt := now()
+				item.CompletedAt = &completedAt
+			} else if !*update.Completed {
+				item.CompletedAt = nil
+			}
+			item.Completed = *update.Completed
+		}
+		items[i] = item
+		if update.Position != nil {
+			items = slices.Delete(items, i, i+1)
+			position := min(max(*update.Position, 0), len(items))
+			items = slices.Insert(items, position, item)
+		}
+		return items, nil
+	})
+}
+
+func (r *memoryTodoRepository) DeleteItem(ctx context.Context, todoID, itemID primitive.ObjectID, by *primitive.ObjectID) (models.Todo, error) {
+	return r.changeItems(todoID, by, func(items []models.ChecklistItem) ([]models.ChecklistItem, error) {
+		i := slices.IndexFunc(items, func(item models.ChecklistItem) bool { return item.ID == itemID })
+		if i < 0 {
+			return nil, ErrNotFound
+		}
+		return slices.Delete(items, i, i+1), nil
+	})
+}
+
+func (r *memoryTodoRepository) MoveToInbox(ctx context.Context, listID primitive.ObjectID, by *primitive.ObjectID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var moved int64
+	for id, todo := range r.todos {
+		if todo.ListID == nil || *todo.ListID != listID {
+			continue
+		}
+		todo.ListID = nil
+		todo.UpdatedAt = now()
+		if by != nil {
+			todo.UpdatedBy = by
+		}
+		r.todos[id] = todo
+		moved++
+	}
+	return moved, nil
+}
+
+func (r *memoryTodoRepository) SetCollaborator(ctx context.Context, todoID primitive.ObjectID, collaborator models.Collaborator) (models.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.todos[todoID]
+	if !ok {
+		return models.Todo{}, ErrNotFound
+	}
+	todo.Collaborators = setCollaborator(todo.Collaborators, collaborator)
+	r.todos[todoID] = todo
+	return todo, nil
+}
+
+func (r *memoryTodoRepository) RemoveCollaborator(ctx context.Context, todoID, userID primitive.ObjectID) (models.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.todos[todoID]
+	if !ok {
+		return models.Todo{}, ErrNotFound
+	}
+	todo.Collaborators = removeCollaborator(todo.Collaborators, userID)
+	r.todos[todoID] = todo
+	return todo, nil
+}
+
+// setCollaborator returns a copy of collaborators with collaborator added or replaced
+func setCollaborator(collaborators []models.Collaborator, collaborator models.Collaborator) []models.Collaborator {
+	collaborators = removeCollaborator(collaborators, collaborator.UserID)
+	return append(collaborators, collaborator)
+}
+
+// removeCollaborator returns a copy of collaborators without the user, nil when empty
+func removeCollaborator(collaborators []models.Collaborator, userID primitive.ObjectID) []models.Collaborator {
+	var kept []models.Collaborator
+	for _, c := range collaborators {
+		if c.UserID != userID {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+func (r *memoryTodoRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.todos[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.todos, id)
+	for i, oid := range r.order {
+		if oid == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *memoryTodoRepository) Count(ctx context.Context) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return int64(len(r.todos)), nil
+}
+
+func (r *memoryTodoRepository) CountByUserID(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return int64(len(r.filter(func(t models.Todo) bool { return t.UserID == userID }))), nil
+}
+
+// rankOrder compares todos by rank, then id
+func rankOrder(a, b models.Todo) int {
+	if c := strings.Compare(a.Rank, b.Rank); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+// ranked returns the scope's todos in rank order
+func (r *memoryTodoRepository) ranked(scope RankScope) []models.Todo {
+	todos := r.filter(scope.Contains)
+	slices.SortFunc(todos, rankOrder)
+	return todos
+}
+
+func (r *memoryTodoRepository) Neighbour(ctx context.Context, scope RankScope, at *models.Todo, before bool, skip primitive.ObjectID) (models.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todos := r.ranked(scope)
+	if before {
+		slices.Reverse(todos)
+	}
+	for _, todo := range todos {
+		if todo.ID == skip {
+			continue
+		}
+		if at == nil || (before && rankOrder(todo, *at) < 0) || (!before && rankOrder(todo, *at) > 0) {
+			return todo, nil
+		}
+	}
+	return models.Todo{}, ErrNotFound
+}
+
+func (r *memoryTodoRepository) Rebalance(ctx context.Context, scope RankScope) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todos := r.ranked(scope)
+	var changed int64
+	for i, key := range rank.Spread(len(todos)) {
+		if todo := todos[i]; todo.Rank != key {
+			todo.Rank = key
+			r.todos[todo.ID] = todo
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+func (r *memoryTodoRepository) LongRanks(ctx context.Context, length int) ([]RankScope, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	scopes := []RankScope{}
+	for _, todo := range r.filter(func(t models.Todo) bool { return len(t.Rank) > length }) {
+		scope := ScopeOf(todo)
+		if !slices.ContainsFunc(scopes, scope.equal) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// equal reports whether both are the same scope
+func (s RankScope) equal(other RankScope) bool {
+	if s.ListID == nil || other.ListID == nil {
+		return s.ListID == other.ListID && s.UserID == other.UserID
+	}
+	return *s.ListID == *other.ListID
+}
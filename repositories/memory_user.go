@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[primitive.ObjectID]models.User
+	order []primitive.ObjectID // insertion order
+}
+
+// NewMemoryUserRepository returns a UserRepository kept in memory
+func NewMemoryUserRepository() UserRepository {
+	return &memoryUserRepository{users: map[primitive.ObjectID]models.User{}}
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	user.Email = utils.NormalizeEmail(user.Email)
+	user.UsernameKey = utils.NormalizeUsername(user.Username)
+	if err := r.checkUnique(*user); err != nil {
+		return err
+	}
+	stampCreated(&user.Audit)
+	r.users[user.ID] = *user
+	r.order = append(r.order, user.ID)
+	return nil
+}
+
+// checkUnique mirrors the unique indexes of the mongo collection
+func (r *memoryUserRepository) checkUnique(user models.User) error {
+	for id, other := range r.users {
+		if id == user.ID {
+			continue
+		}
+		if other.Email == user.Email {
+			return &DuplicateError{Field: "email"}
+		}
+		if other.UsernameKey == user.UsernameKey {
+			return &DuplicateError{Field: "username"}
+		}
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) FindAll(ctx context.Context) ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := []models.User{}
+	for _, id := range r.order {
+		users = append(users, r.users[id])
+	}
+	return users, nil
+}
+
+func (r *memoryUserRepository) FindPage(ctx context.Context, query UserQuery, page PageRequest) (Page[models.User], error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := []models.User{}
+	for _, id := range r.order {
+		user := r.users[id]
+		if query.CreatedAfter != nil && user.CreatedAt.Before(*query.CreatedAfter) {
+			continue
+		}
+		if query.CreatedBefore != nil && !user.CreatedAt.Before(*query.CreatedBefore) {
+			continue
+		}
+		users = append(users, user)
+	}
+
+	keys := userKeys(query)
+	values := userSortValues(keys)
+	return paginate(users, keys, page, func(u models.User) ([]any, primitive.ObjectID) {
+		return values(u, 0)
+	})
+}
+
+func (r *memoryUserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (r *memoryUserRepository) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	email = utils.NormalizeEmail(email)
+	for _, id := range r.order {
+		if r.users[id].Email == email {
+			return r.users[id], nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (r *memoryUserRepository) FindByUsername(ctx context.Context, username string) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key := utils.NormalizeUsername(username)
+	for _, id := range r.order {
+		if r.users[id].UsernameKey == key {
+			return r.users[id], nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (r *memoryUserRepository) Update(ctx context.Context, id primitive.ObjectID, update UserUpdate) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	if update.Username != nil {
+		user.Username = *update.Username
+		user.UsernameKey = utils.NormalizeUsername(*update.Username)
+	}
+	if update.Email != nil {
+		user.Email = utils.NormalizeEmail(*update.Email)
+	}
+	if err := r.checkUnique(user); err != nil {
+		return models.User{}, err
+	}
+	if update.Role != nil {
+		user.Role = *update.Role
+	}
+	if update.Password != nil {
+		user.Password = *update.Password
+	}
+	user.UpdatedAt = now()
+	if update.UpdatedBy != nil {
+		user.UpdatedBy = update.UpdatedBy
+	}
+	r.users[id] = user
+	return user, nil
+}
+
+func (r *memoryUserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.users, id)
+	for i, oid := range r.order {
+		if oid == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type mongoAttachmentRepository struct {
+	collection *mongo.Collection
+	// usage holds one {_id: userId, bytes} counter per uploader
+	usage *mongo.Collection
+}
+
+// NewMongoAttachmentRepository returns an AttachmentRepository backed by the
+// collection, per user usage counters are kept in usage
+func NewMongoAttachmentRepository(collection, usage *mongo.Collection) AttachmentRepository {
+	return &mongoAttachmentRepository{collection: collection, usage: usage}
+}
+
+// reserve adds size to the user's counter in a single update, so concurrent
+// uploads can't both slip under the quota
+func (r *mongoAttachmentRepository) reserve(ctx context.Context, userID primitive.ObjectID, size, quota int64) error {
+	if quota > 0 && size > quota {
+		return ErrQuotaExceeded
+	}
+	filter := bson.M{"_id": userID}
+	if quota > 0 {
+		filter["bytes"] = bson.M{"$lte": quota - size}
+	}
+	_, err := r.usage.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"bytes": size}}, options.UpdateOne().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// the counter exists but is too high, so the upsert tried to insert it again
+		return ErrQuotaExceeded
+	}
+	return err
+}
+
+func (r *mongoAttachmentRepository) release(ctx context.Context, userID primitive.ObjectID, size int64) error {
+	_, err := r.usage.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{"$inc": bson.M{"bytes": -size}})
+	return err
+}
+
+func (r *mongoAttachmentRepository) Create(ctx context.Context, attachment *models.Attachment, quota int64) error {
+	if attachment.ID.IsZero() {
+		attachment.ID = primitive.NewObjectID()
+	}
+	if attachment.UploadedAt.IsZero() {
+		attachment.UploadedAt = now()
+	}
+	if err := r.reserve(ctx, attachment.UploadedBy, attachment.Size, quota); err != nil {
+		return err
+	}
+	if _, err := r.collection.InsertOne(ctx, attachment); err != nil {
+		r.release(ctx, attachment.UploadedBy, attachment.Size)
+		return err
+	}
+	return nil
+}
+
+func (r *mongoAttachmentRepository) find(ctx context.Context, filter bson.M) ([]models.Attachment, error) {
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	attachments := []models.Attachment{}
+	if err := cursor.All(ctx, &attachments); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+func (r *mongoAttachmentRepository) findOne(ctx context.Context, filter bson.M) (models.Attachment, error) {
+	var attachment models.Attachment
+	err := r.collection.FindOne(ctx, filter).Decode(&attachment)
+	if err == mongo.ErrNoDocuments {
+		return attachment, ErrNotFound
+	}
+	return attachment, err
+}
+
+func (r *mongoAttachmentRepository) FindAll(ctx context.Context) ([]models.Attachment, error) {
+	return r.find(ctx, bson.M{})
+}
+
+func (r *mongoAttachmentRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Attachment, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoAttachmentRepository) FindByTodoID(ctx context.Context, todoID primitive.ObjectID) ([]models.Attachment, error) {
+	return r.find(ctx, bson.M{"todoId": todoID})
+}
+
+func (r *mongoAttachmentRepository) FindPageByTodoID(ctx context.Context, todoID primitive.ObjectID, page PageRequest) (Page[models.Attachment], error) {
+	return findPage(ctx, r.collection, bson.M{"todoId": todoID}, attachmentKeys, page, attachmentSortValues)
+}
+
+func (r *mongoAttachmentRepository) FindByKey(ctx context.Context, key string) (models.Attachment, error) {
+	return r.findOne(ctx, bson.M{"key": key})
+}
+
+func (r *mongoAttachmentRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	var deleted models.Attachment
+	err := r.collection.FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&deleted)
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return r.release(ctx, deleted.UploadedBy, deleted.Size)
+}
+
+func (r *mongoAttachmentRepository) Usage(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	var usage struct {
+		Bytes int64 `bson:"bytes"`
+	}
+	err := r.usage.FindOne(ctx, bson.M{"_id": userID}).Decode(&usage)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return usage.Bytes, err
+}
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// invitationUniqueIndexes maps the unique indexes created by the migrations
+// to the field reported in a DuplicateError
+var invitationUniqueIndexes = map[string]string{
+	"resourceId_userId_pending": "user",
+}
+
+type mongoInvitationRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoInvitationRepository returns an InvitationRepository backed by the collection
+func NewMongoInvitationRepository(collection *mongo.Collection) InvitationRepository {
+	return &mongoInvitationRepository{collection: collection}
+}
+
+func (r *mongoInvitationRepository) Create(ctx context.Context, invitation *models.Invitation) error {
+	if invitation.ID.IsZero() {
+		invitation.ID = primitive.NewObjectID()
+	}
+	if invitation.Status == "" {
+		invitation.Status = models.InvitationPending
+	}
+	if invitation.CreatedAt.IsZero() {
+		invitation.CreatedAt = now()
+	}
+	_, err := r.collection.InsertOne(ctx, invitation)
+	return duplicateKeyError(err, invitationUniqueIndexes)
+}
+
+func (r *mongoInvitationRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Invitation, error) {
+	var invitation models.Invitation
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&invitation)
+	if err == mongo.ErrNoDocuments {
+		return invitation, ErrNotFound
+	}
+	return invitation, err
+}
+
+func (r *mongoInvitationRepository) FindPage(ctx context.Context, query InvitationQuery, page PageRequest) (Page[models.Invitation], error) {
+	filter := bson.M{"userId": query.UserID}
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+	return findPage(ctx, r.collection, filter, invitationKeys, page, invitationSortValues)
+}
+
+func (r *mongoInvitationRepository) FindPending(ctx context.Context, resourceID primitive.ObjectID) ([]models.Invitation, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"resourceId": resourceID, "status": models.InvitationPending})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	invitations := []models.Invitation{}
+	if err := cursor.All(ctx, &invitations); err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+func (r *mongoInvitationRepository) Respond(ctx context.Context, id primitive.ObjectID, status string) (models.Invitation, error) {
+	var invitation models.Invitation
+	// only a pending invitation can be answered, so it is answered once
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": models.InvitationPending},
+		bson.M{"$set": bson.M{"status": status, "respondedAt": now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&invitation)
+	if err == mongo.ErrNoDocuments {
+		return invitation, ErrNotFound
+	}
+	return invitation, err
+}
+
+func (r *mongoInvitationRepository) Reopen(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": bson.M{"$ne": models.InvitationPending}},
+		bson.M{"$set": bson.M{"status": models.InvitationPending}, "$unset": bson.M{"respondedAt": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoInvitationRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoInvitationRepository) DeleteByResource(ctx context.Context, resourceID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"resourceId": resourceID})
+	return err
+}
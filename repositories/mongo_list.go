@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	bsonv2 "go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type mongoListRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoListRepository returns a ListRepository backed by the collection
+func NewMongoListRepository(collection *mongo.Collection) ListRepository {
+	return &mongoListRepository{collection: collection}
+}
+
+func (r *mongoListRepository) Create(ctx context.Context, list *models.List) error {
+	if list.ID.IsZero() {
+		list.ID = primitive.NewObjectID()
+	}
+	if list.Position < 0 {
+		var last models.List
+		err := r.collection.FindOne(ctx, bson.M{"userId": list.UserID},
+			options.FindOne().SetSort(bsonv2.D{{Key: "position", Value: -1}})).Decode(&last)
+		switch {
+		case err == mongo.ErrNoDocuments:
+			list.Position = 0
+		case err != nil:
+			return err
+		default:
+			list.Position = last.Position + 1
+		}
+	}
+	stampCreated(&list.Audit)
+	_, err := r.collection.InsertOne(ctx, list)
+	return err
+}
+
+func (r *mongoListRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.List, error) {
+	var list models.List
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&list)
+	if err == mongo.ErrNoDocuments {
+		return list, ErrNotFound
+	}
+	return list, err
+}
+
+func (r *mongoListRepository) FindPage(ctx context.Context, query ListQuery, page PageRequest) (Page[models.List], error) {
+	filter := bson.M{"userId": query.UserID}
+	if query.Shared {
+		filter = bson.M{"$or": bson.A{filter, bson.M{"collaborators.userId": query.UserID}}}
+	}
+	if query.Archived != nil {
+		filter["archived"] = *query.Archived
+	}
+	return findPage(ctx, r.collection, filter, listKeys, page, listSortValues)
+}
+
+func (r *mongoListRepository) FindSharedWith(ctx context.Context, userID primitive.ObjectID) ([]models.List, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"collaborators.userId": userID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	lists := []models.List{}
+	if err := cursor.All(ctx, &lists); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (r *mongoListRepository) Update(ctx context.Context, id primitive.ObjectID, update ListUpdate) (models.List, error) {
+	set := bson.M{}
+	if update.Name != nil {
+		set["name"] = *update.Name
+	}
+	if update.Color != nil {
+		set["color"] = *update.Color
+	}
+	if update.Archived != nil {
+		set["archived"] = *update.Archived
+	}
+	if update.Position != nil {
+		set["position"] = *update.Position
+	}
+
+	if len(set) > 0 {
+		set["updatedAt"] = now()
+		if update.UpdatedBy != nil {
+			set["updatedBy"] = *update.UpdatedBy
+		}
+
+		result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+		if err != nil {
+			return models.List{}, err
+		}
+		if result.MatchedCount == 0 {
+			return models.List{}, ErrNotFound
+		}
+	}
+
+	return r.FindByID(ctx, id)
+}
+
+// collaboratorUpdate runs update on the list and returns it
+func (r *mongoListRepository) collaboratorUpdate(ctx context.Context, listID primitive.ObjectID, update any) (models.List, error) {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": listID}, update)
+	if err != nil {
+		return models.List{}, err
+	}
+	if result.MatchedCount == 0 {
+		return models.List{}, ErrNotFound
+	}
+	return r.FindByID(ctx, listID)
+}
+
+func (r *mongoListRepository) SetCollaborator(ctx context.Context, listID primitive.ObjectID, collaborator models.Collaborator) (models.List, error) {
+	return r.collaboratorUpdate(ctx, listID, setCollaboratorPipeline(collaborator))
+}
+
+func (r *mongoListRepository) RemoveCollaborator(ctx context.Context, listID, userID primitive.ObjectID) (models.List, error) {
+	return r.collaboratorUpdate(ctx, listID, bson.M{"$pull": bson.M{"collaborators": bson.M{"userId": userID}}})
+}
+
+func (r *mongoListRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type mongoReminderRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoReminderRepository returns a ReminderRepository backed by the collection
+func NewMongoReminderRepository(collection *mongo.Collection) ReminderRepository {
+	return &mongoReminderRepository{collection: collection}
+}
+
+func (r *mongoReminderRepository) Create(ctx context.Context, reminder *models.Reminder) error {
+	if reminder.ID.IsZero() {
+		reminder.ID = primitive.NewObjectID()
+	}
+	if reminder.Status == "" {
+		reminder.Status = models.ReminderPending
+	}
+	if reminder.CreatedAt.IsZero() {
+		reminder.CreatedAt = now()
+	}
+	_, err := r.collection.InsertOne(ctx, reminder)
+	return err
+}
+
+func (r *mongoReminderRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Reminder, error) {
+	var reminder models.Reminder
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&reminder)
+	if err == mongo.ErrNoDocuments {
+		return reminder, ErrNotFound
+	}
+	return reminder, err
+}
+
+func (r *mongoReminderRepository) FindByTodo(ctx context.Context, todoID, userID primitive.ObjectID) ([]models.Reminder, error) {
+	filter := bson.M{"todoId": todoID}
+	if !userID.IsZero() {
+		filter["userId"] = userID
+	}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	reminders := []models.Reminder{}
+	if err := cursor.All(ctx, &reminders); err != nil {
+		return nil, err
+	}
+	return reminders, nil
+}
+
+func (r *mongoReminderRepository) Reschedule(ctx context.Context, todoID primitive.ObjectID, dueAt *time.Time) error {
+	filter := bson.M{"todoId": todoID, "minutesBefore": bson.M{"$exists": true}, "status": models.ReminderPending}
+	if dueAt == nil {
+		_, err := r.collection.UpdateMany(ctx, filter, bson.M{"$unset": bson.M{"fireAt": ""}})
+		return err
+	}
+
+	// fireAt = dueAt - minutesBefore, computed for each reminder
+	fireAt := bson.M{"$subtract": bson.A{*dueAt, bson.M{"$multiply": bson.A{"$minutesBefore", 60 * 1000}}}}
+	if _, err := r.collection.UpdateMany(ctx, filter, []bson.M{{"$set": bson.M{"fireAt": fireAt}}}); err != nil {
+		return err
+	}
+
+	// sent reminders whose new time is ahead are armed again
+	filter["status"] = models.ReminderSent
+	filter["$expr"] = bson.M{"$gt": bson.A{fireAt, now()}}
+	_, err := r.collection.UpdateMany(ctx, filter, []bson.M{
+		{"$set": bson.M{"fireAt": fireAt, "status": models.ReminderPending, "attempts": 0}},
+		{"$unset": bson.A{"sentAt", "lastError"}},
+	})
+	return err
+}
+
+func (r *mongoReminderRepository) Lease(ctx context.Context, at time.Time, owner string, until time.Time) (models.Reminder, error) {
+	filter := bson.M{
+		"status": models.ReminderPending,
+		"fireAt": bson.M{"$lte": at},
+		"$or": bson.A{
+			bson.M{"leaseUntil": bson.M{"$exists": false}},
+			bson.M{"leaseUntil": bson.M{"$lte": at}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{"leaseOwner": owner, "leaseUntil": until},
+		"$inc": bson.M{"attempts": 1},
+	}
+
+	// the update is atomic, so only one instance gets the reminder
+	var reminder models.Reminder
+	err := r.collection.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "fireAt", Value: 1}}).SetReturnDocument(options.After),
+	).Decode(&reminder)
+	if err == mongo.ErrNoDocuments {
+		return reminder, ErrNotFound
+	}
+	return reminder, err
+}
+
+func (r *mongoReminderRepository) Finish(ctx context.Context, id primitive.ObjectID, owner string, result ReminderResult) error {
+	set := bson.M{"status": result.Status}
+	unset := bson.M{"leaseOwner": ""}
+	if result.Error != "" {
+		set["lastError"] = result.Error
+	} else {
+		unset["lastError"] = ""
+	}
+	switch result.Status {
+	case models.ReminderSent:
+		set["sentAt"] = now()
+		unset["leaseUntil"] = ""
+	case models.ReminderPending:
+		// the lease doubles as the retry delay
+		set["leaseUntil"] = result.RetryAt
+	default:
+		unset["leaseUntil"] = ""
+	}
+
+	res, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "leaseOwner": owner}, bson.M{"$set": set, "$unset": unset})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoReminderRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoReminderRepository) DeleteByTodo(ctx context.Context, todoID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"todoId": todoID})
+	return err
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type mongoSessionRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoSessionRepository returns a SessionRepository backed by the collection
+func NewMongoSessionRepository(collection *mongo.Collection) SessionRepository {
+	return &mongoSessionRepository{collection: collection}
+}
+
+func (r *mongoSessionRepository) Create(ctx context.Context, session *models.Session) error {
+	if session.ID.IsZero() {
+		session.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, session)
+	return err
+}
+
+func (r *mongoSessionRepository) findOne(ctx context.Context, filter bson.M) (models.Session, error) {
+	var session models.Session
+	err := r.collection.FindOne(ctx, filter).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return session, ErrNotFound
+	}
+	return session, err
+}
+
+func (r *mongoSessionRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Session, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoSessionRepository) FindByRefreshHash(ctx context.Context, hash string) (models.Session, error) {
+	return r.findOne(ctx, bson.M{"refreshHash": hash})
+}
+
+func (r *mongoSessionRepository) FindByUsedHash(ctx context.Context, hash string) (models.Session, error) {
+	return r.findOne(ctx, bson.M{"usedHashes": hash})
+}
+
+func (r *mongoSessionRepository) Rotate(ctx context.Context, id primitive.ObjectID, oldHash, newHash string) error {
+	// the filter on the old hash guards against two concurrent
+	// refreshes with the same token both succeeding
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "refreshHash": oldHash, "revoked": false},
+		bson.M{
+			"$set":  bson.M{"refreshHash": newHash, "lastUsedAt": time.Now()},
+			"$push": bson.M{"usedHashes": oldHash},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoSessionRepository) revoke(ctx context.Context, filter bson.M) (int64, error) {
+	filter["revoked"] = false
+	result, err := r.collection.UpdateMany(ctx, filter, bson.M{
+		"$set": bson.M{"revoked": true, "revokedAt": time.Now()},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *mongoSessionRepository) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.revoke(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (r *mongoSessionRepository) RevokeAllForUser(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	return r.revoke(ctx, bson.M{"userId": userID})
+}
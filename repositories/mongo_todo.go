@@ -0,0 +1,480 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/rank"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type mongoTodoRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoTodoRepository returns a TodoRepository backed by the collection
+func NewMongoTodoRepository(collection *mongo.Collection) TodoRepository {
+	return &mongoTodoRepository{collection: collection}
+}
+
+func (r *mongoTodoRepository) Create(ctx context.Context, todo *models.Todo) error {
+	if todo.ID.IsZero() {
+		todo.ID = primitive.NewObjectID()
+	}
+	stampCreated(&todo.Audit)
+	_, err := r.collection.InsertOne(ctx, todo)
+	return err
+}
+
+func (r *mongoTodoRepository) find(ctx context.Context, filter bson.M) ([]models.Todo, error) {
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	todos := []models.Todo{}
+	if err := cursor.All(ctx, &todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (r *mongoTodoRepository) FindAll(ctx context.Context) ([]models.Todo, error) {
+	return r.find(ctx, bson.M{})
+}
+
+func (r *mongoTodoRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.Todo, error) {
+	var todo models.Todo
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&todo)
+	if err == mongo.ErrNoDocuments {
+		return todo, ErrNotFound
+	}
+	return todo, err
+}
+
+func (r *mongoTodoRepository) FindByImage(ctx context.Context, key string) (models.Todo, error) {
+	var todo models.Todo
+	err := r.collection.FindOne(ctx, bson.M{"$or": bson.A{
+		bson.M{"image": key},
+		// variant names are configurable, so match on the values of the map
+		bson.M{"$expr": bson.M{"$in": bson.A{key, bson.M{"$map": bson.M{
+			"input": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$imageVariants", bson.M{}}}},
+			"in":    "$$this.v",
+		}}}}},
+	}}).Decode(&todo)
+	if err == mongo.ErrNoDocuments {
+		return todo, ErrNotFound
+	}
+	return todo, err
+}
+
+func (r *mongoTodoRepository) FindByUserID(ctx context.Context, userID primitive.ObjectID) ([]models.Todo, error) {
+	return r.find(ctx, bson.M{"userId": userID})
+}
+
+func (r *mongoTodoRepository) FindByListID(ctx context.Context, listID primitive.ObjectID) ([]models.Todo, error) {
+	return r.find(ctx, bson.M{"listId": listFilter(listID)})
+}
+
+// listFilter matches the listId of a list's todos, a zero id matches the inbox
+func listFilter(listID primitive.ObjectID) any {
+	if listID.IsZero() {
+		return nil
+	}
+	return listID
+}
+
+func (r *mongoTodoRepository) Find(ctx context.Context, query TodoQuery, page PageRequest) (Page[models.Todo], error) {
+	// only typed values reach the filter, so query input can't add operators
+	filter := bson.M{}
+	if query.UserID != nil {
+		filter["userId"] = *query.UserID
+	}
+	if query.VisibleTo != nil {
+		// each clause is indexed, which $text requires inside $or
+		filter["$or"] = bson.A{
+			bson.M{"userId": *query.VisibleTo},
+			bson.M{"collaborators.userId": *query.VisibleTo},
+			bson.M{"listId": bson.M{"$in": append([]primitive.ObjectID{}, query.SharedLists...)}},
+		}
+	}
+	if query.ListID != nil {
+		filter["listId"] = listFilter(*query.ListID)
+	}
+	if query.Completed != nil {
+		filter["completed"] = *query.Completed
+	}
+	if len(query.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": query.Tags}
+	}
+	if len(query.Priorities) > 0 {
+		filter["priority"] = bson.M{"$in": query.Priorities}
+	}
+	due := bson.M{}
+	if query.DueAfter != nil {
+		due["$gte"] = *query.DueAfter
+	}
+	if query.DueBefore != nil {
+		due["$lt"] = *query.DueBefore
+	}
+	if len(due) > 0 {
+		filter["dueAt"] = due
+	}
+	if query.Search != "" {
+		// uses the text index on title and description
+		filter["$text"] = bson.M{"$search": query.Search}
+	}
+
+	keys := todoKeys(query)
+	return findPage(ctx, r.collection, filter, keys, page, todoSortValues(keys))
+}
+
+func (r *mongoTodoRepository) Update(ctx context.Context, id primitive.ObjectID, update TodoUpdate) (models.Todo, error) {
+	set := bson.M{}
+	var unset []string
+	if update.Title != nil {
+		set["title"] = *update.Title
+	}
+	if update.Description != nil {
+		set["description"] = *update.Description
+	}
+	if update.Completed != nil {
+		set["completed"] = *update.Completed
+	}
+	if update.DueAt != nil {
+		if update.DueAt.IsZero() {
+			unset = append(unset, "dueAt")
+		} else {
+			set["dueAt"] = *update.DueAt
+		}
+	}
+	if update.DueTimezone != nil {
+		set["dueTimezone"] = *update.DueTimezone
+	}
+	if update.Priority != nil {
+		set["priority"] = *update.Priority
+	}
+	if update.Tags != nil {
+		set["tags"] = update.Tags
+	}
+	if update.AutoComplete != nil {
+		set["autoComplete"] = *update.AutoComplete
+	}
+	if update.Recurrence != nil {
+		if update.Recurrence.Rule == "" {
+			unset = append(unset, "recurrence")
+		} else {
+			set["recurrence"] = *update.Recurrence
+		}
+	}
+	if update.ListID != nil {
+		if update.ListID.IsZero() {
+			unset = append(unset, "listId")
+		} else {
+			set["listId"] = *update.ListID
+		}
+	}
+	if update.Rank != nil {
+		set["rank"] = *update.Rank
+	}
+	if update.Image != nil {
+		set["image"] = *update.Image
+	}
+	if update.ImageVariants != nil {
+		set["imageVariants"] = update.ImageVariants
+	}
+
+	if len(set) == 0 && len(unset) == 0 {
+		return r.FindByID(ctx, id)
+	}
+	set["updatedAt"] = now()
+	if update.UpdatedBy != nil {
+		set["updatedBy"] = *update.UpdatedBy
+	}
+
+	// an update pipeline, so completedAt can depend on the stored completed
+	// flag; values are wrapped in $literal so strings starting with "$"
+	// are never read as field paths
+	stage := bson.M{}
+	for field, value := range set {
+		stage[field] = bson.M{"$literal": value}
+	}
+	if update.Completed != nil {
+		if *update.Completed {
+			// keep the original time when it was already completed
+			stage["completedAt"] = bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$completed", true}}, "$completedAt", set["updatedAt"],
+			}}
+		} else {
+			unset = append(unset, "completedAt")
+		}
+	}
+	pipeline := []bson.M{{"$set": stage}}
+	if len(unset) > 0 {
+		pipeline = append(pipeline, bson.M{"$unset": unset})
+	}
+
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, pipeline)
+	if err != nil {
+		return models.Todo{}, err
+	}
+	if result.MatchedCount == 0 {
+		return models.Todo{}, ErrNotFound
+	}
+	return r.FindByID(ctx, id)
+}
+
+// stamp returns the audit fields every checklist change sets on the todo
+func stamp(by *primitive.ObjectID) bson.M {
+	set := bson.M{"updatedAt": now()}
+	if by != nil {
+		set["updatedBy"] = *by
+	}
+	return set
+}
+
+// itemUpdate runs update on the todo holding the item, or on the todo itself when itemID is nil
+func (r *mongoTodoRepository) itemUpdate(ctx context.Context, todoID primitive.ObjectID, itemID *primitive.ObjectID, update any) (models.Todo, error) {
+	filter := bson.M{"_id": todoID}
+	if itemID != nil {
+		filter["items._id"] = *itemID
+	}
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return models.Todo{}, err
+	}
+	if result.MatchedCount == 0 {
+		return models.Todo{}, ErrNotFound
+	}
+	return r.FindByID(ctx, todoID)
+}
+
+func (r *mongoTodoRepository) AddItem(ctx context.Context, todoID primitive.ObjectID, item *models.ChecklistItem, position int, by *primitive.ObjectID) (models.Todo, error) {
+	if item.ID.IsZero() {
+		item.ID = primitive.NewObjectID()
+	}
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = now()
+	}
+	push := bson.M{"$each": bson.A{item}}
+	if position >= 0 {
+		// positions past the end append
+		push["$position"] = position
+	}
+	return r.itemUpdate(ctx, todoID, nil, bson.M{"$push": bson.M{"items": push}, "$set": stamp(by)})
+}
+
+func (r *mongoTodoRepository) UpdateItem(ctx context.Context, todoID, itemID primitive.ObjectID, update ItemUpdate) (models.Todo, error) {
+	changes := bson.M{}
+	if update.Title != nil {
+		changes["title"] = bson.M{"$literal": *update.Title}
+	}
+	if update.Completed != nil {
+		changes["completed"] = *update.Completed
+		changes["completedAt"] = nil
+		if *update.Completed {
+			// keep the original time when it was already completed
+			changes["completedAt"] = bson.M{"$cond": bson.A{"$$this.completed", "$$this.completedAt", now()}}
+		}
+	}
+
+	set := bson.M{}
+	for field, value := range stamp(update.UpdatedBy) {
+		set[field] = bson.M{"$literal": value}
+	}
+	if len(changes) > 0 {
+		set["items"] = bson.M{"$map": bson.M{"input": "$items", "in": bson.M{"$cond": bson.A{
+			bson.M{"$eq": bson.A{"$$this._id", itemID}},
+			bson.M{"$mergeObjects": bson.A{"$$this", changes}},
+			"$$this",
+		}}}}
+	}
+	pipeline := []bson.M{{"$set": set}}
+	if update.Position != nil {
+		pipeline = append(pipeline, bson.M{"$set": bson.M{"items": moveItem(itemID, max(*update.Position, 0))}})
+	}
+	return r.itemUpdate(ctx, todoID, &itemID, pipeline)
+}
+
+// moveItem is the items array with the item taken out and put back at position
+func moveItem(itemID primitive.ObjectID, position int) bson.M {
+	return bson.M{"$let": bson.M{
+		"vars": bson.M{
+			"item": bson.M{"$first": bson.M{"$filter": bson.M{"input": "$items", "cond": bson.M{"$eq": bson.A{"$$this._id", itemID}}}}},
+			"rest": bson.M{"$filter": bson.M{"input": "$items", "cond": bson.M{"$ne": bson.A{"$$this._id", itemID}}}},
+		},
+		"in": bson.M{"$concatArrays": bson.A{
+			bson.M{"$slice": bson.A{"$$rest", position}},
+			bson.A{"$$item"},
+			bson.M{"$slice": bson.A{"$$rest", position, bson.M{"$add": bson.A{bson.M{"$size": "$$rest"}, 1}}}},
+		}},
+	}}
+}
+
+func (r *mongoTodoRepository) DeleteItem(ctx context.Context, todoID, itemID primitive.ObjectID, by *primitive.ObjectID) (models.Todo, error) {
+	return r.itemUpdate(ctx, todoID, &itemID, bson.M{"$pull": bson.M{"items": bson.M{"_id": itemID}}, "$set": stamp(by)})
+}
+
+func (r *mongoTodoRepository) LinkNext(ctx context.Context, id, nextID primitive.ObjectID) error {
+	// the filter makes sure only one next occurrence is ever linked
+	filter := bson.M{"_id": id, "recurrence": bson.M{"$exists": true}, "recurrence.nextId": bson.M{"$exists": false}}
+	set := stamp(nil)
+	set["recurrence.nextId"] = nextID
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoTodoRepository) MoveToInbox(ctx context.Context, listID primitive.ObjectID, by *primitive.ObjectID) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx, bson.M{"listId": listID}, bson.M{
+		"$set":   stamp(by),
+		"$unset": bson.M{"listId": ""},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *mongoTodoRepository) SetCollaborator(ctx context.Context, todoID primitive.ObjectID, collaborator models.Collaborator) (models.Todo, error) {
+	return r.itemUpdate(ctx, todoID, nil, setCollaboratorPipeline(collaborator))
+}
+
+func (r *mongoTodoRepository) RemoveCollaborator(ctx context.Context, todoID, userID primitive.ObjectID) (models.Todo, error) {
+	return r.itemUpdate(ctx, todoID, nil, bson.M{"$pull": bson.M{"collaborators": bson.M{"userId": userID}}})
+}
+
+// setCollaboratorPipeline replaces the collaborator's entry, or appends it, in one update
+func setCollaboratorPipeline(collaborator models.Collaborator) []bson.M {
+	return []bson.M{{"$set": bson.M{"collaborators": bson.M{"$concatArrays": bson.A{
+		bson.M{"$filter": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$collaborators", bson.A{}}},
+			"cond":  bson.M{"$ne": bson.A{"$$this.userId", collaborator.UserID}},
+		}},
+		bson.A{bson.M{"$literal": collaborator}},
+	}}}}}
+}
+
+func (r *mongoTodoRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoTodoRepository) Count(ctx context.Context) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{})
+}
+
+func (r *mongoTodoRepository) CountByUserID(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"userId": userID})
+}
+
+// scopeFilter matches the todos ranked in the scope
+func scopeFilter(scope RankScope) bson.M {
+	if scope.ListID != nil {
+		return bson.M{"listId": *scope.ListID}
+	}
+	return bson.M{"userId": scope.UserID, "listId": bson.M{"$exists": false}}
+}
+
+func (r *mongoTodoRepository) Neighbour(ctx context.Context, scope RankScope, at *models.Todo, before bool, skip primitive.ObjectID) (models.Todo, error) {
+	filter := scopeFilter(scope)
+	filter["_id"] = bson.M{"$ne": skip}
+	op, dir := "$gt", 1
+	if before {
+		op, dir = "$lt", -1
+	}
+	if at != nil {
+		filter["$or"] = bson.A{
+			bson.M{"rank": bson.M{op: at.Rank}},
+			bson.M{"rank": at.Rank, "_id": bson.M{op: at.ID}},
+		}
+	}
+
+	var todo models.Todo
+	err := r.collection.FindOne(ctx, filter,
+		options.FindOne().SetSort(bson.D{{Key: "rank", Value: dir}, {Key: "_id", Value: dir}}),
+	).Decode(&todo)
+	if err == mongo.ErrNoDocuments {
+		return todo, ErrNotFound
+	}
+	return todo, err
+}
+
+func (r *mongoTodoRepository) Rebalance(ctx context.Context, scope RankScope) (int64, error) {
+	cursor, err := r.collection.Find(ctx, scopeFilter(scope), options.Find().
+		SetSort(bson.D{{Key: "rank", Value: 1}, {Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"rank": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var todos []models.Todo
+	if err := cursor.All(ctx, &todos); err != nil {
+		return 0, err
+	}
+
+	// a todo moved in the meantime keeps its new rank
+	var writes []mongo.WriteModel
+	for i, key := range rank.Spread(len(todos)) {
+		if todos[i].Rank == key {
+			continue
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": todos[i].ID, "rank": todos[i].Rank}).
+			SetUpdate(bson.M{"$set": bson.M{"rank": key}}))
+	}
+	if len(writes) == 0 {
+		return 0, nil
+	}
+	result, err := r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *mongoTodoRepository) LongRanks(ctx context.Context, length int) ([]RankScope, error) {
+	// inbox todos are grouped by owner, list todos by list alone
+	cursor, err := r.collection.Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"$expr": bson.M{"$gt": bson.A{bson.M{"$strLenBytes": bson.M{"$ifNull": bson.A{"$rank", ""}}}, length}}}},
+		{"$group": bson.M{"_id": bson.M{
+			"listId": "$listId",
+			"userId": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$listId", nil}}, nil}}, "$userId", nil}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		Scope struct {
+			ListID *primitive.ObjectID `bson:"listId"`
+			UserID *primitive.ObjectID `bson:"userId"`
+		} `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	scopes := make([]RankScope, len(groups))
+	for i, g := range groups {
+		scopes[i] = RankScope{ListID: g.Scope.ListID}
+		if g.Scope.ListID == nil && g.Scope.UserID != nil {
+			scopes[i].UserID = *g.Scope.UserID
+		}
+	}
+	return scopes, nil
+}
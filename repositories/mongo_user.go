@@ -0,0 +1,155 @@
+package repositories
+
+import (
+	"context"
+	"strings"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// userUniqueIndexes maps the unique indexes created by the migrations
+// to the field reported in a DuplicateError
+var userUniqueIndexes = map[string]string{
+	"email_unique":       "email",
+	"usernameKey_unique": "username",
+}
+
+// duplicateKeyError turns a duplicate key error into a *DuplicateError
+// naming the field, other errors are returned unchanged
+func duplicateKeyError(err error, indexes map[string]string) error {
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+	for index, field := range indexes {
+		if strings.Contains(err.Error(), "index: "+index+" ") {
+			return &DuplicateError{Field: field}
+		}
+	}
+	return &DuplicateError{}
+}
+
+type mongoUserRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoUserRepository returns a UserRepository backed by the collection
+func NewMongoUserRepository(collection *mongo.Collection) UserRepository {
+	return &mongoUserRepository{collection: collection}
+}
+
+func (r *mongoUserRepository) Create(ctx context.Context, user *models.User) error {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	user.Email = utils.NormalizeEmail(user.Email)
+	user.UsernameKey = utils.NormalizeUsername(user.Username)
+	stampCreated(&user.Audit)
+	_, err := r.collection.InsertOne(ctx, user)
+	return duplicateKeyError(err, userUniqueIndexes)
+}
+
+func (r *mongoUserRepository) find(ctx context.Context, filter bson.M, opts ...options.Lister[options.FindOptions]) ([]models.User, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *mongoUserRepository) FindAll(ctx context.Context) ([]models.User, error) {
+	return r.find(ctx, bson.M{})
+}
+
+func (r *mongoUserRepository) FindPage(ctx context.Context, query UserQuery, page PageRequest) (Page[models.User], error) {
+	filter := bson.M{}
+	created := bson.M{}
+	if query.CreatedAfter != nil {
+		created["$gte"] = *query.CreatedAfter
+	}
+	if query.CreatedBefore != nil {
+		created["$lt"] = *query.CreatedBefore
+	}
+	if len(created) > 0 {
+		filter["createdAt"] = created
+	}
+
+	keys := userKeys(query)
+	return findPage(ctx, r.collection, filter, keys, page, userSortValues(keys))
+}
+
+func (r *mongoUserRepository) findOne(ctx context.Context, filter bson.M) (models.User, error) {
+	var user models.User
+	err := r.collection.FindOne(ctx, filter).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return user, ErrNotFound
+	}
+	return user, err
+}
+
+func (r *mongoUserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (models.User, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoUserRepository) FindByEmail(ctx context.Context, email string) (models.User, error) {
+	return r.findOne(ctx, bson.M{"email": utils.NormalizeEmail(email)})
+}
+
+func (r *mongoUserRepository) FindByUsername(ctx context.Context, username string) (models.User, error) {
+	return r.findOne(ctx, bson.M{"usernameKey": utils.NormalizeUsername(username)})
+}
+
+func (r *mongoUserRepository) Update(ctx context.Context, id primitive.ObjectID, update UserUpdate) (models.User, error) {
+	set := bson.M{}
+	if update.Username != nil {
+		set["username"] = *update.Username
+		set["usernameKey"] = utils.NormalizeUsername(*update.Username)
+	}
+	if update.Email != nil {
+		set["email"] = utils.NormalizeEmail(*update.Email)
+	}
+	if update.Role != nil {
+		set["role"] = *update.Role
+	}
+	if update.Password != nil {
+		set["password"] = *update.Password
+	}
+
+	if len(set) > 0 {
+		set["updatedAt"] = now()
+		if update.UpdatedBy != nil {
+			set["updatedBy"] = *update.UpdatedBy
+		}
+
+		result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+		if err != nil {
+			return models.User{}, duplicateKeyError(err, userUniqueIndexes)
+		}
+		if result.MatchedCount == 0 {
+			return models.User{}, ErrNotFound
+		}
+	}
+
+	return r.FindByID(ctx, id)
+}
+
+func (r *mongoUserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+package repositories
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	bsonv2 "go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// ErrInvalidCursor is returned for a cursor that can't be decoded or was
+// made for a different order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SortKey is one field of a listing's order
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+func (k SortKey) String() string {
+	if k.Desc {
+		return "-" + k.Field
+	}
+	return k.Field
+}
+
+// Cursor is a position in a listing: the sort values and id of the item it
+// was taken from. Encode makes it opaque for clients.
+type Cursor struct {
+	// Keys is the order the cursor belongs to, e.g. "-priority"
+	Keys   []string           `bson:"k"`
+	Values []any              `bson:"v"`
+	ID     primitive.ObjectID `bson:"i"`
+	// Before selects the items preceding the position instead of following it
+	Before bool `bson:"b,omitempty"`
+}
+
+// Encode returns the cursor as an opaque URL safe string
+func (c Cursor) Encode() string {
+	data, err := bson.Marshal(c)
+	if err != nil {
+		// the values come from documents, so they always marshal
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor reads a cursor made by Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := bson.Unmarshal(data, &c); err != nil || len(c.Keys) != len(c.Values) {
+		return nil, ErrInvalidCursor
+	}
+	for i, v := range c.Values {
+		switch v := v.(type) {
+		case primitive.DateTime:
+			c.Values[i] = v.Time().UTC()
+		case int32:
+			c.Values[i] = int64(v)
+		case nil, int64, float64, string:
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &c, nil
+}
+
+const (
+	// DefaultPageLimit is used when a PageRequest has no limit
+	DefaultPageLimit = 20
+	// MaxPageLimit caps PageRequest.Limit
+	MaxPageLimit = 100
+)
+
+// PageRequest selects one page of a listing
+type PageRequest struct {
+	Limit int64
+	// Cursor continues from a previous page, Skip is used without one
+	Cursor *Cursor
+	Skip   int64
+	// Total also counts every match
+	Total bool
+}
+
+func (p PageRequest) limit() int64 {
+	if p.Limit <= 0 {
+		return DefaultPageLimit
+	}
+	return min(p.Limit, MaxPageLimit)
+}
+
+// Page is one page of a listing
+type Page[T any] struct {
+	Items []T
+	// Next and Prev lead to the neighbouring pages, nil at either end
+	Next *Cursor
+	Prev *Cursor
+	// Total is the number of matches, set when requested
+	Total *int64
+}
+
+// ordering appends the id to keys, so every item has a distinct position
+func ordering(keys []SortKey) []SortKey {
+	desc := len(keys) > 0 && keys[len(keys)-1].Desc
+	return append(slices.Clone(keys), SortKey{Field: "_id", Desc: desc})
+}
+
+func keyNames(keys []SortKey) []string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.String()
+	}
+	return names
+}
+
+// checkCursor makes sure the cursor was made for this order
+func checkCursor(keys []SortKey, cursor *Cursor) error {
+	if cursor != nil && !slices.Equal(cursor.Keys, keyNames(keys)) {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// finishPage trims the one item fetched beyond the limit and sets the cursors.
+// items are in the listing's order; more reports whether that extra item exists.
+func finishPage[T any](items []T, page PageRequest, keys []SortKey, values func(T) ([]any, primitive.ObjectID)) Page[T] {
+	limit := page.limit()
+	backward := page.Cursor != nil && page.Cursor.Before
+	more := int64(len(items)) > limit
+	if more {
+		if backward {
+			items = items[1:]
+		} else {
+			items = items[:limit]
+		}
+	}
+
+	result := Page[T]{Items: items}
+	if len(items) == 0 {
+		return result
+	}
+	cursor := func(item T, before bool) *Cursor {
+		v, id := values(item)
+		return &Cursor{Keys: keyNames(keys), Values: v, ID: id, Before: before}
+	}
+	if (backward && more) || (!backward && (page.Cursor != nil || page.Skip > 0)) {
+		result.Prev = cursor(items[0], true)
+	}
+	if backward || more {
+		result.Next = cursor(items[len(items)-1], false)
+	}
+	return result
+}
+
+// compareValues orders sort values the way MongoDB does for the types used
+// here: missing values first, then numbers, strings and dates
+func compareValues(a, b any) int {
+	if c := cmp.Compare(valueRank(a), valueRank(b)); c != 0 {
+		return c
+	}
+	switch a := a.(type) {
+	case int64:
+		return cmp.Compare(float64(a), toFloat(b))
+	case float64:
+		return cmp.Compare(a, toFloat(b))
+	case string:
+		return strings.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case primitive.ObjectID:
+		o := b.(primitive.ObjectID)
+		return bytes.Compare(a[:], o[:])
+	}
+	return 0
+}
+
+func valueRank(v any) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case int64, float64:
+		return 1
+	case string:
+		return 2
+	case primitive.ObjectID:
+		return 3
+	case time.Time:
+		return 4
+	}
+	return 5
+}
+
+func toFloat(v any) float64 {
+	switch v := v.(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	}
+	return 0
+}
+
+// paginate pages through items in memory the same way the mongo
+// repositories do; values returns an item's sort values and id
+func paginate[T any](items []T, keys []SortKey, page PageRequest, values func(T) ([]any, primitive.ObjectID)) (Page[T], error) {
+	if err := checkCursor(keys, page.Cursor); err != nil {
+		return Page[T]{}, err
+	}
+	order := ordering(keys)
+	position := func(item T) []any {
+		v, id := values(item)
+		return append(v, id)
+	}
+	compare := func(a, b []any) int {
+		for i, k := range order {
+			c := compareValues(a[i], b[i])
+			if k.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	slices.SortStableFunc(items, func(a, b T) int { return compare(position(a), position(b)) })
+
+	total := int64(len(items))
+	limit := int(page.limit())
+	start := int(min(page.Skip, total))
+	if page.Cursor != nil {
+		// first item at or beyond the cursor
+		at := append(slices.Clone(page.Cursor.Values), any(page.Cursor.ID))
+		start, _ = slices.BinarySearchFunc(items, at, func(item T, at []any) int { return compare(position(item), at) })
+		if page.Cursor.Before {
+			items = items[max(0, start-limit-1):start]
+		} else if start < len(items) && compare(position(items[start]), at) == 0 {
+			start++
+		}
+	}
+	if page.Cursor == nil || !page.Cursor.Before {
+		items = items[start:min(len(items), start+limit+1)]
+	}
+
+	result := finishPage(items, page, keys, values)
+	if page.Total {
+		result.Total = &total
+	}
+	return result, nil
+}
+
+// keysetFilter matches the items after (or before) the cursor in the order
+func keysetFilter(order []SortKey, cursor *Cursor) bson.M {
+	values := append(slices.Clone(cursor.Values), any(cursor.ID))
+	var or bson.A
+	for i, k := range order {
+		// equal on every earlier key, beyond the cursor on this one
+		and := bson.A{}
+		for j := 0; j < i; j++ {
+			and = append(and, bson.M{order[j].Field: values[j]})
+		}
+		beyond := beyondFilter(k.Field, values[i], k.Desc != cursor.Before)
+		if beyond == nil {
+			continue
+		}
+		or = append(or, bson.M{"$and": append(and, beyond)})
+	}
+	if len(or) == 0 {
+		// nothing sorts beyond the cursor
+		return bson.M{"_id": bson.M{"$exists": false}}
+	}
+	return bson.M{"$or": or}
+}
+
+// beyondFilter matches values of field after value, or before it when
+// backward; missing values sort first like in MongoDB. nil means nothing matches.
+func beyondFilter(field string, value any, backward bool) bson.M {
+	if value == nil {
+		if backward {
+			return nil
+		}
+		return bson.M{field: bson.M{"$ne": nil}}
+	}
+	if backward {
+		return bson.M{"$or": bson.A{bson.M{field: bson.M{"$lt": value}}, bson.M{field: nil}}}
+	}
+	return bson.M{field: bson.M{"$gt": value}}
+}
+
+// scored decodes a document along with its text search score
+type scored[T any] struct {
+	Doc   T       `bson:",inline"`
+	Score float64 `bson:"score"`
+}
+
+// findPage runs a paged query on collection. The "score" sort key orders by
+// text search relevance; values returns an item's sort values and id.
+func findPage[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, keys []SortKey, page PageRequest, values func(T, float64) ([]any, primitive.ObjectID)) (Page[T], error) {
+	if err := checkCursor(keys, page.Cursor); err != nil {
+		return Page[T]{}, err
+	}
+	order := ordering(keys)
+	backward := page.Cursor != nil && page.Cursor.Before
+
+	pipeline := []bson.M{{"$match": filter}}
+	if slices.ContainsFunc(keys, func(k SortKey) bool { return k.Field == "score" }) {
+		pipeline = append(pipeline, bson.M{"$addFields": bson.M{"score": bson.M{"$meta": "textScore"}}})
+	}
+	if page.Cursor != nil {
+		pipeline = append(pipeline, bson.M{"$match": keysetFilter(order, page.Cursor)})
+	}
+	// the sort needs an ordered document, v1 bson.D is not understood by the v2 driver
+	sort := bsonv2.D{}
+	for _, k := range order {
+		direction := 1
+		if k.Desc != backward {
+			direction = -1
+		}
+		sort = append(sort, bsonv2.E{Key: k.Field, Value: direction})
+	}
+	pipeline = append(pipeline, bson.M{"$sort": sort})
+	if page.Cursor == nil && page.Skip > 0 {
+		pipeline = append(pipeline, bson.M{"$skip": page.Skip})
+	}
+	pipeline = append(pipeline, bson.M{"$limit": page.limit() + 1})
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	defer cursor.Close(ctx)
+	docs := []scored[T]{}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return Page[T]{}, err
+	}
+	if backward {
+		slices.Reverse(docs)
+	}
+
+	result := finishPage(docs, page, keys, func(d scored[T]) ([]any, primitive.ObjectID) {
+		return values(d.Doc, d.Score)
+	})
+	out := Page[T]{Items: make([]T, len(result.Items)), Next: result.Next, Prev: result.Prev}
+	for i, d := range result.Items {
+		out.Items[i] = d.Doc
+	}
+
+	if page.Total {
+		total, err := collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return Page[T]{}, err
+		}
+		out.Total = &total
+	}
+	return out, nil
+}
@@ -0,0 +1,371 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+var (
+	// ErrNotFound is returned when no document matches
+	ErrNotFound = errors.New("not found")
+	// ErrQuotaExceeded is returned when an upload would exceed the user's storage quota
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+// DuplicateError is returned when a unique field is already taken
+type DuplicateError struct {
+	// Field is the API name of the conflicting field, e.g. "email"
+	Field string
+}
+
+func (e *DuplicateError) Error() string {
+	return e.Field + " already exists"
+}
+
+// UserUpdate holds the user fields to change, nil fields are left untouched
+type UserUpdate struct {
+	Username *string
+	Email    *string
+	Role     *string
+	Password *string
+	// UpdatedBy is the user making the change, nil for system updates
+	UpdatedBy *primitive.ObjectID
+}
+
+// UserSortFields are the fields UserQuery.SortBy accepts
+var UserSortFields = []string{"createdAt", "updatedAt", "username", "email"}
+
+// UserQuery selects and orders users
+type UserQuery struct {
+	// SortBy is one of UserSortFields, defaults to createdAt
+	SortBy string
+	Desc   bool
+	// optional createdAt range, After is inclusive and Before exclusive
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// TodoSortFields are the fields TodoQuery.Sort accepts
+var TodoSortFields = []string{"rank", "createdAt", "updatedAt", "dueAt", "completedAt", "priority", "title"}
+
+// TodoQuery selects and orders todos, zero fields match everything
+type TodoQuery struct {
+	UserID *primitive.ObjectID
+	// VisibleTo matches the todos the user owns or collaborates on and every
+	// todo in SharedLists, the lists shared with the user
+	VisibleTo   *primitive.ObjectID
+	SharedLists []primitive.ObjectID
+	// ListID selects the todos of a list, a zero id those in the inbox
+	ListID    *primitive.ObjectID
+	Completed *bool
+	// Tags matches todos carrying every one of them
+	Tags []string
+	// Priorities matches todos with any of them
+	Priorities []int
+	// optional dueAt range, After is inclusive and Before exclusive
+	DueAfter  *time.Time
+	DueBefore *time.Time
+	// Search is matched against the words of the title and description,
+	// results are ordered by relevance unless Sort is set
+	Search string
+	// Sort uses TodoSortFields and is applied in order; defaults to rank,
+	// the order users arrange their todos in
+	Sort []SortKey
+}
+
+// RankScope is a set of todos ordered by rank: the todos of a list, or a
+// user's todos in the inbox when ListID is nil
+type RankScope struct {
+	UserID primitive.ObjectID
+	ListID *primitive.ObjectID
+}
+
+// ScopeOf returns the scope the todo is ranked in
+func ScopeOf(todo models.Todo) RankScope {
+	if todo.ListID != nil {
+		return RankScope{ListID: todo.ListID}
+	}
+	return RankScope{UserID: todo.UserID}
+}
+
+// Contains reports whether the todo is ranked in the scope
+func (s RankScope) Contains(todo models.Todo) bool {
+	if s.ListID != nil {
+		return todo.ListID != nil && *todo.ListID == *s.ListID
+	}
+	return todo.ListID == nil && todo.UserID == s.UserID
+}
+
+// TodoUpdate holds the todo fields to change, nil fields are left untouched
+type TodoUpdate struct {
+	Title       *string
+	Description *string
+	// Completed also sets completedAt when the todo becomes completed and clears it when reopened
+	Completed *bool
+	// DueAt sets the due date, a zero time clears it
+	DueAt       *time.Time
+	DueTimezone *string
+	Priority    *int
+	// Tags replaces the tags when not nil, an empty slice clears them
+	Tags         []string
+	AutoComplete *bool
+	// Recurrence replaces the recurrence, one with an empty rule clears it
+	Recurrence *models.Recurrence
+	// ListID moves the todo to a list, a zero id moves it to the inbox
+	ListID *primitive.ObjectID
+	// Rank sets the todo's place among the todos of its list or inbox
+	Rank  *string
+	Image *string
+	// ImageVariants replaces the variants when not nil
+	ImageVariants map[string]string
+	// UpdatedBy is the user making the change, nil for system updates
+	UpdatedBy *primitive.ObjectID
+}
+
+// Empty reports whether the update changes nothing
+func (u TodoUpdate) Empty() bool {
+	return u.Title == nil && u.Description == nil && u.Completed == nil && u.DueAt == nil &&
+		u.DueTimezone == nil && u.Priority == nil && u.Tags == nil && u.AutoComplete == nil && u.Recurrence == nil && u.ListID == nil && u.Rank == nil && u.Image == nil
+}
+
+// ItemUpdate holds the checklist item fields to change, nil fields are left untouched
+type ItemUpdate struct {
+	Title *string
+	// Completed also sets completedAt when the item becomes completed and clears it when reopened
+	Completed *bool
+	// Position moves the item to this index of the checklist, clamped to its length
+	Position *int
+	// UpdatedBy is the user making the change, stamped on the todo
+	UpdatedBy *primitive.ObjectID
+}
+
+// ListQuery selects a user's lists, ordered by position
+type ListQuery struct {
+	UserID primitive.ObjectID
+	// Shared also selects the lists shared with the user
+	Shared bool
+	// Archived selects archived or active lists, nil selects both
+	Archived *bool
+}
+
+// ListUpdate holds the list fields to change, nil fields are left untouched
+type ListUpdate struct {
+	Name     *string
+	Color    *string
+	Archived *bool
+	Position *int
+	// UpdatedBy is the user making the change, nil for system updates
+	UpdatedBy *primitive.ObjectID
+}
+
+// InvitationQuery selects a user's invitations, oldest first
+type InvitationQuery struct {
+	UserID primitive.ObjectID
+	// Status is one of the models.Invitation statuses, empty selects every status
+	Status string
+}
+
+// UserRepository persists users.
+// Emails are stored normalized and emails and usernames are unique ignoring case,
+// Create and Update return a *DuplicateError when one is taken.
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	FindAll(ctx context.Context) ([]models.User, error)
+	// FindPage returns a page of the users matching the query, ties are broken by id
+	FindPage(ctx context.Context, query UserQuery, page PageRequest) (Page[models.User], error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.User, error)
+	// FindByEmail matches the normalized email
+	FindByEmail(ctx context.Context, email string) (models.User, error)
+	// FindByUsername matches the username ignoring case
+	FindByUsername(ctx context.Context, username string) (models.User, error)
+	Update(ctx context.Context, id primitive.ObjectID, update UserUpdate) (models.User, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// TodoRepository persists todos
+type TodoRepository interface {
+	Create(ctx context.Context, todo *models.Todo) error
+	FindAll(ctx context.Context) ([]models.Todo, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.Todo, error)
+	FindByUserID(ctx context.Context, userID primitive.ObjectID) ([]models.Todo, error)
+	FindByListID(ctx context.Context, listID primitive.ObjectID) ([]models.Todo, error)
+	// Find returns a page of the todos matching the query, ties are broken by id
+	Find(ctx context.Context, query TodoQuery, page PageRequest) (Page[models.Todo], error)
+	// FindByImage finds the todo whose image or one of its variants is stored under key
+	FindByImage(ctx context.Context, key string) (models.Todo, error)
+	Update(ctx context.Context, id primitive.ObjectID, update TodoUpdate) (models.Todo, error)
+	// AddItem inserts item into the todo's checklist at position (clamped,
+	// negative appends) and returns the todo; by is stamped as its last editor
+	AddItem(ctx context.Context, todoID primitive.ObjectID, item *models.ChecklistItem, position int, by *primitive.ObjectID) (models.Todo, error)
+	// UpdateItem changes a checklist item and returns the todo,
+	// ErrNotFound when the todo or the item doesn't exist
+	UpdateItem(ctx context.Context, todoID, itemID primitive.ObjectID, update ItemUpdate) (models.Todo, error)
+	// DeleteItem removes a checklist item and returns the todo
+	DeleteItem(ctx context.Context, todoID, itemID primitive.ObjectID, by *primitive.ObjectID) (models.Todo, error)
+	// SetCollaborator adds the collaborator or changes their role, and returns the todo
+	SetCollaborator(ctx context.Context, todoID primitive.ObjectID, collaborator models.Collaborator) (models.Todo, error)
+	// RemoveCollaborator takes the user off the todo's collaborators and returns the todo
+	RemoveCollaborator(ctx context.Context, todoID, userID primitive.ObjectID) (models.Todo, error)
+	// LinkNext records nextID as the todo of the recurring todo's next
+	// occurrence, ErrNotFound when the todo isn't recurring or already has one
+	LinkNext(ctx context.Context, id, nextID primitive.ObjectID) error
+	// MoveToInbox takes every todo out of the list and returns how many were moved
+	MoveToInbox(ctx context.Context, listID primitive.ObjectID, by *primitive.ObjectID) (int64, error)
+	// Neighbour returns the todo next to at in the scope's order (rank, then
+	// id), before or after it, leaving skip out. A nil at stands for the
+	// scope's end: the last todo before it, the first after it. ErrNotFound
+	// when there is none.
+	Neighbour(ctx context.Context, scope RankScope, at *models.Todo, before bool, skip primitive.ObjectID) (models.Todo, error)
+	// Rebalance spreads the ranks of the scope's todos evenly, keeping their
+	// order, and returns how many changed
+	Rebalance(ctx context.Context, scope RankScope) (int64, error)
+	// LongRanks returns the scopes holding a rank longer than length
+	LongRanks(ctx context.Context, length int) ([]RankScope, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	Count(ctx context.Context) (int64, error)
+	CountByUserID(ctx context.Context, userID primitive.ObjectID) (int64, error)
+}
+
+// ListRepository persists todo lists
+type ListRepository interface {
+	// Create saves the list, a negative position places it after the user's other lists
+	Create(ctx context.Context, list *models.List) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.List, error)
+	// FindPage returns a page of the user's lists by position, ties are broken by id
+	FindPage(ctx context.Context, query ListQuery, page PageRequest) (Page[models.List], error)
+	// FindSharedWith returns the lists the user collaborates on
+	FindSharedWith(ctx context.Context, userID primitive.ObjectID) ([]models.List, error)
+	Update(ctx context.Context, id primitive.ObjectID, update ListUpdate) (models.List, error)
+	// SetCollaborator adds the collaborator or changes their role, and returns the list
+	SetCollaborator(ctx context.Context, listID primitive.ObjectID, collaborator models.Collaborator) (models.List, error)
+	// RemoveCollaborator takes the user off the list's collaborators and returns the list
+	RemoveCollaborator(ctx context.Context, listID, userID primitive.ObjectID) (models.List, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// InvitationRepository persists sharing invitations.
+// A user has at most one pending invitation per resource, Create returns a
+// *DuplicateError for field "user" otherwise.
+type InvitationRepository interface {
+	Create(ctx context.Context, invitation *models.Invitation) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.Invitation, error)
+	// FindPage returns a page of the user's invitations, oldest first
+	FindPage(ctx context.Context, query InvitationQuery, page PageRequest) (Page[models.Invitation], error)
+	// FindPending returns the pending invitations to the resource
+	FindPending(ctx context.Context, resourceID primitive.ObjectID) ([]models.Invitation, error)
+	// Respond accepts or declines a pending invitation and returns it,
+	// ErrNotFound when it isn't pending anymore
+	Respond(ctx context.Context, id primitive.ObjectID, status string) (models.Invitation, error)
+	// Reopen makes an answered invitation pending again, for an answer that
+	// couldn't be carried out
+	Reopen(ctx context.Context, id primitive.ObjectID) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// DeleteByResource removes every invitation to a deleted resource
+	DeleteByResource(ctx context.Context, resourceID primitive.ObjectID) error
+}
+
+// ReminderResult is the outcome of a reminder's delivery attempt
+type ReminderResult struct {
+	// Status is final (sent, failed or cancelled), or pending to retry at RetryAt
+	Status  string
+	Error   string
+	RetryAt time.Time
+}
+
+// ReminderRepository persists reminders and leases the due ones to the
+// instance sending them, so several instances never send the same reminder
+type ReminderRepository interface {
+	Create(ctx context.Context, reminder *models.Reminder) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.Reminder, error)
+	// FindByTodo returns the todo's reminders for the user, or everyone's
+	// for a zero userID, oldest first
+	FindByTodo(ctx context.Context, todoID, userID primitive.ObjectID) ([]models.Reminder, error)
+	// Reschedule moves the todo's offset reminders to its new due date, a nil
+	// dueAt leaves them waiting for one. Sent reminders whose new time is
+	// still ahead are pending again.
+	Reschedule(ctx context.Context, todoID primitive.ObjectID, dueAt *time.Time) error
+	// Lease hands the earliest pending reminder due at now, with no lease or
+	// an expired one, to owner until the given time and counts the attempt;
+	// ErrNotFound when none is due
+	Lease(ctx context.Context, now time.Time, owner string, until time.Time) (models.Reminder, error)
+	// Finish records the result of a leased reminder and releases it,
+	// ErrNotFound when owner no longer holds the lease
+	Finish(ctx context.Context, id primitive.ObjectID, owner string, result ReminderResult) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// DeleteByTodo removes the reminders of a deleted todo
+	DeleteByTodo(ctx context.Context, todoID primitive.ObjectID) error
+}
+
+// AttachmentRepository persists attachment metadata and the storage used by each uploader
+type AttachmentRepository interface {
+	// Create saves the attachment and adds its size to the uploader's usage,
+	// ErrQuotaExceeded is returned when the usage would go above quota bytes (0 is unlimited)
+	Create(ctx context.Context, attachment *models.Attachment, quota int64) error
+	FindAll(ctx context.Context) ([]models.Attachment, error)
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.Attachment, error)
+	FindByTodoID(ctx context.Context, todoID primitive.ObjectID) ([]models.Attachment, error)
+	// FindPageByTodoID returns a page of the todo's attachments, oldest first
+	FindPageByTodoID(ctx context.Context, todoID primitive.ObjectID, page PageRequest) (Page[models.Attachment], error)
+	FindByKey(ctx context.Context, key string) (models.Attachment, error)
+	// Delete removes the attachment and releases its size from the uploader's usage
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// Usage is the total size of the user's attachments in bytes
+	Usage(ctx context.Context, userID primitive.ObjectID) (int64, error)
+}
+
+// SessionRepository persists refresh token sessions
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (models.Session, error)
+	FindByRefreshHash(ctx context.Context, hash string) (models.Session, error)
+	// FindByUsedHash finds the session an already rotated refresh token belonged to
+	FindByUsedHash(ctx context.Context, hash string) (models.Session, error)
+	// Rotate swaps oldHash for newHash, returns ErrNotFound if the session
+	// is revoked or oldHash is no longer current
+	Rotate(ctx context.Context, id primitive.ObjectID, oldHash, newHash string) error
+	Revoke(ctx context.Context, id primitive.ObjectID) error
+	RevokeAllForUser(ctx context.Context, userID primitive.ObjectID) (int64, error)
+}
+
+// Repositories groups every repository the handlers depend on
+type Repositories struct {
+	Users       UserRepository
+	Todos       TodoRepository
+	Lists       ListRepository
+	Invitations InvitationRepository
+	Reminders   ReminderRepository
+	Attachments AttachmentRepository
+	Sessions    SessionRepository
+}
+
+// NewMongoRepositories returns repositories backed by the given database
+func NewMongoRepositories(db *mongo.Database) *Repositories {
+	return &Repositories{
+		Users:       NewMongoUserRepository(db.Collection("users")),
+		Todos:       NewMongoTodoRepository(db.Collection("todos")),
+		Lists:       NewMongoListRepository(db.Collection("lists")),
+		Invitations: NewMongoInvitationRepository(db.Collection("invitations")),
+		Reminders:   NewMongoReminderRepository(db.Collection("reminders")),
+		Attachments: NewMongoAttachmentRepository(db.Collection("attachments"), db.Collection("storage_usage")),
+		Sessions:    NewMongoSessionRepository(db.Collection("sessions")),
+	}
+}
+
+// NewMemoryRepositories returns repositories kept in process memory,
+// useful for tests and running without MongoDB
+func NewMemoryRepositories() *Repositories {
+	return &Repositories{
+		Users:       NewMemoryUserRepository(),
+		Todos:       NewMemoryTodoRepository(),
+		Lists:       NewMemoryListRepository(),
+		Invitations: NewMemoryInvitationRepository(),
+		Reminders:   NewMemoryReminderRepository(),
+		Attachments: NewMemoryAttachmentRepository(),
+		Sessions:    NewMemorySessionRepository(),
+	}
+}
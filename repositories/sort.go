@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// todoSortValues returns a todo's values for keys, in the types compareValues
+// and cursors use; score is the text search relevance
+func todoSortValues(keys []SortKey) func(models.Todo, float64) ([]any, primitive.ObjectID) {
+	return func(t models.Todo, score float64) ([]any, primitive.ObjectID) {
+		values := make([]any, len(keys))
+		for i, k := range keys {
+			switch k.Field {
+			case "rank":
+				values[i] = t.Rank
+			case "createdAt":
+				values[i] = t.CreatedAt
+			case "updatedAt":
+				values[i] = t.UpdatedAt
+			case "dueAt":
+				values[i] = optionalTime(t.DueAt)
+			case "completedAt":
+				values[i] = optionalTime(t.CompletedAt)
+			case "priority":
+				values[i] = int64(t.Priority)
+			case "title":
+				values[i] = t.Title
+			case "score":
+				values[i] = score
+			}
+		}
+		return values, t.ID
+	}
+}
+
+// userSortValues returns a user's values for keys
+func userSortValues(keys []SortKey) func(models.User, float64) ([]any, primitive.ObjectID) {
+	return func(u models.User, _ float64) ([]any, primitive.ObjectID) {
+		values := make([]any, len(keys))
+		for i, k := range keys {
+			switch k.Field {
+			case "createdAt":
+				values[i] = u.CreatedAt
+			case "updatedAt":
+				values[i] = u.UpdatedAt
+			case "username":
+				values[i] = u.Username
+			case "email":
+				values[i] = u.Email
+			}
+		}
+		return values, u.ID
+	}
+}
+
+// attachmentKeys is the order of a todo's attachments
+var attachmentKeys = []SortKey{{Field: "uploadedAt"}}
+
+func attachmentSortValues(a models.Attachment, _ float64) ([]any, primitive.ObjectID) {
+	return []any{a.UploadedAt}, a.ID
+}
+
+// listKeys is the order of a user's lists
+var listKeys = []SortKey{{Field: "position"}}
+
+func listSortValues(l models.List, _ float64) ([]any, primitive.ObjectID) {
+	return []any{int64(l.Position)}, l.ID
+}
+
+// invitationKeys is the order of a user's invitations
+var invitationKeys = []SortKey{{Field: "createdAt"}}
+
+func invitationSortValues(i models.Invitation, _ float64) ([]any, primitive.ObjectID) {
+	return []any{i.CreatedAt}, i.ID
+}
+
+// optionalTime is nil for a missing time, like a missing field in MongoDB
+func optionalTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+// userKeys is the order of a UserQuery
+func userKeys(query UserQuery) []SortKey {
+	field := query.SortBy
+	if field == "" {
+		field = "createdAt"
+	}
+	return []SortKey{{Field: field, Desc: query.Desc}}
+}
+
+// todoKeys is the order of a TodoQuery, searches default to relevance
+func todoKeys(query TodoQuery) []SortKey {
+	switch {
+	case len(query.Sort) > 0:
+		return query.Sort
+	case query.Search != "":
+		return []SortKey{{Field: "score", Desc: true}}
+	}
+	return []SortKey{{Field: "rank"}}
+}
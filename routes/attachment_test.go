@@ -0,0 +1,157 @@
+package routes_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
+	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/storage"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var pdfContent = []byte("%PDF-1.4\n1 0 obj << >> endobj\ntrailer << >>\n%%EOF\n")
+
+// attach uploads a file to the todo and expects the status
+func (ta *testApp) attach(u testUser, todoID primitive.ObjectID, name string, content []byte, status int) models.Attachment {
+	ta.t.Helper()
+	resp := ta.multipart("POST", "/api/todo/"+todoID.Hex()+"/attachments", nil,
+		&upload{Field: "file", FileName: name, Content: content}, u.Token)
+	expectStatus(ta.t, resp, status)
+
+	var attachment models.Attachment
+	if status == http.StatusCreated {
+		decode(ta.t, resp, &attachment)
+	}
+	return attachment
+}
+
+func TestAttachments(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	todo := ta.createTodo(bob, "taxes", nil)
+	base := "/api/todo/" + todo.ID.Hex() + "/attachments"
+
+	report := ta.attach(bob, todo.ID, `..\reports/report 2024.pdf`, pdfContent, http.StatusCreated)
+	sum := sha256.Sum256(pdfContent)
+	if report.Name != "report 2024.pdf" || report.Size != int64(len(pdfContent)) || report.ContentType != "application/pdf" ||
+		report.Checksum != hex.EncodeToString(sum[:]) || report.UploadedBy != bob.ID || report.TodoID != todo.ID ||
+		report.UploadedAt.IsZero() {
+		t.Fatalf("unexpected attachment %+v", report)
+	}
+	if !strings.HasPrefix(report.URL, "/api/files/attachments/") {
+		t.Fatalf("expected a signed URL, got %q", report.URL)
+	}
+	notes := ta.attach(bob, todo.ID, "notes.txt", []byte("buy stamps\n"), http.StatusCreated)
+	if notes.ContentType != "text/plain" {
+		t.Fatalf("expected text/plain, got %q", notes.ContentType)
+	}
+
+	ta.attach(bob, todo.ID, "page.html", []byte("<html><script>alert(1)</script></html>"), http.StatusUnsupportedMediaType)
+	ta.attach(carol, todo.ID, "x.txt", []byte("x"), http.StatusForbidden)
+
+	var list controllers.ListResponse[models.Attachment]
+	resp := ta.request("GET", base, nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &list)
+	if len(list.Data) != 2 || list.Data[0].ID != report.ID || list.Data[1].URL == "" {
+		t.Fatalf("unexpected attachments %+v", list.Data)
+	}
+	resp = ta.request("GET", base, nil, carol.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	resp = ta.request("GET", base+"/"+report.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	resp = ta.request("GET", base+"/"+report.ID.Hex()+"/download", nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	if cd := resp.Header.Get("Content-Disposition"); cd != `attachment; filename="report 2024.pdf"` {
+		t.Fatalf("unexpected Content-Disposition %q", cd)
+	}
+	if got := readBody(t, resp); string(got) != string(pdfContent) {
+		t.Fatal("expected the uploaded file")
+	}
+
+	// the signed URL and the owner's token both work through /api/files
+	resp = ta.getFile(report.URL, "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.getFile("/api/files/"+ta.attachmentKey(report.ID), bob.Token, nil)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.getFile("/api/files/"+ta.attachmentKey(report.ID), carol.Token, nil)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	// attachments are only reachable through their own todo
+	other := ta.createTodo(bob, "other", nil)
+	resp = ta.request("GET", "/api/todo/"+other.ID.Hex()+"/attachments/"+report.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+
+	key := ta.attachmentKey(report.ID)
+	resp = ta.request("DELETE", base+"/"+report.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	if _, err := ta.files.Stat(context.Background(), key); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatal("expected the attachment file to be removed")
+	}
+	resp = ta.request("GET", base+"/"+report.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+
+	// deleting the todo removes the remaining attachments
+	key = ta.attachmentKey(notes.ID)
+	resp = ta.request("DELETE", "/api/todo/"+todo.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	if _, err := ta.repos.Attachments.FindByID(context.Background(), notes.ID); err == nil {
+		t.Fatal("expected the attachment to be deleted with its todo")
+	}
+	if _, err := ta.files.Stat(context.Background(), key); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatal("expected the attachment file to be deleted with its todo")
+	}
+}
+
+func TestAttachmentQuota(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	todo := ta.createTodo(bob, "taxes", nil)
+
+	quota := config.Cfg.AttachmentQuotaBytes
+	config.Cfg.AttachmentQuotaBytes = int64(2 * len(pdfContent))
+	t.Cleanup(func() { config.Cfg.AttachmentQuotaBytes = quota })
+
+	first := ta.attach(bob, todo.ID, "a.pdf", pdfContent, http.StatusCreated)
+	ta.attach(bob, todo.ID, "b.pdf", pdfContent, http.StatusCreated)
+
+	resp := ta.multipart("POST", "/api/todo/"+todo.ID.Hex()+"/attachments", nil,
+		&upload{Field: "file", FileName: "c.pdf", Content: pdfContent}, bob.Token)
+	expectStatus(t, resp, http.StatusRequestEntityTooLarge)
+	var p problem
+	decode(t, resp, &p)
+	if p.Code != apperrors.CodeQuotaExceeded {
+		t.Fatalf("expected quota_exceeded, got %q", p.Code)
+	}
+
+	// deleting frees the space again
+	resp = ta.request("DELETE", "/api/todo/"+todo.ID.Hex()+"/attachments/"+first.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	ta.attach(bob, todo.ID, "c.pdf", pdfContent, http.StatusCreated)
+
+	used, err := ta.repos.Attachments.Usage(context.Background(), bob.ID)
+	if err != nil || used != int64(2*len(pdfContent)) {
+		t.Fatalf("expected usage of two files, got %d (%v)", used, err)
+	}
+}
+
+// attachmentKey is the storage key of an attachment, it is not part of the API
+func (ta *testApp) attachmentKey(id primitive.ObjectID) string {
+	ta.t.Helper()
+	attachment, err := ta.repos.Attachments.FindByID(context.Background(), id)
+	if err != nil {
+		ta.t.Fatal(err)
+	}
+	return attachment.Key
+}
@@ -0,0 +1,100 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestLogin(t *testing.T) {
+	ta := newTestApp(t)
+	u := ta.register("alice", "alice@example.com")
+	if u.Token == "" || u.RefreshToken == "" {
+		t.Fatal("expected access and refresh tokens")
+	}
+
+	resp := ta.request("POST", "/api/login", controllers.LoginInput{Email: u.Email, Password: "wrong"}, "")
+	expectStatus(t, resp, http.StatusUnauthorized)
+
+	resp = ta.request("POST", "/api/login", controllers.LoginInput{Email: "nobody@example.com", Password: "x"}, "")
+	expectStatus(t, resp, http.StatusUnauthorized)
+}
+
+func TestProtectedRoutesRequireToken(t *testing.T) {
+	ta := newTestApp(t)
+
+	resp := ta.request("GET", "/api/users", nil, "")
+	expectStatus(t, resp, http.StatusUnauthorized)
+
+	resp = ta.request("GET", "/api/users", nil, "not-a-jwt")
+	expectStatus(t, resp, http.StatusUnauthorized)
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	ta := newTestApp(t)
+	u := ta.register("alice", "alice@example.com")
+
+	resp := ta.request("POST", "/api/token/refresh", controllers.RefreshInput{RefreshToken: u.RefreshToken}, "")
+	expectStatus(t, resp, http.StatusOK)
+	var rotated controllers.LoginResponse
+	decode(t, resp, &rotated)
+	if rotated.RefreshToken == u.RefreshToken {
+		t.Fatal("expected refresh token to rotate")
+	}
+
+	resp = ta.request("GET", "/api/user/"+u.ID.Hex(), nil, rotated.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	// replaying the old token revokes the whole family
+	resp = ta.request("POST", "/api/token/refresh", controllers.RefreshInput{RefreshToken: u.RefreshToken}, "")
+	expectStatus(t, resp, http.StatusUnauthorized)
+
+	resp = ta.request("POST", "/api/token/refresh", controllers.RefreshInput{RefreshToken: rotated.RefreshToken}, "")
+	expectStatus(t, resp, http.StatusUnauthorized)
+
+	resp = ta.request("GET", "/api/user/"+u.ID.Hex(), nil, rotated.Token)
+	expectStatus(t, resp, http.StatusUnauthorized)
+}
+
+func TestRefreshTokenInvalid(t *testing.T) {
+	ta := newTestApp(t)
+
+	resp := ta.request("POST", "/api/token/refresh", fiber.Map{}, "")
+	expectStatus(t, resp, http.StatusUnprocessableEntity)
+
+	resp = ta.request("POST", "/api/token/refresh", controllers.RefreshInput{RefreshToken: "bogus"}, "")
+	expectStatus(t, resp, http.StatusUnauthorized)
+}
+
+func TestLogout(t *testing.T) {
+	ta := newTestApp(t)
+	u := ta.register("alice", "alice@example.com")
+	other := ta.login(u.User)
+
+	resp := ta.request("POST", "/api/logout", nil, u.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	resp = ta.request("GET", "/api/user/"+u.ID.Hex(), nil, u.Token)
+	expectStatus(t, resp, http.StatusUnauthorized)
+
+	// the second session is untouched
+	resp = ta.request("GET", "/api/user/"+u.ID.Hex(), nil, other.Token)
+	expectStatus(t, resp, http.StatusOK)
+}
+
+func TestLogoutAll(t *testing.T) {
+	ta := newTestApp(t)
+	u := ta.register("alice", "alice@example.com")
+	other := ta.login(u.User)
+
+	resp := ta.request("POST", "/api/logout-all", nil, u.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	resp = ta.request("GET", "/api/user/"+u.ID.Hex(), nil, other.Token)
+	expectStatus(t, resp, http.StatusUnauthorized)
+
+	resp = ta.request("POST", "/api/token/refresh", controllers.RefreshInput{RefreshToken: other.RefreshToken}, "")
+	expectStatus(t, resp, http.StatusUnauthorized)
+}
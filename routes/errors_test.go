@@ -0,0 +1,66 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/clinton-mwachia/go-fiber-api-template/middlewares"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// problem is the RFC 7807 body rendered by middlewares.ErrorHandler
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail"`
+	Instance  string `json:"instance"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id"`
+}
+
+func TestErrorsAreProblemJSON(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+
+	path := "/api/user/" + primitive.NewObjectID().Hex()
+	resp := ta.request("GET", path, nil, bob.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+	if ct := resp.Header.Get("Content-Type"); ct != middlewares.MIMEProblemJSON {
+		t.Fatalf("expected %s, got %s", middlewares.MIMEProblemJSON, ct)
+	}
+
+	var p problem
+	decode(t, resp, &p)
+	if p.Status != http.StatusForbidden || p.Code != apperrors.CodeForbidden || p.Title != "Forbidden" {
+		t.Fatalf("unexpected problem %+v", p)
+	}
+	if p.Instance != path {
+		t.Fatalf("expected instance %s, got %s", path, p.Instance)
+	}
+	if p.RequestID == "" || p.RequestID != resp.Header.Get("X-Request-ID") {
+		t.Fatalf("expected request id to match header, got %q", p.RequestID)
+	}
+}
+
+func TestErrorsHideInternalDetails(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+
+	resp := ta.request("POST", "/api/login", controllers.LoginInput{Email: bob.Email, Password: "wrong"}, "")
+	expectStatus(t, resp, http.StatusUnauthorized)
+	var p problem
+	decode(t, resp, &p)
+	if p.Code != apperrors.CodeInvalidCredentials || p.Detail != "Invalid email or password" {
+		t.Fatalf("unexpected problem %+v", p)
+	}
+
+	resp = ta.request("GET", "/api/users", nil, "garbage")
+	expectStatus(t, resp, http.StatusUnauthorized)
+	decode(t, resp, &p)
+	if p.Code != apperrors.CodeInvalidToken || p.Detail != "Invalid token" {
+		t.Fatalf("unexpected problem %+v", p)
+	}
+}
@@ -0,0 +1,205 @@
+package routes_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
+	"github.com/clinton-mwachia/go-fiber-api-template/storage"
+	"github.com/gofiber/fiber/v2"
+)
+
+// stored returns the content of a stored file
+func (ta *testApp) stored(key string) []byte {
+	ta.t.Helper()
+	body, _, err := ta.files.Get(context.Background(), key)
+	if err != nil {
+		ta.t.Fatal(err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		ta.t.Fatal(err)
+	}
+	return data
+}
+
+// getFile requests path with optional extra headers
+func (ta *testApp) getFile(path, token string, headers map[string]string) *http.Response {
+	ta.t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return ta.do(req, token)
+}
+
+func readBody(t *testing.T, resp *http.Response) []byte {
+	t.Helper()
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestServeSignedFile(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	todo := ta.createTodo(bob, "one", imageUpload("pic.png"))
+
+	resp := ta.getFile(todo.ImageURLs["original"], "", nil)
+	expectStatus(t, resp, http.StatusOK)
+	if got := readBody(t, resp); string(got) != string(ta.stored(todo.Image)) {
+		t.Fatal("expected the stored image")
+	}
+	if resp.Header.Get("Content-Type") != "image/png" || resp.Header.Get("ETag") == "" || resp.Header.Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("unexpected headers %v", resp.Header)
+	}
+	if cc := resp.Header.Get("Cache-Control"); !strings.HasPrefix(cc, "private, max-age=") {
+		t.Fatalf("expected a private max-age, got %q", cc)
+	}
+
+	resp = ta.getFile(todo.ImageURLs["thumb"], "", nil)
+	expectStatus(t, resp, http.StatusOK)
+
+	// signature of another key
+	tampered := strings.Replace(todo.ImageURLs["original"], todo.Image, todo.ImageVariants["thumb"], 1)
+	resp = ta.getFile(tampered, "", nil)
+	expectStatus(t, resp, http.StatusForbidden)
+	var p problem
+	decode(t, resp, &p)
+	if p.Code != apperrors.CodeInvalidSignature {
+		t.Fatalf("expected invalid_signature, got %q", p.Code)
+	}
+
+	expired := storage.NewSigner("test-secret", "/api/files").Sign(todo.Image, -time.Minute)
+	resp = ta.getFile(expired, "", nil)
+	expectStatus(t, resp, http.StatusForbidden)
+	decode(t, resp, &p)
+	if p.Code != apperrors.CodeSignatureExpired {
+		t.Fatalf("expected signature_expired, got %q", p.Code)
+	}
+
+	resp = ta.getFile("/api/files/"+todo.Image, "", nil)
+	expectStatus(t, resp, http.StatusUnauthorized)
+}
+
+func TestServeFileOwnership(t *testing.T) {
+	ta := newTestApp(t)
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	todo := ta.createTodo(bob, "one", imageUpload("pic.png"))
+
+	resp := ta.getFile("/api/files/"+todo.Image, bob.Token, nil)
+	expectStatus(t, resp, http.StatusOK)
+	if cc := resp.Header.Get("Cache-Control"); cc != "private, no-cache" {
+		t.Fatalf("expected revalidation for owner access, got %q", cc)
+	}
+
+	resp = ta.getFile("/api/files/"+todo.ImageVariants["medium"], bob.Token, nil)
+	expectStatus(t, resp, http.StatusOK)
+
+	resp = ta.getFile("/api/files/"+todo.Image, admin.Token, nil)
+	expectStatus(t, resp, http.StatusOK)
+
+	resp = ta.getFile("/api/files/"+todo.Image, carol.Token, nil)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	resp = ta.getFile("/api/files/todos/missing.png", bob.Token, nil)
+	expectStatus(t, resp, http.StatusNotFound)
+
+	resp = ta.getFile("/api/files/todos/..%2F..%2Fetc%2Fpasswd", bob.Token, nil)
+	expectStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestServeFileCollaborators(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	dave := ta.register("dave", "dave@example.com")
+	eve := ta.register("eve", "eve@example.com")
+	todo := ta.createTodo(bob, "one", imageUpload("pic.png"))
+	list := ta.createList(bob, fiber.Map{"name": "Shared"})
+	resp := ta.request("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"listId": list.ID.Hex()}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	// whoever can view the todo, through the todo or its list, gets its files
+	ta.share(bob, carol, "/api/todo/"+todo.ID.Hex(), "viewer")
+	ta.share(bob, dave, "/api/list/"+list.ID.Hex(), "viewer")
+	for _, u := range []testUser{carol, dave} {
+		resp = ta.getFile("/api/files/"+todo.Image, u.Token, nil)
+		expectStatus(t, resp, http.StatusOK)
+		resp = ta.getFile("/api/files/"+todo.ImageVariants["thumb"], u.Token, nil)
+		expectStatus(t, resp, http.StatusOK)
+	}
+
+	resp = ta.getFile("/api/files/"+todo.Image, eve.Token, nil)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	// access ends with the share
+	resp = ta.request("DELETE", "/api/todo/"+todo.ID.Hex()+"/shares/"+carol.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.getFile("/api/files/"+todo.Image, carol.Token, nil)
+	expectStatus(t, resp, http.StatusForbidden)
+}
+
+func TestServeFileRangesAndCaching(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	todo := ta.createTodo(bob, "one", imageUpload("pic.png"))
+	url := todo.ImageURLs["original"]
+	content := ta.stored(todo.Image)
+
+	resp := ta.getFile(url, "", map[string]string{"Range": "bytes=0-9"})
+	expectStatus(t, resp, http.StatusPartialContent)
+	if cr := resp.Header.Get("Content-Range"); cr != "bytes 0-9/"+strconv.Itoa(len(content)) {
+		t.Fatalf("unexpected Content-Range %q", cr)
+	}
+	if got := readBody(t, resp); string(got) != string(content[:10]) {
+		t.Fatalf("expected the first 10 bytes, got %d bytes", len(got))
+	}
+
+	resp = ta.getFile(url, "", map[string]string{"Range": "bytes=-5"})
+	expectStatus(t, resp, http.StatusPartialContent)
+	if got := readBody(t, resp); string(got) != string(content[len(content)-5:]) {
+		t.Fatal("expected the last 5 bytes")
+	}
+
+	resp = ta.getFile(url, "", map[string]string{"Range": "bytes=99999-"})
+	expectStatus(t, resp, http.StatusRequestedRangeNotSatisfiable)
+	if cr := resp.Header.Get("Content-Range"); cr != "bytes */"+strconv.Itoa(len(content)) {
+		t.Fatalf("unexpected Content-Range %q", cr)
+	}
+
+	resp = ta.getFile(url, "", nil)
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+
+	resp = ta.getFile(url, "", map[string]string{"If-None-Match": etag})
+	expectStatus(t, resp, http.StatusNotModified)
+
+	resp = ta.getFile(url, "", map[string]string{"If-Modified-Since": lastModified})
+	expectStatus(t, resp, http.StatusNotModified)
+
+	resp = ta.getFile(url, "", map[string]string{"If-None-Match": `"stale"`})
+	expectStatus(t, resp, http.StatusOK)
+
+	// a stale If-Range turns the range request into a full response
+	resp = ta.getFile(url, "", map[string]string{"Range": "bytes=0-9", "If-Range": `"stale"`})
+	expectStatus(t, resp, http.StatusOK)
+	if got := readBody(t, resp); len(got) != len(content) {
+		t.Fatalf("expected the whole file, got %d bytes", len(got))
+	}
+
+	resp = ta.getFile(url, "", map[string]string{"Range": "bytes=0-9", "If-Range": etag})
+	expectStatus(t, resp, http.StatusPartialContent)
+}
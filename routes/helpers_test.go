@@ -0,0 +1,213 @@
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
+	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/clinton-mwachia/go-fiber-api-template/middlewares"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/routes"
+	"github.com/clinton-mwachia/go-fiber-api-template/storage"
+	"github.com/gofiber/fiber/v2"
+)
+
+const testPassword = "Password123!"
+
+// TestMain sets the configuration shared by every test
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	config.Cfg = &config.Config{
+		JWTSecret:       "test-secret",
+		JWTTTLMin:       15,
+		RefreshTTLHours: 24,
+		MaxUploadBytes:  64 << 10,
+	}
+
+	os.Exit(m.Run())
+}
+
+// testApp is a fiber app wired to in-memory repositories
+// and local storage in a temp dir
+type testApp struct {
+	t     *testing.T
+	app   *fiber.App
+	repos *repositories.Repositories
+	files storage.Storage
+}
+
+// testUser is a registered user together with its access token
+type testUser struct {
+	models.User
+	Token        string
+	RefreshToken string
+}
+
+func newTestApp(t *testing.T) *testApp {
+	t.Helper()
+	return newTestAppWith(t, repositories.NewMemoryRepositories())
+}
+
+// newTestAppWith wires the app to repos, to swap in failing repositories
+func newTestAppWith(t *testing.T, repos *repositories.Repositories) *testApp {
+	t.Helper()
+	app := fiber.New(fiber.Config{ErrorHandler: middlewares.ErrorHandler})
+	signer := storage.NewSigner("test-secret", "/api/files")
+	files, err := storage.NewLocal(t.TempDir(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes.SetUpRouter(app, repos, files, signer)
+	return &testApp{t: t, app: app, repos: repos, files: files}
+}
+
+// do sends the request through the app and returns the response
+func (ta *testApp) do(req *http.Request, token string) *http.Response {
+	ta.t.Helper()
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := ta.app.Test(req, -1)
+	if err != nil {
+		ta.t.Fatalf("%s %s: %v", req.Method, req.URL.Path, err)
+	}
+	return resp
+}
+
+// request sends body (if any) as JSON
+func (ta *testApp) request(method, path string, body any, token string) *http.Response {
+	ta.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			ta.t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return ta.do(req, token)
+}
+
+// upload describes the file part of a multipart request
+type upload struct {
+	Field    string
+	FileName string
+	Content  []byte
+}
+
+// imageUpload is a small valid PNG sent as the "image" field
+func imageUpload(fileName string) *upload {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	img.Set(1, 1, color.RGBA{G: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		panic(err)
+	}
+	return &upload{Field: "image", FileName: fileName, Content: buf.Bytes()}
+}
+
+// multipart sends fields and an optional file as multipart/form-data
+func (ta *testApp) multipart(method, path string, fields map[string]string, file *upload, token string) *http.Response {
+	ta.t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			ta.t.Fatal(err)
+		}
+	}
+	if file != nil {
+		fw, err := w.CreateFormFile(file.Field, file.FileName)
+		if err != nil {
+			ta.t.Fatal(err)
+		}
+		if _, err := fw.Write(file.Content); err != nil {
+			ta.t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		ta.t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return ta.do(req, token)
+}
+
+// register creates a user through the API and logs them in
+func (ta *testApp) register(username, email string) testUser {
+	ta.t.Helper()
+	resp := ta.request("POST", "/api/user/register", fiber.Map{
+		"username": username,
+		"email":    email,
+		"password": testPassword,
+	}, "")
+	expectStatus(ta.t, resp, http.StatusCreated)
+
+	user, err := ta.repos.Users.FindByEmail(context.Background(), email)
+	if err != nil {
+		ta.t.Fatalf("registered user %s not stored: %v", email, err)
+	}
+	return ta.login(user)
+}
+
+// registerAdmin registers a user and promotes them straight in the repository
+func (ta *testApp) registerAdmin(username, email string) testUser {
+	ta.t.Helper()
+	u := ta.register(username, email)
+	role := config.RoleAdmin
+	if _, err := ta.repos.Users.Update(context.Background(), u.ID, repositories.UserUpdate{Role: &role}); err != nil {
+		ta.t.Fatal(err)
+	}
+	u.Role = role
+	// login again so the token carries the new role
+	return ta.login(u.User)
+}
+
+// login obtains tokens for the user
+func (ta *testApp) login(user models.User) testUser {
+	ta.t.Helper()
+	resp := ta.request("POST", "/api/login", controllers.LoginInput{
+		Email:    user.Email,
+		Password: testPassword,
+	}, "")
+	expectStatus(ta.t, resp, http.StatusOK)
+
+	var res controllers.LoginResponse
+	decode(ta.t, resp, &res)
+	return testUser{User: user, Token: res.Token, RefreshToken: res.RefreshToken}
+}
+
+// expectStatus fails the test when the response status differs
+func expectStatus(t *testing.T, resp *http.Response, status int) {
+	t.Helper()
+	if resp.StatusCode != status {
+		body, _ := io.ReadAll(resp.Body)
+		t.Fatalf("expected status %d, got %d: %s", status, resp.StatusCode, body)
+	}
+}
+
+// decode reads the JSON response body into v
+func decode(t *testing.T, resp *http.Response, v any) {
+	t.Helper()
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+}
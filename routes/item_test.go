@@ -0,0 +1,157 @@
+package routes_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+func itemTitles(items []models.ChecklistItem) string {
+	titles := make([]string, len(items))
+	for i, item := range items {
+		titles[i] = item.Title
+	}
+	return strings.Join(titles, ",")
+}
+
+func TestChecklistItems(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	todo := ta.createTodo(bob, "move house", nil)
+	base := "/api/todo/" + todo.ID.Hex() + "/items"
+
+	send := func(method, path string, body any, status int) models.Todo {
+		t.Helper()
+		resp := ta.request(method, path, body, bob.Token)
+		expectStatus(t, resp, status)
+		var got models.Todo
+		if status < 300 {
+			decode(t, resp, &got)
+		}
+		return got
+	}
+
+	send("POST", base, fiber.Map{"title": "pack"}, http.StatusCreated)
+	send("POST", base, fiber.Map{"title": "clean"}, http.StatusCreated)
+	got := send("POST", base, fiber.Map{"title": "book van", "position": 0}, http.StatusCreated)
+	if itemTitles(got.Items) != "book van,pack,clean" || got.Progress != 0 {
+		t.Fatalf("unexpected checklist %+v", got.Items)
+	}
+	send("POST", base, fiber.Map{"title": ""}, http.StatusUnprocessableEntity)
+
+	van, pack, clean := got.Items[0], got.Items[1], got.Items[2]
+	got = send("PUT", base+"/"+van.ID.Hex(), fiber.Map{"completed": true}, http.StatusOK)
+	if got.Items[0].CompletedAt == nil || got.Progress != 33 || got.Completed {
+		t.Fatalf("expected one item done, got %+v (progress %d)", got.Items[0], got.Progress)
+	}
+	got = send("PUT", base+"/"+van.ID.Hex(), fiber.Map{"completed": false, "position": 5}, http.StatusOK)
+	if itemTitles(got.Items) != "pack,clean,book van" || got.Items[2].CompletedAt != nil || got.Progress != 0 {
+		t.Fatalf("expected the item reopened and moved last, got %+v", got.Items)
+	}
+	got = send("PUT", base+"/"+clean.ID.Hex(), fiber.Map{"title": "clean flat", "position": 0}, http.StatusOK)
+	if itemTitles(got.Items) != "clean flat,pack,book van" {
+		t.Fatalf("expected the item renamed and moved first, got %s", itemTitles(got.Items))
+	}
+	send("PUT", base+"/"+clean.ID.Hex(), fiber.Map{}, http.StatusBadRequest)
+
+	resp := ta.request("GET", base+"/"+pack.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var item models.ChecklistItem
+	decode(t, resp, &item)
+	if item.ID != pack.ID || item.Title != "pack" {
+		t.Fatalf("unexpected item %+v", item)
+	}
+
+	got = send("DELETE", base+"/"+pack.ID.Hex(), nil, http.StatusOK)
+	if itemTitles(got.Items) != "clean flat,book van" {
+		t.Fatalf("expected the item removed, got %s", itemTitles(got.Items))
+	}
+	send("DELETE", base+"/"+pack.ID.Hex(), nil, http.StatusNotFound)
+	send("PUT", base+"/"+pack.ID.Hex(), fiber.Map{"completed": true}, http.StatusNotFound)
+	send("GET", base+"/not-an-id", nil, http.StatusBadRequest)
+
+	resp = ta.request("GET", base, nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var checklist controllers.Checklist
+	decode(t, resp, &checklist)
+	if len(checklist.Items) != 2 || checklist.Progress != 0 {
+		t.Fatalf("unexpected checklist %+v", checklist)
+	}
+
+	for _, resp := range []*http.Response{
+		ta.request("GET", base, nil, carol.Token),
+		ta.request("POST", base, fiber.Map{"title": "x"}, carol.Token),
+		ta.request("DELETE", base+"/"+van.ID.Hex(), nil, carol.Token),
+	} {
+		expectStatus(t, resp, http.StatusForbidden)
+	}
+}
+
+func TestChecklistAutoComplete(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	todo := ta.createTodo(bob, "trip", nil)
+	base := "/api/todo/" + todo.ID.Hex() + "/items"
+
+	send := func(method, path string, body any, status int) models.Todo {
+		t.Helper()
+		resp := ta.request(method, path, body, bob.Token)
+		expectStatus(t, resp, status)
+		var got models.Todo
+		decode(t, resp, &got)
+		return got
+	}
+
+	send("POST", base, fiber.Map{"title": "tickets"}, http.StatusCreated)
+	got := send("POST", base, fiber.Map{"title": "passport"}, http.StatusCreated)
+	tickets, passport := got.Items[0], got.Items[1]
+
+	// without autoComplete the todo stays open
+	send("PUT", base+"/"+tickets.ID.Hex(), fiber.Map{"completed": true}, http.StatusOK)
+	got = send("PUT", base+"/"+passport.ID.Hex(), fiber.Map{"completed": true}, http.StatusOK)
+	if got.Completed || got.Progress != 100 {
+		t.Fatalf("expected an open todo at 100%%, got completed=%v progress=%d", got.Completed, got.Progress)
+	}
+
+	// turning it on completes the todo straight away
+	got = send("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"autoComplete": true}, http.StatusOK)
+	if !got.AutoComplete || !got.Completed || got.CompletedAt == nil {
+		t.Fatalf("expected the todo completed, got %+v", got)
+	}
+
+	got = send("PUT", base+"/"+passport.ID.Hex(), fiber.Map{"completed": false}, http.StatusOK)
+	if got.Completed || got.CompletedAt != nil || got.Progress != 50 {
+		t.Fatalf("expected the todo reopened, got %+v", got)
+	}
+	got = send("PUT", base+"/"+passport.ID.Hex(), fiber.Map{"completed": true}, http.StatusOK)
+	if !got.Completed {
+		t.Fatal("expected the todo completed again")
+	}
+	got = send("POST", base, fiber.Map{"title": "insurance"}, http.StatusCreated)
+	if got.Completed || got.Progress != 66 {
+		t.Fatalf("expected a new item to reopen the todo, got completed=%v progress=%d", got.Completed, got.Progress)
+	}
+	got = send("DELETE", base+"/"+got.Items[2].ID.Hex(), nil, http.StatusOK)
+	if !got.Completed {
+		t.Fatal("expected removing the open item to complete the todo")
+	}
+}
+
+func TestChecklistLimit(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	todo := ta.createTodo(bob, "many", nil)
+	base := "/api/todo/" + todo.ID.Hex() + "/items"
+
+	for i := 0; i < 100; i++ {
+		resp := ta.request("POST", base, fiber.Map{"title": "item"}, bob.Token)
+		expectStatus(t, resp, http.StatusCreated)
+	}
+	resp := ta.request("POST", base, fiber.Map{"title": "one too many"}, bob.Token)
+	expectStatus(t, resp, http.StatusConflict)
+}
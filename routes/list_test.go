@@ -0,0 +1,214 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// createList creates a list for the user through the API
+func (ta *testApp) createList(u testUser, body fiber.Map) models.List {
+	ta.t.Helper()
+	resp := ta.request("POST", "/api/list/register", body, u.Token)
+	expectStatus(ta.t, resp, http.StatusCreated)
+
+	var list models.List
+	decode(ta.t, resp, &list)
+	return list
+}
+
+// fileTodo creates a todo in the list, a zero list files it in the inbox
+func (ta *testApp) fileTodo(u testUser, title string, listID primitive.ObjectID) models.Todo {
+	ta.t.Helper()
+	body := fiber.Map{"title": title, "userId": u.ID.Hex()}
+	if !listID.IsZero() {
+		body["listId"] = listID.Hex()
+	}
+	resp := ta.request("POST", "/api/todo/register", body, u.Token)
+	expectStatus(ta.t, resp, http.StatusCreated)
+
+	var todo models.Todo
+	decode(ta.t, resp, &todo)
+	return todo
+}
+
+func (ta *testApp) listNames(u testUser, path string) []string {
+	ta.t.Helper()
+	resp := ta.request("GET", path, nil, u.Token)
+	expectStatus(ta.t, resp, http.StatusOK)
+	var page controllers.ListResponse[models.List]
+	decode(ta.t, resp, &page)
+	names := []string{}
+	for _, list := range page.Data {
+		names = append(names, list.Name)
+	}
+	return names
+}
+
+func TestLists(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+
+	work := ta.createList(bob, fiber.Map{"name": " Work ", "color": "#3B82F6"})
+	home := ta.createList(bob, fiber.Map{"name": "Home"})
+	errands := ta.createList(bob, fiber.Map{"name": "Errands", "position": 0})
+	ta.createList(carol, fiber.Map{"name": "Carol's"})
+	if work.Name != "Work" || work.Color != "#3b82f6" || work.UserID != bob.ID || work.Position != 0 || home.Position != 1 {
+		t.Fatalf("unexpected lists %+v %+v", work, home)
+	}
+	if got := ta.listNames(bob, "/api/lists"); len(got) != 3 || got[0] != "Work" || got[1] != "Errands" || got[2] != "Home" {
+		t.Fatalf("expected lists by position then creation, got %v", got)
+	}
+
+	resp := ta.request("POST", "/api/list/register", fiber.Map{"name": "x", "color": "blue"}, bob.Token)
+	expectStatus(t, resp, http.StatusUnprocessableEntity)
+
+	// reorder and archive
+	resp = ta.request("PUT", "/api/list/"+home.ID.Hex(), fiber.Map{"position": 0, "name": "House", "color": ""}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("PUT", "/api/list/"+errands.ID.Hex(), fiber.Map{"archived": true}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	if got := ta.listNames(bob, "/api/lists"); len(got) != 2 || got[0] != "Work" || got[1] != "House" {
+		t.Fatalf("expected the active lists, got %v", got)
+	}
+	if got := ta.listNames(bob, "/api/lists?archived=true"); len(got) != 1 || got[0] != "Errands" {
+		t.Fatalf("expected the archived list, got %v", got)
+	}
+	resp = ta.request("PUT", "/api/list/"+home.ID.Hex(), fiber.Map{}, bob.Token)
+	expectStatus(t, resp, http.StatusBadRequest)
+
+	// only the owner may use a list
+	for _, resp := range []*http.Response{
+		ta.request("GET", "/api/list/"+work.ID.Hex(), nil, carol.Token),
+		ta.request("PUT", "/api/list/"+work.ID.Hex(), fiber.Map{"name": "mine"}, carol.Token),
+		ta.request("DELETE", "/api/list/"+work.ID.Hex(), nil, carol.Token),
+		ta.request("GET", "/api/list/"+work.ID.Hex()+"/todos", nil, carol.Token),
+	} {
+		expectStatus(t, resp, http.StatusForbidden)
+	}
+	resp = ta.request("GET", "/api/list/"+primitive.NewObjectID().Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+}
+
+func TestListTodos(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	work := ta.createList(bob, fiber.Map{"name": "Work"})
+	home := ta.createList(bob, fiber.Map{"name": "Home"})
+	carols := ta.createList(carol, fiber.Map{"name": "Carol's"})
+
+	report := ta.fileTodo(bob, "report", work.ID)
+	ta.fileTodo(bob, "slides", work.ID)
+	ta.fileTodo(bob, "dishes", home.ID)
+	ta.fileTodo(bob, "call mum", primitive.NilObjectID)
+	if report.ListID == nil || *report.ListID != work.ID {
+		t.Fatalf("expected the todo in the list, got %+v", report.ListID)
+	}
+
+	// a todo can't be filed in someone else's list
+	resp := ta.request("POST", "/api/todo/register", fiber.Map{"title": "x", "userId": bob.ID.Hex(), "listId": carols.ID.Hex()}, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+	resp = ta.request("PUT", "/api/todo/"+report.ID.Hex(), fiber.Map{"listId": carols.ID.Hex()}, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+
+	get := func(path string) string {
+		t.Helper()
+		resp := ta.request("GET", path, nil, bob.Token)
+		expectStatus(t, resp, http.StatusOK)
+		var page controllers.ListResponse[models.Todo]
+		decode(t, resp, &page)
+		return todoTitles(page.Data)
+	}
+	if got := get("/api/list/" + work.ID.Hex() + "/todos"); got != "report,slides" {
+		t.Fatalf("expected the list's todos, got %s", got)
+	}
+	if got := get("/api/list/" + work.ID.Hex() + "/todos?q=report"); got != "report" {
+		t.Fatalf("expected the filters to apply, got %s", got)
+	}
+	if got := get("/api/todos/" + bob.ID.Hex() + "?list=inbox"); got != "call mum" {
+		t.Fatalf("expected the inbox, got %s", got)
+	}
+
+	// move between lists, to the end of the new one, and to the inbox
+	resp = ta.request("PUT", "/api/todo/"+report.ID.Hex(), fiber.Map{"listId": home.ID.Hex()}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	if got := get("/api/todos/" + bob.ID.Hex() + "?list=" + home.ID.Hex()); got != "dishes,report" {
+		t.Fatalf("expected the todo moved, got %s", got)
+	}
+	resp = ta.request("PUT", "/api/todo/"+report.ID.Hex(), fiber.Map{"listId": ""}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var moved models.Todo
+	decode(t, resp, &moved)
+	if moved.ListID != nil {
+		t.Fatalf("expected the todo in the inbox, got %v", moved.ListID)
+	}
+
+	// deleting a list moves its todos to the inbox by default
+	resp = ta.request("DELETE", "/api/list/"+work.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var deleted struct {
+		MovedTodos int64 `json:"movedTodos"`
+	}
+	decode(t, resp, &deleted)
+	if deleted.MovedTodos != 1 {
+		t.Fatalf("expected one todo moved, got %d", deleted.MovedTodos)
+	}
+	// they keep their ranks, so they mix in with the inbox's todos
+	if got := get("/api/todos/" + bob.ID.Hex() + "?list=inbox"); got != "call mum,report,slides" {
+		t.Fatalf("expected the list's todos in the inbox, got %s", got)
+	}
+	resp = ta.request("GET", "/api/list/"+work.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+
+	// or deletes them with it
+	resp = ta.request("DELETE", "/api/list/"+home.ID.Hex()+"?todos=archive", nil, bob.Token)
+	expectStatus(t, resp, http.StatusBadRequest)
+	resp = ta.request("DELETE", "/api/list/"+home.ID.Hex()+"?todos=delete", nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	if got := get("/api/todos/" + bob.ID.Hex()); got != "call mum,report,slides" {
+		t.Fatalf("expected the list's todos deleted, got %s", got)
+	}
+}
+
+func TestFileTodoNeedsListAccess(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	dave := ta.register("dave", "dave@example.com")
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	carols := ta.createList(carol, fiber.Map{"name": "Carol's"})
+	bobs := ta.createList(bob, fiber.Map{"name": "Bob's"})
+
+	// nobody files a todo in a private list of someone else
+	resp := ta.request("POST", "/api/todo/register", fiber.Map{"title": "spam", "userId": carol.ID.Hex(), "listId": carols.ID.Hex()}, bob.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+	resp = ta.request("POST", "/api/todo/register", fiber.Map{"title": "spam", "userId": bob.ID.Hex(), "listId": carols.ID.Hex()}, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+	resp = ta.request("POST", "/api/todo/register", fiber.Map{"title": "welcome", "userId": carol.ID.Hex(), "listId": carols.ID.Hex()}, admin.Token)
+	expectStatus(t, resp, http.StatusCreated)
+
+	// an editor of a todo can't move it into a list of its owner they can't see
+	todo := ta.fileTodo(bob, "plan trip", primitive.NilObjectID)
+	ta.share(bob, dave, "/api/todo/"+todo.ID.Hex(), "editor")
+	resp = ta.request("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"listId": bobs.ID.Hex()}, dave.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+	resp = ta.request("POST", "/api/todo/"+todo.ID.Hex()+"/move", fiber.Map{"listId": bobs.ID.Hex()}, dave.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+
+	// nor into one they only view
+	ta.share(bob, dave, "/api/list/"+bobs.ID.Hex(), "viewer")
+	resp = ta.request("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"listId": bobs.ID.Hex()}, dave.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+	resp = ta.request("POST", "/api/todo/"+todo.ID.Hex()+"/move", fiber.Map{"listId": bobs.ID.Hex()}, dave.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	// the owner can
+	resp = ta.request("POST", "/api/todo/"+todo.ID.Hex()+"/move", fiber.Map{"listId": bobs.ID.Hex()}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+}
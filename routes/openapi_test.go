@@ -0,0 +1,77 @@
+package routes_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/docs"
+)
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	ta := newTestApp(t)
+	for _, r := range docs.Routes(ta.app) {
+		if _, ok := docs.Operations[docs.Key(r.Method, r.Path)]; !ok {
+			t.Errorf("route %s %s has no entry in docs.Operations", r.Method, r.Path)
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	ta := newTestApp(t)
+
+	resp := ta.request("GET", "/api/openapi.json", nil, "")
+	expectStatus(t, resp, http.StatusOK)
+	var spec struct {
+		OpenAPI    string                    `json:"openapi"`
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	decode(t, resp, &spec)
+
+	if !strings.HasPrefix(spec.OpenAPI, "3.1") {
+		t.Fatalf("expected openapi 3.1, got %s", spec.OpenAPI)
+	}
+	if _, ok := spec.Paths["/api/todo/{id}"]["delete"]; !ok {
+		t.Fatal("expected DELETE /api/todo/{id} in the document")
+	}
+	for _, name := range []string{"User", "Todo", "LoginInput", "LoginResponse"} {
+		if _, ok := spec.Components.Schemas[name]; !ok {
+			t.Errorf("expected schema %s in components", name)
+		}
+	}
+
+	// validate rules become the constraints of the property's type
+	type property struct {
+		MaxLength *int           `json:"maxLength"`
+		Minimum   *int           `json:"minimum"`
+		Maximum   *int           `json:"maximum"`
+		MaxItems  *int           `json:"maxItems"`
+		Items     map[string]any `json:"items"`
+	}
+	var input struct {
+		Properties map[string]property `json:"properties"`
+	}
+	if err := json.Unmarshal(spec.Components.Schemas["CreateTodoInput"], &input); err != nil {
+		t.Fatal(err)
+	}
+	priority, title, tags := input.Properties["priority"], input.Properties["title"], input.Properties["tags"]
+	if priority.Minimum == nil || *priority.Minimum != 0 || priority.Maximum == nil || *priority.Maximum != 3 || priority.MaxLength != nil {
+		t.Errorf("expected priority between 0 and 3, got %+v", priority)
+	}
+	if title.MaxLength == nil || *title.MaxLength != 200 || title.Maximum != nil {
+		t.Errorf("expected title up to 200 characters, got %+v", title)
+	}
+	if tags.MaxItems == nil || *tags.MaxItems != 20 || tags.MaxLength != nil || tags.Items["maxLength"] != float64(50) {
+		t.Errorf("expected up to 20 tags of up to 50 characters, got %+v", tags)
+	}
+
+	resp = ta.request("GET", "/api/docs", nil, "")
+	expectStatus(t, resp, http.StatusOK)
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("expected html, got %s", ct)
+	}
+}
@@ -0,0 +1,148 @@
+package routes_test
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+var linkPattern = regexp.MustCompile(`<([^>]+)>; rel="(\w+)"`)
+
+// links returns the request URIs of the Link header by rel
+func links(t *testing.T, resp *http.Response) map[string]string {
+	t.Helper()
+	out := map[string]string{}
+	for _, m := range linkPattern.FindAllStringSubmatch(resp.Header.Get("Link"), -1) {
+		u, err := url.Parse(m[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[m[2]] = u.RequestURI()
+	}
+	return out
+}
+
+func todoTitles(todos []models.Todo) string {
+	titles := make([]string, len(todos))
+	for i, todo := range todos {
+		titles[i] = todo.Title
+	}
+	return strings.Join(titles, ",")
+}
+
+func TestTodoPagination(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	for _, todo := range []fiber.Map{
+		{"title": "a", "priority": 3},
+		{"title": "b", "priority": 1, "dueAt": "2025-02-01T00:00:00Z"},
+		{"title": "c", "priority": 3, "dueAt": "2025-01-01T00:00:00Z"},
+		{"title": "d", "priority": 1},
+		{"title": "e", "priority": 3, "dueAt": "2025-03-01T00:00:00Z"},
+		{"title": "f", "priority": 0, "dueAt": "2025-01-15T00:00:00Z"},
+		{"title": "g", "priority": 1, "dueAt": "2025-02-01T00:00:00Z"},
+	} {
+		todo["userId"] = bob.ID.Hex()
+		resp := ta.request("POST", "/api/todo/register", todo, bob.Token)
+		expectStatus(t, resp, http.StatusCreated)
+	}
+
+	get := func(path string) (controllers.ListResponse[models.Todo], map[string]string) {
+		t.Helper()
+		resp := ta.request("GET", path, nil, bob.Token)
+		expectStatus(t, resp, http.StatusOK)
+		var page controllers.ListResponse[models.Todo]
+		decode(t, resp, &page)
+		return page, links(t, resp)
+	}
+
+	base := "/api/todos/" + bob.ID.Hex() + "?sort=-priority,dueAt&completed=false"
+	all, _ := get(base + "&limit=100")
+	if want := "a,c,e,d,b,g,f"; todoTitles(all.Data) != want {
+		t.Fatalf("expected %s, got %s", want, todoTitles(all.Data))
+	}
+
+	// forward through the Link headers, the filters are kept
+	page, link := get(base + "&limit=3&total=true")
+	if page.Total == nil || *page.Total != 7 || page.Page != 1 || page.Limit != 3 || page.PrevCursor != "" || link["prev"] != "" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	var forward []models.Todo
+	var pages int
+	for {
+		forward = append(forward, page.Data...)
+		pages++
+		if link["next"] == "" {
+			break
+		}
+		if page.NextCursor == "" || !strings.Contains(link["next"], "completed=false") {
+			t.Fatalf("expected a next link keeping the filters, got %q", link["next"])
+		}
+		page, link = get(link["next"])
+		if page.Page != 0 || page.Total == nil || *page.Total != 7 {
+			t.Fatalf("expected a cursor page still counting every match, got %+v", page)
+		}
+	}
+	if pages != 3 || todoTitles(forward) != todoTitles(all.Data) {
+		t.Fatalf("expected every todo once over 3 pages, got %s over %d", todoTitles(forward), pages)
+	}
+
+	// and back again from the last page
+	var backward []models.Todo
+	for link["prev"] != "" {
+		page, link = get(link["prev"])
+		backward = append(page.Data, backward...)
+	}
+	if todoTitles(backward) != "a,c,e,d,b,g" {
+		t.Fatalf("expected the earlier pages going back, got %s", todoTitles(backward))
+	}
+
+	page, _ = get(base + "&limit=2&page=2")
+	if page.Page != 2 || todoTitles(page.Data) != "e,d" || page.PrevCursor == "" || page.NextCursor == "" {
+		t.Fatalf("unexpected numbered page %+v", page)
+	}
+
+	page, _ = get(base + "&limit=1000")
+	if page.Limit != 100 {
+		t.Fatalf("expected the limit to be capped, got %d", page.Limit)
+	}
+
+	page, _ = get(base + "&limit=2")
+	for _, query := range []string{
+		"?sort=title&cursor=" + page.NextCursor, // made for another order
+		"?cursor=not-a-cursor",
+		"?page=2&cursor=" + page.NextCursor,
+	} {
+		resp := ta.request("GET", "/api/todos/"+bob.ID.Hex()+query, nil, bob.Token)
+		expectStatus(t, resp, http.StatusBadRequest)
+	}
+}
+
+func TestUserPagination(t *testing.T) {
+	ta := newTestApp(t)
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	ta.register("bob", "bob@example.com")
+	ta.register("carol", "carol@example.com")
+
+	var usernames []string
+	path := "/api/users?limit=1&sort=-username"
+	for path != "" {
+		resp := ta.request("GET", path, nil, admin.Token)
+		expectStatus(t, resp, http.StatusOK)
+		var page controllers.ListResponse[models.User]
+		decode(t, resp, &page)
+		for _, u := range page.Data {
+			usernames = append(usernames, u.Username)
+		}
+		path = links(t, resp)["next"]
+	}
+	if strings.Join(usernames, ",") != "carol,bob,admin" {
+		t.Fatalf("expected users by username descending, got %v", usernames)
+	}
+}
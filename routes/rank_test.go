@@ -0,0 +1,153 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMoveTodo(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	dave := ta.register("dave", "dave@example.com")
+	work := ta.createList(bob, fiber.Map{"name": "Work"})
+	home := ta.createList(bob, fiber.Map{"name": "Home"})
+
+	// new todos go to the end of their list
+	a := ta.fileTodo(bob, "a", work.ID)
+	b := ta.fileTodo(bob, "b", work.ID)
+	c := ta.fileTodo(bob, "c", work.ID)
+	dishes := ta.fileTodo(bob, "dishes", home.ID)
+	inbox := ta.fileTodo(bob, "inbox", primitive.NilObjectID)
+	if a.Rank == "" || a.Rank >= b.Rank || b.Rank >= c.Rank {
+		t.Fatalf("expected increasing ranks, got %q %q %q", a.Rank, b.Rank, c.Rank)
+	}
+
+	listPath := "/api/list/" + work.ID.Hex() + "/todos"
+	get := func(u testUser, path string) string {
+		t.Helper()
+		resp := ta.request("GET", path, nil, u.Token)
+		expectStatus(t, resp, http.StatusOK)
+		var page controllers.ListResponse[models.Todo]
+		decode(t, resp, &page)
+		return todoTitles(page.Data)
+	}
+	move := func(u testUser, todo models.Todo, body fiber.Map, status int) models.Todo {
+		t.Helper()
+		resp := ta.request("POST", "/api/todo/"+todo.ID.Hex()+"/move", body, u.Token)
+		expectStatus(t, resp, status)
+		var moved models.Todo
+		if status < 300 {
+			decode(t, resp, &moved)
+		}
+		return moved
+	}
+
+	// before or after another todo of the list
+	move(bob, c, fiber.Map{"before": a.ID.Hex()}, http.StatusOK)
+	if got := get(bob, listPath); got != "c,a,b" {
+		t.Fatalf("expected c moved first, got %s", got)
+	}
+	move(bob, a, fiber.Map{"after": b.ID.Hex()}, http.StatusOK)
+	if got := get(bob, listPath); got != "c,b,a" {
+		t.Fatalf("expected a moved last, got %s", got)
+	}
+	if got := get(bob, listPath+"?sort=-rank"); got != "a,b,c" {
+		t.Fatalf("expected the reverse order, got %s", got)
+	}
+
+	// to another list, at its end or next to one of its todos
+	moved := move(bob, inbox, fiber.Map{"listId": work.ID.Hex(), "after": c.ID.Hex()}, http.StatusOK)
+	if moved.ListID == nil || *moved.ListID != work.ID {
+		t.Fatalf("expected the todo in the list, got %v", moved.ListID)
+	}
+	if got := get(bob, listPath); got != "c,inbox,b,a" {
+		t.Fatalf("expected the todo after c, got %s", got)
+	}
+	move(bob, dishes, fiber.Map{"listId": work.ID.Hex()}, http.StatusOK)
+	move(bob, inbox, fiber.Map{"listId": ""}, http.StatusOK)
+	if got := get(bob, listPath); got != "c,b,a,dishes" {
+		t.Fatalf("expected dishes at the end, got %s", got)
+	}
+	if got := get(bob, "/api/todos/"+bob.ID.Hex()+"?list=inbox"); got != "inbox" {
+		t.Fatalf("expected the todo back in the inbox, got %s", got)
+	}
+
+	// the order holds across pages
+	resp := ta.request("GET", listPath+"?limit=2", nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var page controllers.ListResponse[models.Todo]
+	decode(t, resp, &page)
+	if got := todoTitles(page.Data); got != "c,b" || page.NextCursor == "" {
+		t.Fatalf("expected the first page, got %s", got)
+	}
+	if got := get(bob, listPath+"?limit=2&cursor="+page.NextCursor); got != "a,dishes" {
+		t.Fatalf("expected the second page, got %s", got)
+	}
+
+	// the other todo has to be in the same list, and only one of before and after
+	for _, body := range []fiber.Map{
+		{"before": a.ID.Hex()},
+		{"before": inbox.ID.Hex()},
+		{"after": primitive.NewObjectID().Hex()},
+		{"before": "nope"},
+		{"before": b.ID.Hex(), "after": c.ID.Hex()},
+		{"listId": home.ID.Hex(), "before": b.ID.Hex()},
+	} {
+		move(bob, a, body, http.StatusUnprocessableEntity)
+	}
+	move(bob, a, fiber.Map{}, http.StatusBadRequest)
+	move(bob, a, fiber.Map{"listId": primitive.NewObjectID().Hex()}, http.StatusNotFound)
+
+	// viewers can't reorder, editors can
+	ta.share(bob, carol, "/api/list/"+work.ID.Hex(), "viewer")
+	ta.share(bob, dave, "/api/list/"+work.ID.Hex(), "editor")
+	move(carol, a, fiber.Map{"before": c.ID.Hex()}, http.StatusForbidden)
+	move(dave, a, fiber.Map{"before": c.ID.Hex()}, http.StatusOK)
+	if got := get(carol, listPath); got != "a,c,b,dishes" {
+		t.Fatalf("expected the editor's move, got %s", got)
+	}
+}
+
+func TestMoveTodoRebalances(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	list := ta.createList(bob, fiber.Map{"name": "Work"})
+	var todos []models.Todo
+	for _, title := range []string{"a", "b", "c"} {
+		todos = append(todos, ta.fileTodo(bob, title, list.ID))
+	}
+
+	// todos sharing a rank leave no room between them, the list is spread out first
+	ctx := context.Background()
+	tie := "V"
+	for _, todo := range todos[:2] {
+		if _, err := ta.repos.Todos.Update(ctx, todo.ID, repositories.TodoUpdate{Rank: &tie}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp := ta.request("POST", "/api/todo/"+todos[2].ID.Hex()+"/move", fiber.Map{"after": todos[0].ID.Hex()}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	resp = ta.request("GET", "/api/list/"+list.ID.Hex()+"/todos", nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var page controllers.ListResponse[models.Todo]
+	decode(t, resp, &page)
+	if got := todoTitles(page.Data); got != "a,c,b" {
+		t.Fatalf("expected c between a and b, got %s", got)
+	}
+	seen := map[string]bool{}
+	for _, todo := range page.Data {
+		if seen[todo.Rank] {
+			t.Fatalf("expected distinct ranks, got %q twice", todo.Rank)
+		}
+		seen[todo.Rank] = true
+	}
+}
@@ -0,0 +1,171 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRecurringTodo(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+
+	send := func(method, path string, body any, status int) models.Todo {
+		t.Helper()
+		resp := ta.request(method, path, body, bob.Token)
+		expectStatus(t, resp, status)
+		var todo models.Todo
+		if status < 300 {
+			decode(t, resp, &todo)
+		}
+		return todo
+	}
+
+	// a rule needs to be valid and the todo due
+	create := fiber.Map{"title": "water plants", "userId": bob.ID.Hex(), "recurrence": "FREQ=DAILY;COUNT=3"}
+	send("POST", "/api/todo/register", create, http.StatusUnprocessableEntity)
+	create["dueAt"], create["dueTimezone"], create["recurrence"] = "2026-03-28T09:00", "Europe/Berlin", "FREQ=FORTNIGHTLY"
+	send("POST", "/api/todo/register", create, http.StatusUnprocessableEntity)
+	create["recurrence"] = "rrule:freq=daily;count=3"
+	todo := send("POST", "/api/todo/register", create, http.StatusCreated)
+	if todo.Recurrence == nil || todo.Recurrence.Rule != "FREQ=DAILY;COUNT=3" || todo.Recurrence.Start.Format(time.RFC3339) != "2026-03-28T09:00:00+01:00" {
+		t.Fatalf("unexpected recurrence %+v", todo.Recurrence)
+	}
+	send("POST", "/api/todo/"+todo.ID.Hex()+"/items", fiber.Map{"title": "balcony"}, http.StatusCreated)
+	send("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"dueAt": ""}, http.StatusUnprocessableEntity)
+
+	// completing an occurrence creates the next one, once, at the same wall clock time after the DST change
+	done := send("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"completed": true}, http.StatusOK)
+	if done.Recurrence.NextID == nil {
+		t.Fatal("expected the next occurrence to be linked")
+	}
+	next := send("GET", "/api/todo/"+done.Recurrence.NextID.Hex(), nil, http.StatusOK)
+	if next.Completed || next.DueAt.Format(time.RFC3339) != "2026-03-29T09:00:00+02:00" || next.Title != "water plants" ||
+		len(next.Items) != 1 || next.Items[0].Completed || next.Items[0].ID == done.Items[0].ID || next.Recurrence.NextID != nil {
+		t.Fatalf("unexpected next occurrence %+v", next)
+	}
+	send("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"completed": false}, http.StatusOK)
+	again := send("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"completed": true}, http.StatusOK)
+	if *again.Recurrence.NextID != *done.Recurrence.NextID {
+		t.Fatal("expected no second next occurrence")
+	}
+	resp := ta.request("GET", "/api/todos/"+bob.ID.Hex(), nil, bob.Token)
+	var page controllers.ListResponse[models.Todo]
+	decode(t, resp, &page)
+	if len(page.Data) != 2 {
+		t.Fatalf("expected 2 todos, got %s", todoTitles(page.Data))
+	}
+
+	// skipping moves the todo to the next occurrence
+	skipped := send("POST", "/api/todo/"+next.ID.Hex()+"/skip", nil, http.StatusOK)
+	if skipped.DueAt.Format(time.RFC3339) != "2026-03-30T09:00:00+02:00" || len(skipped.Recurrence.Exceptions) != 1 {
+		t.Fatalf("unexpected skipped todo %+v %+v", skipped.DueAt, skipped.Recurrence)
+	}
+	send("POST", "/api/todo/"+next.ID.Hex()+"/skip", nil, http.StatusConflict)
+
+	// completing the last occurrence (through its checklist) ends the series
+	send("PUT", "/api/todo/"+next.ID.Hex(), fiber.Map{"autoComplete": true}, http.StatusOK)
+	last := send("PUT", "/api/todo/"+next.ID.Hex()+"/items/"+next.Items[0].ID.Hex(), fiber.Map{"completed": true}, http.StatusOK)
+	if !last.Completed || last.Recurrence.NextID != nil {
+		t.Fatalf("expected the series to end, got %+v", last.Recurrence)
+	}
+	send("POST", "/api/todo/"+next.ID.Hex()+"/skip", nil, http.StatusConflict)
+
+	// an empty recurrence stops it
+	stopped := send("PUT", "/api/todo/"+next.ID.Hex(), fiber.Map{"recurrence": ""}, http.StatusOK)
+	if stopped.Recurrence != nil {
+		t.Fatalf("expected the recurrence cleared, got %+v", stopped.Recurrence)
+	}
+	send("POST", "/api/todo/"+next.ID.Hex()+"/skip", nil, http.StatusBadRequest)
+	send("PUT", "/api/todo/"+next.ID.Hex(), fiber.Map{"recurrenceExceptions": []string{"2026-03-30"}}, http.StatusUnprocessableEntity)
+}
+
+func TestTodoOccurrences(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+
+	resp := ta.request("POST", "/api/todo/register", fiber.Map{
+		"title": "standup", "userId": bob.ID.Hex(), "dueAt": "2026-10-19T09:30", "dueTimezone": "America/New_York",
+		"recurrence": "FREQ=WEEKLY;BYDAY=MO,WE", "recurrenceExceptions": []string{"2026-10-28", "2026-11-02T14:30:00Z"},
+	}, bob.Token)
+	expectStatus(t, resp, http.StatusCreated)
+	var todo models.Todo
+	decode(t, resp, &todo)
+	if len(todo.Recurrence.Exceptions) != 2 || todo.Recurrence.Exceptions[0].Format(time.RFC3339) != "2026-10-28T09:30:00-04:00" {
+		t.Fatalf("unexpected exceptions %v", todo.Recurrence.Exceptions)
+	}
+	resp = ta.request("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"recurrenceExceptions": []string{"2026-10-20"}}, bob.Token)
+	expectStatus(t, resp, http.StatusUnprocessableEntity)
+
+	path := "/api/todo/" + todo.ID.Hex() + "/occurrences"
+	get := func(query string) []string {
+		t.Helper()
+		resp := ta.request("GET", path+query, nil, bob.Token)
+		expectStatus(t, resp, http.StatusOK)
+		var occurrences controllers.Occurrences
+		decode(t, resp, &occurrences)
+		got := make([]string, len(occurrences.Occurrences))
+		for i, o := range occurrences.Occurrences {
+			got[i] = o.Format(time.RFC3339)
+		}
+		return got
+	}
+
+	// skipped ones are left out and the time holds after the clocks go back
+	got := get("?to=2026-11-06")
+	want := []string{"2026-10-19T09:30:00-04:00", "2026-10-21T09:30:00-04:00", "2026-10-26T09:30:00-04:00", "2026-11-04T09:30:00-05:00"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+	if got := get("?from=2026-11-01&to=2026-11-10"); len(got) != 2 || got[1] != "2026-11-09T09:30:00-05:00" {
+		t.Fatalf("unexpected occurrences %v", got)
+	}
+	if got := get(""); len(got) != 8 {
+		t.Fatalf("expected a month of occurrences, got %v", got)
+	}
+
+	for _, query := range []string{"?from=soon", "?from=2026-11-01&to=2026-10-01", "?from=2026-01-01&to=2027-06-01"} {
+		resp := ta.request("GET", path+query, nil, bob.Token)
+		expectStatus(t, resp, http.StatusBadRequest)
+	}
+	resp = ta.request("GET", path, nil, carol.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+	resp = ta.request("POST", "/api/todo/"+todo.ID.Hex()+"/skip", nil, carol.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	plain := ta.createTodo(bob, "once", nil)
+	resp = ta.request("GET", "/api/todo/"+plain.ID.Hex()+"/occurrences", nil, bob.Token)
+	expectStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestSkipKeepsExceptionsCapped(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	todo := ta.createTodo(bob, "stretch", nil)
+	resp := ta.request("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"dueAt": "2026-01-01T08:00:00Z", "recurrence": "FREQ=DAILY"}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	// skipping every day for months keeps the most recent skips
+	for range 110 {
+		resp = ta.request("POST", "/api/todo/"+todo.ID.Hex()+"/skip", nil, bob.Token)
+		expectStatus(t, resp, http.StatusOK)
+		decode(t, resp, &todo)
+	}
+	exceptions := todo.Recurrence.Exceptions
+	if len(exceptions) != 100 || !exceptions[0].Equal(time.Date(2026, 1, 11, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the last 100 skips, got %d from %v", len(exceptions), exceptions[0])
+	}
+	if !todo.DueAt.Equal(time.Date(2026, 4, 21, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the todo due after the skips, got %v", todo.DueAt)
+	}
+}
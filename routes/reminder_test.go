@@ -0,0 +1,160 @@
+package routes_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// enableEmailReminders turns on the email channel for the test
+func enableEmailReminders(t *testing.T) {
+	host := config.Cfg.SMTPHost
+	config.Cfg.SMTPHost = "localhost"
+	t.Cleanup(func() { config.Cfg.SMTPHost = host })
+}
+
+func TestReminders(t *testing.T) {
+	enableEmailReminders(t)
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	dave := ta.register("dave", "dave@example.com")
+
+	resp := ta.request("POST", "/api/todo/register", fiber.Map{
+		"title": "pay rent", "userId": bob.ID.Hex(), "dueAt": "2030-01-02T10:00", "dueTimezone": "Europe/Berlin",
+	}, bob.Token)
+	expectStatus(t, resp, http.StatusCreated)
+	var todo models.Todo
+	decode(t, resp, &todo)
+	path := "/api/todo/" + todo.ID.Hex() + "/reminders"
+
+	add := func(u testUser, path string, body fiber.Map, status int) models.Reminder {
+		t.Helper()
+		resp := ta.request("POST", path, body, u.Token)
+		expectStatus(t, resp, status)
+		var reminder models.Reminder
+		if status < 300 {
+			decode(t, resp, &reminder)
+		}
+		return reminder
+	}
+	list := func(u testUser) []models.Reminder {
+		t.Helper()
+		resp := ta.request("GET", path, nil, u.Token)
+		expectStatus(t, resp, http.StatusOK)
+		var reminders []models.Reminder
+		decode(t, resp, &reminders)
+		return reminders
+	}
+
+	// exactly one of at and minutesBefore, on an enabled channel, in the future
+	for _, body := range []fiber.Map{
+		{"channel": "email"},
+		{"channel": "email", "at": "2029-12-31T09:00", "minutesBefore": 30},
+		{"channel": "sms", "minutesBefore": 30},
+		{"channel": "webhook", "minutesBefore": 30},
+		{"channel": "email", "minutesBefore": -5},
+		{"channel": "email", "at": "tomorrow"},
+		{"channel": "email", "at": "2020-01-01"},
+	} {
+		add(bob, path, body, http.StatusUnprocessableEntity)
+	}
+	plain := ta.createTodo(bob, "someday", nil)
+	add(bob, "/api/todo/"+plain.ID.Hex()+"/reminders", fiber.Map{"channel": "email", "minutesBefore": 30}, http.StatusUnprocessableEntity)
+
+	// an offset reminder fires before the due date, an absolute one is read in the todo's timezone
+	before := add(bob, path, fiber.Map{"channel": "email", "minutesBefore": 30}, http.StatusCreated)
+	if before.Status != models.ReminderPending || before.FireAt == nil || !before.FireAt.Equal(todo.DueAt.Add(-30*time.Minute)) {
+		t.Fatalf("unexpected reminder %+v", before)
+	}
+	at := add(bob, path, fiber.Map{"channel": "email", "at": "2029-12-31T09:00"}, http.StatusCreated)
+	if at.FireAt.Format(time.RFC3339) != "2029-12-31T08:00:00Z" {
+		t.Fatalf("unexpected reminder time %v", at.FireAt)
+	}
+
+	// collaborators set their own reminders, strangers can't
+	add(dave, path, fiber.Map{"channel": "email", "minutesBefore": 10}, http.StatusForbidden)
+	ta.share(bob, carol, "/api/todo/"+todo.ID.Hex(), "viewer")
+	carols := add(carol, path, fiber.Map{"channel": "email", "minutesBefore": 60}, http.StatusCreated)
+	if got := list(bob); len(got) != 2 || got[0].ID != before.ID || got[1].ID != at.ID {
+		t.Fatalf("expected bob's two reminders, got %+v", got)
+	}
+	if got := list(carol); len(got) != 1 || got[0].ID != carols.ID {
+		t.Fatalf("expected carol's reminder, got %+v", got)
+	}
+	resp = ta.request("DELETE", path+"/"+before.ID.Hex(), nil, carol.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+
+	// moving the due date moves the offset reminders, clearing it holds them
+	resp = ta.request("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"dueAt": "2030-01-03T10:00"}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	got := list(bob)
+	if got[0].FireAt.Format(time.RFC3339) != "2030-01-03T08:30:00Z" || !got[1].FireAt.Equal(*at.FireAt) {
+		t.Fatalf("unexpected rescheduled reminders %+v %+v", got[0].FireAt, got[1].FireAt)
+	}
+	resp = ta.request("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"dueAt": ""}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	if got := list(bob); got[0].FireAt != nil || got[0].Status != models.ReminderPending {
+		t.Fatalf("expected the offset reminder on hold, got %+v", got[0])
+	}
+
+	resp = ta.request("DELETE", path+"/"+at.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("DELETE", path+"/"+at.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+
+	// a user has at most 10 per todo
+	for range 9 {
+		add(bob, path, fiber.Map{"channel": "email", "at": "2029-12-31T09:00"}, http.StatusCreated)
+	}
+	add(bob, path, fiber.Map{"channel": "email", "at": "2029-12-31T09:00"}, http.StatusConflict)
+
+	// they go with the todo
+	resp = ta.request("DELETE", "/api/todo/"+todo.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	if left, _ := ta.repos.Reminders.FindByTodo(context.Background(), todo.ID, primitive.ObjectID{}); len(left) != 0 {
+		t.Fatalf("expected the reminders deleted, got %d", len(left))
+	}
+}
+
+func TestRecurringReminders(t *testing.T) {
+	enableEmailReminders(t)
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+
+	resp := ta.request("POST", "/api/todo/register", fiber.Map{
+		"title": "standup", "userId": bob.ID.Hex(), "dueAt": "2030-03-04T09:30", "dueTimezone": "UTC",
+		"recurrence": "FREQ=WEEKLY;BYDAY=MO,WE",
+	}, bob.Token)
+	expectStatus(t, resp, http.StatusCreated)
+	var todo models.Todo
+	decode(t, resp, &todo)
+	path := "/api/todo/" + todo.ID.Hex()
+	for _, body := range []fiber.Map{{"channel": "email", "minutesBefore": 15}, {"channel": "email", "at": "2030-03-01"}} {
+		resp = ta.request("POST", path+"/reminders", body, bob.Token)
+		expectStatus(t, resp, http.StatusCreated)
+	}
+
+	// skipping moves the offset reminder to the next occurrence
+	resp = ta.request("POST", path+"/skip", nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	reminders, _ := ta.repos.Reminders.FindByTodo(context.Background(), todo.ID, bob.ID)
+	if reminders[0].FireAt.Format(time.RFC3339) != "2030-03-06T09:15:00Z" {
+		t.Fatalf("unexpected reminder after a skip %v", reminders[0].FireAt)
+	}
+
+	// the next occurrence gets the offset reminder, not the absolute one
+	resp = ta.request("PUT", path, fiber.Map{"completed": true}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &todo)
+	next, _ := ta.repos.Reminders.FindByTodo(context.Background(), *todo.Recurrence.NextID, bob.ID)
+	if len(next) != 1 || next[0].FireAt.Format(time.RFC3339) != "2030-03-11T09:15:00Z" || *next[0].MinutesBefore != 15 {
+		t.Fatalf("unexpected reminders on the next occurrence %+v", next)
+	}
+}
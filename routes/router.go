@@ -3,52 +3,134 @@ package routes
 import (
 	"time"
 
+	"github.com/clinton-mwachia/go-fiber-api-template/apperrors"
 	"github.com/clinton-mwachia/go-fiber-api-template/config"
 	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/clinton-mwachia/go-fiber-api-template/docs"
 	"github.com/clinton-mwachia/go-fiber-api-template/middlewares"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/clinton-mwachia/go-fiber-api-template/storage"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 )
 
-func SetUpRouter(app *fiber.App) {
-	app.Use(logger.New())
+// SetUpRouter registers every route, handlers get their data through repos
+// and uploaded files through files, signer checks signed file URLs
+func SetUpRouter(app *fiber.App, repos *repositories.Repositories, files storage.Storage, signer *storage.Signer) {
+	app.Use(requestid.New())
+	app.Use(logger.New(logger.Config{
+		Format: "${time} | ${status} | ${latency} | ${ip} | ${method} | ${path} | ${locals:requestid} | ${error}\n",
+	}))
 
 	api := app.Group("/api")
 
-	api.Post("/login", controllers.Login)
+	// controllers
+	auth := controllers.NewAuthController(repos.Users, repos.Sessions)
+	users := controllers.NewUserController(repos.Users, repos.Sessions)
+	todos := controllers.NewTodoController(repos.Todos, repos.Users, repos.Lists, repos.Invitations, repos.Reminders, repos.Attachments, files)
+	lists := controllers.NewListController(repos.Lists, todos)
+	shares := controllers.NewShareController(repos.Todos, repos.Lists, repos.Users, repos.Invitations)
+	attachments := controllers.NewAttachmentController(repos.Attachments, files)
+	fileServer := controllers.NewFileController(files, repos.Todos, repos.Lists, repos.Attachments, signer)
 
-	//api.Use(middlewares.AuthRequired())
+	// api documentation
+	api.Get("/openapi.json", docs.SpecHandler(app))
+	api.Get("/docs", docs.UIHandler())
 
-	// get collections
-	todoCollection := config.GetCollection("todos")
+	// public routes
+	api.Post("/login", auth.Login)
+	api.Post("/token/refresh", auth.RefreshToken)
+	api.Post("/user/register", users.Register)
+	// signed file URLs, unsigned requests continue to the authenticated route below
+	api.Get("/files/+", fileServer.ServeSigned)
+
+	// everything below requires a valid access token
+	api.Use(middlewares.AuthRequired(repos.Sessions))
+
+	api.Post("/logout", auth.Logout)
+	api.Post("/logout-all", auth.LogoutAll)
 
 	// users routes
-	api.Post("/user/register", controllers.Register)
-	api.Get("/users", controllers.GetAllUsers)
-	api.Get("/user/:id", controllers.GetUserByID)
-	api.Get("/users/paginated", controllers.GetPaginatedUsers)
-	api.Put("/user/:id", controllers.UpdateUser)
-	api.Delete("/user/:id", controllers.DeleteUser)
-	api.Put("/change-password/:id", controllers.ChangePassword)
-	api.Put("/reset-password/:id", controllers.ResetPassword)
+	api.Get("/users", middlewares.RequirePermission(config.PermUsersRead), users.GetAllUsers)
+	api.Get("/user/:id", middlewares.RequireSelfOrPermission("id", config.PermUsersRead), users.GetUserByID)
+	api.Get("/users/paginated", middlewares.RequirePermission(config.PermUsersRead), users.GetPaginatedUsers)
+	api.Put("/user/:id", middlewares.RequireSelfOrPermission("id", config.PermUsersWrite), users.UpdateUser)
+	api.Delete("/user/:id", middlewares.RequirePermission(config.PermUsersDelete), users.DeleteUser)
+	api.Put("/change-password/:id", middlewares.RequireSelfOrPermission("id", config.PermUsersWrite), users.ChangePassword)
+	api.Put("/reset-password/:id", middlewares.RequirePermission(config.PermPasswordsReset), users.ResetPassword)
+
+	// a todo or list may be used by its owner and its collaborators, as far as their role allows
+	canViewTodo := middlewares.EnsureTodoAccess(repos.Todos, repos.Lists, models.AccessView)
+	canEditTodo := middlewares.EnsureTodoAccess(repos.Todos, repos.Lists, models.AccessEdit)
+	ownsTodo := middlewares.EnsureTodoAccess(repos.Todos, repos.Lists, models.AccessOwner)
+	canViewList := middlewares.EnsureListAccess(repos.Lists, models.AccessView)
+	canEditList := middlewares.EnsureListAccess(repos.Lists, models.AccessEdit)
+	ownsList := middlewares.EnsureListAccess(repos.Lists, models.AccessOwner)
 
 	// todos routes
-	api.Post("/todo/register", controllers.CreateTodo)
-	api.Get("/todos", controllers.GetTodos)
-	api.Delete("/todo/:id", middlewares.EnsureTodoOwner(todoCollection), controllers.DeleteTodo)
-	api.Put("/todo/:id", controllers.UpdateTodo)
-	api.Get("/todo/:id", controllers.GetTodoByID)
-	api.Get("/todos/:userId/count", controllers.CountTodosByUserID)
+	api.Post("/todo/register", middlewares.RequirePermission(config.PermTodosWrite), todos.CreateTodo)
+	api.Get("/todos", middlewares.RequirePermission(config.PermTodosReadAll), todos.GetTodos)
+	api.Delete("/todo/:id", middlewares.RequirePermission(config.PermTodosWrite), ownsTodo, todos.DeleteTodo)
+	api.Put("/todo/:id", middlewares.RequirePermission(config.PermTodosWrite), canEditTodo, todos.UpdateTodo)
+	api.Get("/todo/:id", middlewares.RequirePermission(config.PermTodosRead), canViewTodo, todos.GetTodoByID)
+	api.Post("/todo/:id/move", middlewares.RequirePermission(config.PermTodosWrite), canEditTodo, todos.MoveTodo)
+	api.Post("/todo/:id/skip", middlewares.RequirePermission(config.PermTodosWrite), canEditTodo, todos.SkipOccurrence)
+	api.Get("/todo/:id/occurrences", middlewares.RequirePermission(config.PermTodosRead), canViewTodo, todos.GetOccurrences)
+	api.Get("/todos/:userId/count", middlewares.RequireSelfOrPermission("userId", config.PermTodosReadAll), todos.CountTodosByUserID)
 	// the api only make 3 requests per minute
-	api.Get("/todos/count", limiter.New(limiter.Config{
+	api.Get("/todos/count", middlewares.RequirePermission(config.PermTodosReadAll), limiter.New(limiter.Config{
 		Max:        3,               // max requests
 		Expiration: 1 * time.Minute, // per minute
 		LimitReached: func(c *fiber.Ctx) error {
-			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-				"error": "Too many requests, please try after 1 minute",
-			})
+			return apperrors.TooManyRequests("Too many requests, please try after 1 minute")
 		},
-	}), controllers.CountTodos)
-	api.Get("/todos/:userId", controllers.GetTodosByUserID)
+	}), todos.CountTodos)
+	api.Get("/todos/:userId", middlewares.RequireSelfOrPermission("userId", config.PermTodosReadAll), todos.GetTodosByUserID)
+
+	// lists routes
+	api.Post("/list/register", middlewares.RequirePermission(config.PermTodosWrite), lists.CreateList)
+	api.Get("/lists", middlewares.RequirePermission(config.PermTodosRead), lists.GetLists)
+	api.Get("/list/:id", middlewares.RequirePermission(config.PermTodosRead), canViewList, lists.GetList)
+	api.Put("/list/:id", middlewares.RequirePermission(config.PermTodosWrite), canEditList, lists.UpdateList)
+	api.Delete("/list/:id", middlewares.RequirePermission(config.PermTodosWrite), ownsList, lists.DeleteList)
+	api.Get("/list/:id/todos", middlewares.RequirePermission(config.PermTodosRead), canViewList, lists.GetListTodos)
+
+	// sharing routes, only owners invite and change roles, collaborators may leave
+	api.Post("/todo/:id/shares", middlewares.RequirePermission(config.PermTodosWrite), ownsTodo, shares.InviteToTodo)
+	api.Get("/todo/:id/shares", middlewares.RequirePermission(config.PermTodosRead), canViewTodo, shares.GetTodoShares)
+	api.Put("/todo/:id/shares/:userId", middlewares.RequirePermission(config.PermTodosWrite), ownsTodo, shares.UpdateTodoShare)
+	api.Delete("/todo/:id/shares/:userId", middlewares.RequirePermission(config.PermTodosRead), canViewTodo, shares.RemoveTodoShare)
+	api.Post("/list/:id/shares", middlewares.RequirePermission(config.PermTodosWrite), ownsList, shares.InviteToList)
+	api.Get("/list/:id/shares", middlewares.RequirePermission(config.PermTodosRead), canViewList, shares.GetListShares)
+	api.Put("/list/:id/shares/:userId", middlewares.RequirePermission(config.PermTodosWrite), ownsList, shares.UpdateListShare)
+	api.Delete("/list/:id/shares/:userId", middlewares.RequirePermission(config.PermTodosRead), canViewList, shares.RemoveListShare)
+	api.Get("/invitations", middlewares.RequirePermission(config.PermTodosRead), shares.GetInvitations)
+	api.Post("/invitation/:id/accept", middlewares.RequirePermission(config.PermTodosRead), shares.AcceptInvitation)
+	api.Post("/invitation/:id/decline", middlewares.RequirePermission(config.PermTodosRead), shares.DeclineInvitation)
+	api.Delete("/invitation/:id", middlewares.RequirePermission(config.PermTodosWrite), shares.CancelInvitation)
+
+	// attachments routes
+	api.Post("/todo/:id/attachments", middlewares.RequirePermission(config.PermTodosWrite), canEditTodo, attachments.AddAttachment)
+	api.Get("/todo/:id/attachments", middlewares.RequirePermission(config.PermTodosRead), canViewTodo, attachments.GetAttachments)
+	api.Get("/todo/:id/attachments/:attachmentId", middlewares.RequirePermission(config.PermTodosRead), canViewTodo, attachments.GetAttachment)
+	api.Get("/todo/:id/attachments/:attachmentId/download", middlewares.RequirePermission(config.PermTodosRead), canViewTodo, attachments.DownloadAttachment)
+	api.Delete("/todo/:id/attachments/:attachmentId", middlewares.RequirePermission(config.PermTodosWrite), canEditTodo, attachments.DeleteAttachment)
+
+	// checklist routes
+	api.Post("/todo/:id/items", middlewares.RequirePermission(config.PermTodosWrite), canEditTodo, todos.AddItem)
+	api.Get("/todo/:id/items", middlewares.RequirePermission(config.PermTodosRead), canViewTodo, todos.GetItems)
+	api.Get("/todo/:id/items/:itemId", middlewares.RequirePermission(config.PermTodosRead), canViewTodo, todos.GetItem)
+	api.Put("/todo/:id/items/:itemId", middlewares.RequirePermission(config.PermTodosWrite), canEditTodo, todos.UpdateItem)
+	api.Delete("/todo/:id/items/:itemId", middlewares.RequirePermission(config.PermTodosWrite), canEditTodo, todos.DeleteItem)
+
+	// reminders routes, everyone who can see a todo manages their own reminders on it
+	api.Post("/todo/:id/reminders", middlewares.RequirePermission(config.PermTodosRead), canViewTodo, todos.AddReminder)
+	api.Get("/todo/:id/reminders", middlewares.RequirePermission(config.PermTodosRead), canViewTodo, todos.GetReminders)
+	api.Delete("/todo/:id/reminders/:reminderId", middlewares.RequirePermission(config.PermTodosRead), canViewTodo, todos.DeleteReminder)
+
+	// files routes
+	api.Get("/files/+", middlewares.RequirePermission(config.PermTodosRead), fileServer.ServeFile)
 }
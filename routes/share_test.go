@@ -0,0 +1,336 @@
+package routes_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/repositories"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// share invites the user to the todo or list at path and has them accept
+func (ta *testApp) share(owner, u testUser, path, role string) models.Invitation {
+	ta.t.Helper()
+	resp := ta.request("POST", path+"/shares", fiber.Map{"user": u.Email, "role": role}, owner.Token)
+	expectStatus(ta.t, resp, http.StatusCreated)
+	var invitation models.Invitation
+	decode(ta.t, resp, &invitation)
+
+	resp = ta.request("POST", "/api/invitation/"+invitation.ID.Hex()+"/accept", nil, u.Token)
+	expectStatus(ta.t, resp, http.StatusOK)
+	decode(ta.t, resp, &invitation)
+	return invitation
+}
+
+func (ta *testApp) invitations(u testUser, status string) []models.Invitation {
+	ta.t.Helper()
+	resp := ta.request("GET", "/api/invitations?status="+status, nil, u.Token)
+	expectStatus(ta.t, resp, http.StatusOK)
+	var page controllers.ListResponse[models.Invitation]
+	decode(ta.t, resp, &page)
+	return page.Data
+}
+
+func TestShareTodo(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	dave := ta.register("dave", "dave@example.com")
+	todo := ta.createTodo(bob, "plan trip", nil)
+	path := "/api/todo/" + todo.ID.Hex()
+
+	// invitations by username or email, the owner and unknown users can't be invited
+	resp := ta.request("POST", path+"/shares", fiber.Map{"user": "carol", "role": "viewer"}, bob.Token)
+	expectStatus(t, resp, http.StatusCreated)
+	var invitation models.Invitation
+	decode(t, resp, &invitation)
+	if invitation.UserID != carol.ID || invitation.Status != models.InvitationPending || invitation.Name != "plan trip" {
+		t.Fatalf("unexpected invitation %+v", invitation)
+	}
+	resp = ta.request("POST", path+"/shares", fiber.Map{"user": "carol@example.com", "role": "editor"}, bob.Token)
+	expectStatus(t, resp, http.StatusConflict)
+	resp = ta.request("POST", path+"/shares", fiber.Map{"user": "bob", "role": "viewer"}, bob.Token)
+	expectStatus(t, resp, http.StatusBadRequest)
+	resp = ta.request("POST", path+"/shares", fiber.Map{"user": "nobody", "role": "viewer"}, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+	resp = ta.request("POST", path+"/shares", fiber.Map{"user": "dave", "role": "owner"}, bob.Token)
+	expectStatus(t, resp, http.StatusUnprocessableEntity)
+	resp = ta.request("POST", path+"/shares", fiber.Map{"user": "dave", "role": "viewer"}, carol.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	// nothing is shared until the invitation is accepted
+	resp = ta.request("GET", path, nil, carol.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+	if got := ta.invitations(carol, "pending"); len(got) != 1 || got[0].ID != invitation.ID {
+		t.Fatalf("expected the pending invitation, got %+v", got)
+	}
+	resp = ta.request("POST", "/api/invitation/"+invitation.ID.Hex()+"/accept", nil, dave.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+	resp = ta.request("POST", "/api/invitation/"+invitation.ID.Hex()+"/accept", nil, bob.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+	resp = ta.request("POST", "/api/invitation/"+invitation.ID.Hex()+"/accept", nil, carol.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("POST", "/api/invitation/"+invitation.ID.Hex()+"/decline", nil, carol.Token)
+	expectStatus(t, resp, http.StatusConflict)
+	if got := ta.invitations(carol, "accepted"); len(got) != 1 {
+		t.Fatalf("expected the accepted invitation, got %+v", got)
+	}
+
+	// a viewer can read but not change the todo
+	resp = ta.request("GET", path, nil, carol.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("GET", path+"/items", nil, carol.Token)
+	expectStatus(t, resp, http.StatusOK)
+	for _, resp := range []*http.Response{
+		ta.request("PUT", path, fiber.Map{"title": "mine"}, carol.Token),
+		ta.request("POST", path+"/items", fiber.Map{"title": "x"}, carol.Token),
+		ta.request("DELETE", path, nil, carol.Token),
+	} {
+		expectStatus(t, resp, http.StatusForbidden)
+	}
+
+	// an editor can change it but not delete or share it
+	resp = ta.request("PUT", path+"/shares/"+carol.ID.Hex(), fiber.Map{"role": "editor"}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("PUT", path, fiber.Map{"title": "plan holiday"}, carol.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("POST", path+"/items", fiber.Map{"title": "book flights"}, carol.Token)
+	expectStatus(t, resp, http.StatusCreated)
+	for _, resp := range []*http.Response{
+		ta.request("DELETE", path, nil, carol.Token),
+		ta.request("POST", path+"/shares", fiber.Map{"user": "dave", "role": "viewer"}, carol.Token),
+		ta.request("PUT", path+"/shares/"+carol.ID.Hex(), fiber.Map{"role": "viewer"}, carol.Token),
+	} {
+		expectStatus(t, resp, http.StatusForbidden)
+	}
+
+	// shared todos show up in the collaborator's own listing
+	ta.createTodo(carol, "groceries", nil)
+	get := func(path string) string {
+		t.Helper()
+		resp := ta.request("GET", path, nil, carol.Token)
+		expectStatus(t, resp, http.StatusOK)
+		var page controllers.ListResponse[models.Todo]
+		decode(t, resp, &page)
+		return todoTitles(page.Data)
+	}
+	if got := get("/api/todos/" + carol.ID.Hex()); got != "plan holiday,groceries" {
+		t.Fatalf("expected own and shared todos, got %s", got)
+	}
+	if got := get("/api/todos/" + carol.ID.Hex() + "?shared=false"); got != "groceries" {
+		t.Fatalf("expected only own todos, got %s", got)
+	}
+
+	// the owner sees collaborators and pending invitations
+	resp = ta.request("POST", path+"/shares", fiber.Map{"user": "dave", "role": "viewer"}, bob.Token)
+	expectStatus(t, resp, http.StatusCreated)
+	var pending models.Invitation
+	decode(t, resp, &pending)
+	resp = ta.request("GET", path+"/shares", nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var shares controllers.Shares
+	decode(t, resp, &shares)
+	if shares.OwnerID != bob.ID || len(shares.Collaborators) != 1 || shares.Collaborators[0].Role != models.ShareEditor ||
+		len(shares.Invitations) != 1 || shares.Invitations[0].UserID != dave.ID {
+		t.Fatalf("unexpected shares %+v", shares)
+	}
+	resp = ta.request("POST", path+"/shares", fiber.Map{"user": "carol", "role": "viewer"}, bob.Token)
+	expectStatus(t, resp, http.StatusConflict)
+
+	// only the sender can withdraw an invitation, declining keeps it out of the todo
+	resp = ta.request("DELETE", "/api/invitation/"+pending.ID.Hex(), nil, dave.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+	resp = ta.request("POST", "/api/invitation/"+pending.ID.Hex()+"/decline", nil, dave.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("GET", path, nil, dave.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+	resp = ta.request("DELETE", "/api/invitation/"+pending.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	if got := ta.invitations(dave, "declined"); len(got) != 0 {
+		t.Fatalf("expected the invitation withdrawn, got %+v", got)
+	}
+
+	// a collaborator can leave, but not remove others
+	resp = ta.request("DELETE", path+"/shares/"+bob.ID.Hex(), nil, carol.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+	resp = ta.request("DELETE", path+"/shares/"+carol.ID.Hex(), nil, carol.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("GET", path, nil, carol.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+	resp = ta.request("DELETE", path+"/shares/"+carol.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+}
+
+func TestShareList(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	dave := ta.register("dave", "dave@example.com")
+	work := ta.createList(bob, fiber.Map{"name": "Work"})
+	report := ta.fileTodo(bob, "report", work.ID)
+	path := "/api/list/" + work.ID.Hex()
+
+	ta.share(bob, carol, path, models.ShareViewer)
+	ta.share(bob, dave, path, models.ShareEditor)
+
+	// the list and its todos are shared
+	if got := ta.listNames(carol, "/api/lists"); len(got) != 1 || got[0] != "Work" {
+		t.Fatalf("expected the shared list, got %v", got)
+	}
+	if got := ta.listNames(carol, "/api/lists?shared=false"); len(got) != 0 {
+		t.Fatalf("expected no own lists, got %v", got)
+	}
+	resp := ta.request("GET", path+"/todos", nil, carol.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("GET", "/api/todo/"+report.ID.Hex(), nil, carol.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("PUT", "/api/todo/"+report.ID.Hex(), fiber.Map{"completed": true}, carol.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	// viewers can't file todos in the list, editors can and may edit the list's todos
+	resp = ta.request("POST", "/api/todo/register", fiber.Map{"title": "x", "userId": carol.ID.Hex(), "listId": work.ID.Hex()}, carol.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+	slides := ta.fileTodo(dave, "slides", work.ID)
+	resp = ta.request("PUT", "/api/todo/"+report.ID.Hex(), fiber.Map{"completed": true}, dave.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("PUT", path, fiber.Map{"name": "Office"}, dave.Token)
+	expectStatus(t, resp, http.StatusOK)
+	// archiving and ordering stay with the owner
+	for _, body := range []fiber.Map{{"archived": true}, {"position": 0}, {"name": "Desk", "position": 3}} {
+		resp = ta.request("PUT", path, body, dave.Token)
+		expectStatus(t, resp, http.StatusForbidden)
+	}
+	if got := ta.listNames(bob, "/api/lists"); len(got) != 1 || got[0] != "Office" {
+		t.Fatalf("expected the list unchanged, got %v", got)
+	}
+	for _, resp := range []*http.Response{
+		ta.request("DELETE", "/api/todo/"+report.ID.Hex(), nil, dave.Token),
+		ta.request("DELETE", path, nil, dave.Token),
+		ta.request("PUT", path, fiber.Map{"name": "Mine"}, carol.Token),
+	} {
+		expectStatus(t, resp, http.StatusForbidden)
+	}
+
+	// the owner sees todos filed by collaborators
+	resp = ta.request("GET", path+"/todos", nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var page controllers.ListResponse[models.Todo]
+	decode(t, resp, &page)
+	if got := todoTitles(page.Data); got != "report,slides" {
+		t.Fatalf("expected the list's todos, got %s", got)
+	}
+	resp = ta.request("GET", "/api/todo/"+slides.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	// deleting the list ends the sharing
+	resp = ta.request("POST", path+"/shares", fiber.Map{"user": "eve", "role": "viewer"}, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+	resp = ta.request("DELETE", path+"?todos=delete", nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var deleted struct {
+		MovedTodos   int64 `json:"movedTodos"`
+		DeletedTodos int64 `json:"deletedTodos"`
+	}
+	decode(t, resp, &deleted)
+	if deleted.DeletedTodos != 1 || deleted.MovedTodos != 1 {
+		t.Fatalf("expected the owner's todo deleted and the editor's moved, got %+v", deleted)
+	}
+	// the editor keeps the todo they filed, in their inbox
+	resp = ta.request("GET", "/api/todos/"+dave.ID.Hex()+"?list=inbox", nil, dave.Token)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &page)
+	if got := todoTitles(page.Data); got != "slides" || page.Data[0].ID != slides.ID {
+		t.Fatalf("expected the editor's todo in their inbox, got %s", got)
+	}
+	resp = ta.request("GET", "/api/todo/"+report.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+	if got := ta.listNames(carol, "/api/lists"); len(got) != 0 {
+		t.Fatalf("expected the list gone, got %v", got)
+	}
+	resp = ta.request("GET", path+"/shares", nil, carol.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+	resp = ta.request("POST", "/api/invitation/"+primitive.NewObjectID().Hex()+"/accept", nil, carol.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+}
+
+func TestTodoEditorKeepsTodoInList(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	dave := ta.register("dave", "dave@example.com")
+	erin := ta.register("erin", "erin@example.com")
+	list := ta.createList(bob, fiber.Map{"name": "Team"})
+	todo := ta.fileTodo(bob, "plan trip", list.ID)
+	path := "/api/todo/" + todo.ID.Hex()
+
+	// carol sees the todo through the list, dave edits just the todo, erin edits the list
+	ta.share(bob, carol, "/api/list/"+list.ID.Hex(), "viewer")
+	ta.share(bob, dave, path, "editor")
+	ta.share(bob, erin, "/api/list/"+list.ID.Hex(), "editor")
+
+	// taking it out of the list would hide it from carol
+	resp := ta.request("PUT", path, fiber.Map{"listId": ""}, dave.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+	resp = ta.request("POST", path+"/move", fiber.Map{"listId": ""}, dave.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+	resp = ta.request("GET", path, nil, carol.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	// other changes are fine
+	resp = ta.request("PUT", path, fiber.Map{"title": "plan the trip"}, dave.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	// editors of the list can
+	resp = ta.request("PUT", path, fiber.Map{"listId": ""}, erin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("GET", path, nil, carol.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+}
+
+// flakyTodos fails to add collaborators while fail is set
+type flakyTodos struct {
+	repositories.TodoRepository
+	fail bool
+}
+
+func (f *flakyTodos) SetCollaborator(ctx context.Context, todoID primitive.ObjectID, collaborator models.Collaborator) (models.Todo, error) {
+	if f.fail {
+		return models.Todo{}, errors.New("write failed")
+	}
+	return f.TodoRepository.SetCollaborator(ctx, todoID, collaborator)
+}
+
+func TestAcceptInvitationCanBeRetried(t *testing.T) {
+	repos := repositories.NewMemoryRepositories()
+	todos := &flakyTodos{TodoRepository: repos.Todos}
+	repos.Todos = todos
+	ta := newTestAppWith(t, repos)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	todo := ta.createTodo(bob, "plan trip", nil)
+
+	resp := ta.request("POST", "/api/todo/"+todo.ID.Hex()+"/shares", fiber.Map{"user": "carol", "role": "viewer"}, bob.Token)
+	expectStatus(t, resp, http.StatusCreated)
+	var invitation models.Invitation
+	decode(t, resp, &invitation)
+	accept := "/api/invitation/" + invitation.ID.Hex() + "/accept"
+
+	// a failed accept leaves the invitation pending
+	todos.fail = true
+	resp = ta.request("POST", accept, nil, carol.Token)
+	expectStatus(t, resp, http.StatusInternalServerError)
+	if got := ta.invitations(carol, "pending"); len(got) != 1 || got[0].ID != invitation.ID {
+		t.Fatalf("expected the invitation still pending, got %+v", got)
+	}
+
+	todos.fail = false
+	resp = ta.request("POST", accept, nil, carol.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("GET", "/api/todo/"+todo.ID.Hex(), nil, carol.Token)
+	expectStatus(t, resp, http.StatusOK)
+}
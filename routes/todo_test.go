@@ -0,0 +1,503 @@
+package routes_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/storage"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// createTodo creates a todo for the user through the API
+func (ta *testApp) createTodo(u testUser, title string, file *upload) models.Todo {
+	ta.t.Helper()
+	resp := ta.multipart("POST", "/api/todo/register", map[string]string{
+		"title":  title,
+		"userId": u.ID.Hex(),
+	}, file, u.Token)
+	expectStatus(ta.t, resp, http.StatusCreated)
+
+	var todo models.Todo
+	decode(ta.t, resp, &todo)
+	return todo
+}
+
+// imageStored reports whether the todo's image and every variant are in storage
+func (ta *testApp) imageStored(todo models.Todo) bool {
+	ta.t.Helper()
+	keys := []string{todo.Image}
+	for _, key := range todo.ImageVariants {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		_, err := ta.files.Stat(context.Background(), key)
+		if errors.Is(err, storage.ErrNotFound) {
+			return false
+		}
+		if err != nil {
+			ta.t.Fatal(err)
+		}
+	}
+	return true
+}
+
+func TestCreateTodo(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+
+	todo := ta.createTodo(bob, "buy milk", nil)
+	if todo.Title != "buy milk" || todo.UserID != bob.ID || todo.Completed {
+		t.Fatalf("unexpected todo %+v", todo)
+	}
+
+	withImage := ta.createTodo(bob, "with image", imageUpload("photo.png"))
+	if withImage.Image == "" {
+		t.Fatal("expected image path")
+	}
+	if len(withImage.ImageVariants) != 2 || withImage.ImageVariants["thumb"] == "" || withImage.ImageVariants["medium"] == "" {
+		t.Fatalf("expected thumb and medium variants, got %v", withImage.ImageVariants)
+	}
+	if !ta.imageStored(withImage) {
+		t.Fatal("expected uploaded image and variants in storage")
+	}
+	for _, name := range []string{"original", "thumb", "medium"} {
+		if !strings.HasPrefix(withImage.ImageURLs[name], "/api/files/todos/") {
+			t.Fatalf("expected a signed %s URL, got %v", name, withImage.ImageURLs)
+		}
+	}
+	if todo.ImageVariants != nil || todo.ImageURLs != nil {
+		t.Fatalf("expected no image fields without an upload, got %+v", todo)
+	}
+
+	resp := ta.multipart("POST", "/api/todo/register", map[string]string{"userId": bob.ID.Hex()}, nil, bob.Token)
+	expectStatus(t, resp, http.StatusUnprocessableEntity)
+
+	resp = ta.multipart("POST", "/api/todo/register", map[string]string{"title": "x", "userId": "nope"}, nil, bob.Token)
+	expectStatus(t, resp, http.StatusUnprocessableEntity)
+
+	resp = ta.multipart("POST", "/api/todo/register", map[string]string{
+		"title":  "ghost",
+		"userId": primitive.NewObjectID().Hex(),
+	}, nil, bob.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+}
+
+func TestCreateTodoForOtherUsers(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	admin := ta.registerAdmin("admin", "admin@example.com")
+
+	// users only create their own todos
+	resp := ta.request("POST", "/api/todo/register", fiber.Map{"title": "spam", "userId": carol.ID.Hex()}, bob.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+	resp = ta.request("GET", "/api/todos/"+carol.ID.Hex(), nil, carol.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var page controllers.ListResponse[models.Todo]
+	decode(t, resp, &page)
+	if len(page.Data) != 0 {
+		t.Fatalf("expected no todos for carol, got %s", todoTitles(page.Data))
+	}
+
+	// roles that may write every todo create them for anyone who exists
+	resp = ta.request("POST", "/api/todo/register", fiber.Map{"title": "welcome", "userId": carol.ID.Hex()}, admin.Token)
+	expectStatus(t, resp, http.StatusCreated)
+	var todo models.Todo
+	decode(t, resp, &todo)
+	if todo.UserID != carol.ID {
+		t.Fatalf("expected a todo owned by carol, got %s", todo.UserID.Hex())
+	}
+	resp = ta.request("POST", "/api/todo/register", fiber.Map{"title": "ghost", "userId": primitive.NewObjectID().Hex()}, admin.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+}
+
+func TestCreateTodoRejectsUnsafeUploads(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	fields := map[string]string{"title": "x", "userId": bob.ID.Hex()}
+
+	// the client's name is ignored, keys are random and typed from the content
+	todo := ta.createTodo(bob, "traversal", imageUpload("../../etc/passwd.JPG"))
+	if !strings.HasPrefix(todo.Image, "todos/") || !strings.HasSuffix(todo.Image, ".png") || strings.Contains(todo.Image, "passwd") {
+		t.Fatalf("expected a random png key, got %q", todo.Image)
+	}
+	other := ta.createTodo(bob, "same name", imageUpload("../../etc/passwd.JPG"))
+	if other.Image == todo.Image {
+		t.Fatal("expected uploads with the same name not to collide")
+	}
+
+	resp := ta.multipart("POST", "/api/todo/register", fields, &upload{Field: "image", FileName: "cat.png", Content: []byte("<html>not an image</html>")}, bob.Token)
+	expectStatus(t, resp, http.StatusUnsupportedMediaType)
+
+	big := imageUpload("big.png")
+	big.Content = append(big.Content, make([]byte, 64<<10)...)
+	resp = ta.multipart("POST", "/api/todo/register", fields, big, bob.Token)
+	expectStatus(t, resp, http.StatusRequestEntityTooLarge)
+
+	var p problem
+	decode(t, resp, &p)
+	if p.Code != "payload_too_large" {
+		t.Fatalf("expected payload_too_large, got %q", p.Code)
+	}
+}
+
+func TestGetTodos(t *testing.T) {
+	ta := newTestApp(t)
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	bob := ta.register("bob", "bob@example.com")
+	ta.createTodo(bob, "one", nil)
+	ta.createTodo(admin, "two", nil)
+
+	resp := ta.request("GET", "/api/todos", nil, bob.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	resp = ta.request("GET", "/api/todos", nil, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var todos controllers.ListResponse[models.Todo]
+	decode(t, resp, &todos)
+	if len(todos.Data) != 2 {
+		t.Fatalf("expected 2 todos, got %d", len(todos.Data))
+	}
+}
+
+func TestGetTodoByID(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	todo := ta.createTodo(bob, "one", nil)
+
+	resp := ta.request("GET", "/api/todo/"+todo.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var got models.Todo
+	decode(t, resp, &got)
+	if got.ID != todo.ID {
+		t.Fatalf("expected todo %s, got %s", todo.ID.Hex(), got.ID.Hex())
+	}
+
+	resp = ta.request("GET", "/api/todo/"+primitive.NewObjectID().Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+
+	resp = ta.request("GET", "/api/todo/bad-id", nil, bob.Token)
+	expectStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestUpdateTodo(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	todo := ta.createTodo(bob, "one", imageUpload("old.png"))
+
+	resp := ta.request("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"title": "renamed", "completed": true}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var got models.Todo
+	decode(t, resp, &got)
+	if got.Title != "renamed" || !got.Completed {
+		t.Fatalf("expected updated todo, got %+v", got)
+	}
+
+	resp = ta.multipart("PUT", "/api/todo/"+todo.ID.Hex(), nil, imageUpload("new.png"), bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &got)
+	if got.Image == todo.Image {
+		t.Fatal("expected image to be replaced")
+	}
+	if ta.imageStored(todo) {
+		t.Fatal("expected old image and variants to be removed")
+	}
+	if !ta.imageStored(got) || got.ImageURLs["thumb"] == "" {
+		t.Fatalf("expected new variants, got %+v", got)
+	}
+
+	resp = ta.request("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{}, bob.Token)
+	expectStatus(t, resp, http.StatusBadRequest)
+
+	resp = ta.request("PUT", "/api/todo/"+primitive.NewObjectID().Hex(), fiber.Map{"title": "x"}, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+}
+
+func TestTodoDetails(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+
+	resp := ta.request("POST", "/api/todo/register", fiber.Map{
+		"title":       "taxes",
+		"userId":      bob.ID.Hex(),
+		"description": "Collect the **receipts**",
+		"dueAt":       "2025-04-15T18:00",
+		"dueTimezone": "America/New_York",
+		"priority":    models.PriorityHigh,
+		"tags":        []string{" Finance ", "home", "finance"},
+	}, bob.Token)
+	expectStatus(t, resp, http.StatusCreated)
+	var todo models.Todo
+	decode(t, resp, &todo)
+	if todo.Description != "Collect the **receipts**" || todo.Priority != models.PriorityHigh {
+		t.Fatalf("expected description and priority, got %+v", todo)
+	}
+	if strings.Join(todo.Tags, ",") != "finance,home" {
+		t.Fatalf("expected normalized tags, got %v", todo.Tags)
+	}
+	// 18:00 in New York during daylight saving time
+	if todo.DueAt == nil || !todo.DueAt.Equal(time.Date(2025, 4, 15, 22, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected due date in the todo's timezone, got %v", todo.DueAt)
+	}
+	if _, offset := todo.DueAt.Zone(); offset != -4*3600 {
+		t.Fatalf("expected due date rendered in America/New_York, got %v", todo.DueAt)
+	}
+
+	resp = ta.request("GET", "/api/todo/"+todo.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var got models.Todo
+	decode(t, resp, &got)
+	if got.DueAt == nil || !got.DueAt.Equal(*todo.DueAt) || got.DueTimezone != "America/New_York" || len(got.Tags) != 2 {
+		t.Fatalf("expected the details on reads, got %+v", got)
+	}
+
+	resp = ta.multipart("POST", "/api/todo/register", map[string]string{
+		"title":    "call",
+		"userId":   bob.ID.Hex(),
+		"priority": "2",
+		"tags":     "Phone",
+		"dueAt":    "2025-01-02T09:00:00+01:00",
+	}, nil, bob.Token)
+	expectStatus(t, resp, http.StatusCreated)
+	decode(t, resp, &got)
+	if got.Priority != models.PriorityMedium || strings.Join(got.Tags, ",") != "phone" ||
+		got.DueAt == nil || !got.DueAt.Equal(time.Date(2025, 1, 2, 8, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected details from the form, got %+v", got)
+	}
+
+	// completedAt follows completed and is kept when completed again
+	resp = ta.request("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"completed": true}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &got)
+	if got.CompletedAt == nil {
+		t.Fatal("expected completedAt to be set")
+	}
+	completedAt := *got.CompletedAt
+	time.Sleep(2 * time.Millisecond)
+	resp = ta.request("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"completed": true, "priority": 0}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &got)
+	if got.CompletedAt == nil || !got.CompletedAt.Equal(completedAt) || got.Priority != models.PriorityNone {
+		t.Fatalf("expected completedAt to be kept, got %+v", got)
+	}
+	resp = ta.request("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"completed": false, "dueAt": "", "tags": []string{}}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	got = models.Todo{}
+	decode(t, resp, &got)
+	if got.CompletedAt != nil || got.DueAt != nil || len(got.Tags) != 0 {
+		t.Fatalf("expected completedAt, dueAt and tags to be cleared, got %+v", got)
+	}
+
+	// a local due date is read in the stored timezone
+	resp = ta.multipart("PUT", "/api/todo/"+todo.ID.Hex(), map[string]string{"dueAt": "2025-12-01"}, nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &got)
+	if got.DueAt == nil || !got.DueAt.Equal(time.Date(2025, 12, 1, 5, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected midnight in New York, got %v", got.DueAt)
+	}
+
+	for _, body := range []fiber.Map{
+		{"priority": 4},
+		{"dueTimezone": "Mars/Olympus"},
+		{"dueAt": "next tuesday"},
+		{"tags": []string{strings.Repeat("x", 51)}},
+		{"description": strings.Repeat("x", 10001)},
+	} {
+		resp = ta.request("PUT", "/api/todo/"+todo.ID.Hex(), body, bob.Token)
+		expectStatus(t, resp, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestTodoAuditFields(t *testing.T) {
+	ta := newTestApp(t)
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	bob := ta.register("bob", "bob@example.com")
+
+	todo := ta.createTodo(bob, "one", nil)
+	if todo.CreatedAt.IsZero() || !todo.UpdatedAt.Equal(todo.CreatedAt) {
+		t.Fatalf("expected creation timestamps, got %+v", todo.Audit)
+	}
+	if todo.CreatedBy == nil || *todo.CreatedBy != bob.ID || todo.UpdatedBy == nil || *todo.UpdatedBy != bob.ID {
+		t.Fatalf("expected bob as creator, got %+v", todo.Audit)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	resp := ta.request("PUT", "/api/todo/"+todo.ID.Hex(), fiber.Map{"completed": true}, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var got models.Todo
+	decode(t, resp, &got)
+	if !got.CreatedAt.Equal(todo.CreatedAt) || !got.UpdatedAt.After(todo.UpdatedAt) {
+		t.Fatalf("expected only updatedAt to move, got %+v", got.Audit)
+	}
+	if *got.CreatedBy != bob.ID || got.UpdatedBy == nil || *got.UpdatedBy != admin.ID {
+		t.Fatalf("expected admin as last editor, got %+v", got.Audit)
+	}
+}
+
+func TestDeleteTodoOwnership(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	todo := ta.createTodo(bob, "one", imageUpload("pic.png"))
+
+	resp := ta.request("DELETE", "/api/todo/"+todo.ID.Hex(), nil, carol.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	resp = ta.request("DELETE", "/api/todo/"+todo.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	for _, key := range []string{todo.Image, todo.ImageVariants["thumb"], todo.ImageVariants["medium"]} {
+		if _, err := ta.files.Stat(context.Background(), key); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected %s to be removed", key)
+		}
+	}
+
+	resp = ta.request("DELETE", "/api/todo/"+todo.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+
+	resp = ta.request("DELETE", "/api/todo/bad-id", nil, bob.Token)
+	expectStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestGetTodosByUserID(t *testing.T) {
+	ta := newTestApp(t)
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+	ta.createTodo(bob, "one", nil)
+	ta.createTodo(bob, "two", nil)
+	ta.createTodo(carol, "three", nil)
+
+	resp := ta.request("GET", "/api/todos/"+bob.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var todos controllers.ListResponse[models.Todo]
+	decode(t, resp, &todos)
+	if len(todos.Data) != 2 {
+		t.Fatalf("expected 2 todos, got %d", len(todos.Data))
+	}
+
+	resp = ta.request("GET", "/api/todos/"+bob.ID.Hex(), nil, carol.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	resp = ta.request("GET", "/api/todos/"+bob.ID.Hex(), nil, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+}
+
+func TestTodoFilters(t *testing.T) {
+	ta := newTestApp(t)
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+
+	create := func(u testUser, fields fiber.Map) models.Todo {
+		t.Helper()
+		fields["userId"] = u.ID.Hex()
+		resp := ta.request("POST", "/api/todo/register", fields, u.Token)
+		expectStatus(t, resp, http.StatusCreated)
+		var todo models.Todo
+		decode(t, resp, &todo)
+		return todo
+	}
+	create(bob, fiber.Map{"title": "File taxes", "description": "gather receipts", "priority": 3, "tags": []string{"finance", "home"}, "dueAt": "2025-04-15T00:00:00Z"})
+	create(bob, fiber.Map{"title": "Buy milk", "priority": 1, "tags": []string{"home"}, "dueAt": "2025-03-01T00:00:00Z"})
+	report := create(bob, fiber.Map{"title": "Expense report", "description": "attach the taxi receipts", "priority": 2, "tags": []string{"work"}})
+	create(carol, fiber.Map{"title": "Carol's receipts", "tags": []string{"home"}})
+	resp := ta.request("PUT", "/api/todo/"+report.ID.Hex(), fiber.Map{"completed": true}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	titles := func(path, token string) string {
+		t.Helper()
+		resp := ta.request("GET", path, nil, token)
+		expectStatus(t, resp, http.StatusOK)
+		var todos controllers.ListResponse[models.Todo]
+		decode(t, resp, &todos)
+		var out []string
+		for _, todo := range todos.Data {
+			out = append(out, todo.Title)
+		}
+		return strings.Join(out, ",")
+	}
+
+	base := "/api/todos/" + bob.ID.Hex()
+	for query, want := range map[string]string{
+		"":                                "File taxes,Buy milk,Expense report",
+		"?completed=false":                "File taxes,Buy milk",
+		"?completed=true":                 "Expense report",
+		"?tags=home":                      "File taxes,Buy milk",
+		"?tags=Home,FINANCE":              "File taxes",
+		"?priority=1,2":                   "Buy milk,Expense report",
+		"?dueAfter=2025-03-15T00:00:00Z":  "File taxes",
+		"?dueBefore=2025-04-15T00:00:00Z": "Buy milk",
+		"?sort=-priority":                 "File taxes,Expense report,Buy milk",
+		"?sort=dueAt,title":               "Expense report,Buy milk,File taxes",
+		"?sort=completedAt,-title":        "File taxes,Buy milk,Expense report",
+		"?q=receipts":                     "Expense report,File taxes", // equal relevance, newest first
+		"?q=taxes+receipts":               "File taxes,Expense report",
+		"?q=receipts&sort=-title":         "File taxes,Expense report",
+		"?q=receipts&completed=true":      "Expense report",
+		"?q=nothing":                      "",
+	} {
+		if got := titles(base+query, bob.Token); got != want {
+			t.Errorf("%s: expected %q, got %q", query, want, got)
+		}
+	}
+
+	if got := titles("/api/todos?tags=home&sort=title", admin.Token); got != "Buy milk,Carol's receipts,File taxes" {
+		t.Errorf("expected every user's home todos, got %q", got)
+	}
+
+	// anything outside the allow-list is rejected rather than passed on
+	for _, query := range []string{
+		"?sort=password", "?sort=$where", "?sort=title,-title", "?completed=maybe",
+		"?priority=7", "?priority=%7B%22$gt%22:0%7D", "?dueAfter=yesterday",
+		"?q=" + strings.Repeat("x", 201),
+	} {
+		resp := ta.request("GET", base+query, nil, bob.Token)
+		expectStatus(t, resp, http.StatusBadRequest)
+	}
+}
+
+func TestCountTodosByUserID(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	ta.createTodo(bob, "one", nil)
+
+	resp := ta.request("GET", "/api/todos/"+bob.ID.Hex()+"/count", nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var res struct {
+		User  string `json:"user"`
+		Count int64  `json:"count"`
+	}
+	decode(t, resp, &res)
+	if res.User != "bob" || res.Count != 1 {
+		t.Fatalf("unexpected count %+v", res)
+	}
+}
+
+func TestCountTodosRateLimited(t *testing.T) {
+	ta := newTestApp(t)
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	ta.createTodo(admin, "one", nil)
+
+	resp := ta.request("GET", "/api/todos/count", nil, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var res struct {
+		Count int64 `json:"count"`
+	}
+	decode(t, resp, &res)
+	if res.Count != 1 {
+		t.Fatalf("expected count 1, got %d", res.Count)
+	}
+
+	// the route allows 3 requests per minute
+	ta.request("GET", "/api/todos/count", nil, admin.Token)
+	ta.request("GET", "/api/todos/count", nil, admin.Token)
+	resp = ta.request("GET", "/api/todos/count", nil, admin.Token)
+	expectStatus(t, resp, http.StatusTooManyRequests)
+}
@@ -0,0 +1,339 @@
+package routes_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
+	"github.com/clinton-mwachia/go-fiber-api-template/controllers"
+	"github.com/clinton-mwachia/go-fiber-api-template/models"
+	"github.com/clinton-mwachia/go-fiber-api-template/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRegisterAlwaysCreatesUserRole(t *testing.T) {
+	ta := newTestApp(t)
+	resp := ta.request("POST", "/api/user/register", fiber.Map{
+		"username": "mallory",
+		"email":    "mallory@example.com",
+		"password": testPassword,
+		"role":     "admin",
+	}, "")
+	expectStatus(t, resp, http.StatusCreated)
+
+	u := ta.login(models.User{Email: "mallory@example.com"})
+	resp = ta.request("GET", "/api/users", nil, u.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+}
+
+func TestRegisterValidation(t *testing.T) {
+	ta := newTestApp(t)
+	resp := ta.request("POST", "/api/user/register", fiber.Map{
+		"username": "x",
+		"email":    "not-an-email",
+		"password": "weak",
+	}, "")
+	expectStatus(t, resp, http.StatusUnprocessableEntity)
+
+	var res struct {
+		Fields []utils.FieldError `json:"errors"`
+	}
+	decode(t, resp, &res)
+	got := map[string]string{}
+	for _, f := range res.Fields {
+		got[f.Field] = f.Rule
+	}
+	want := map[string]string{"username": "min", "email": "email", "password": "password"}
+	for field, rule := range want {
+		if got[field] != rule {
+			t.Errorf("expected %s to fail %s, got %q", field, rule, got[field])
+		}
+	}
+
+	resp = ta.request("POST", "/api/user/register", fiber.Map{}, "")
+	expectStatus(t, resp, http.StatusUnprocessableEntity)
+}
+
+func TestRegisterDuplicates(t *testing.T) {
+	ta := newTestApp(t)
+	ta.register("bob", "bob@example.com")
+
+	conflict := func(body fiber.Map, field string) {
+		t.Helper()
+		resp := ta.request("POST", "/api/user/register", body, "")
+		expectStatus(t, resp, http.StatusConflict)
+		var res struct {
+			Code   string             `json:"code"`
+			Fields []utils.FieldError `json:"errors"`
+		}
+		decode(t, resp, &res)
+		if res.Code != "duplicate" || len(res.Fields) != 1 || res.Fields[0].Field != field {
+			t.Fatalf("expected duplicate %s, got %+v", field, res)
+		}
+	}
+	conflict(fiber.Map{"username": "robert", "email": "Bob@Example.COM", "password": testPassword}, "email")
+	conflict(fiber.Map{"username": "BOB", "email": "other@example.com", "password": testPassword}, "username")
+
+	// emails are stored normalized and matched ignoring case
+	resp := ta.request("POST", "/api/login", fiber.Map{"email": "BOB@example.com", "password": testPassword}, "")
+	expectStatus(t, resp, http.StatusOK)
+}
+
+func TestGetAllUsers(t *testing.T) {
+	ta := newTestApp(t)
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	user := ta.register("bob", "bob@example.com")
+
+	resp := ta.request("GET", "/api/users", nil, user.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	resp = ta.request("GET", "/api/users", nil, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var users controllers.ListResponse[models.User]
+	decode(t, resp, &users)
+	if len(users.Data) != 2 || users.Data[0].Username != "admin" {
+		t.Fatalf("expected 2 users oldest first, got %+v", users.Data)
+	}
+}
+
+func TestGetPaginatedUsers(t *testing.T) {
+	ta := newTestApp(t)
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	ta.register("bob", "bob@example.com")
+	ta.register("carol", "carol@example.com")
+
+	resp := ta.request("GET", "/api/users/paginated?page=1&limit=2", nil, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var page struct {
+		Page  int           `json:"page"`
+		Limit int           `json:"limit"`
+		Data  []models.User `json:"data"`
+	}
+	decode(t, resp, &page)
+	if len(page.Data) != 2 || page.Data[0].Username != "carol" {
+		t.Fatalf("expected newest two users, got %+v", page.Data)
+	}
+
+	resp = ta.request("GET", "/api/users/paginated?page=2&limit=2", nil, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &page)
+	if len(page.Data) != 1 || page.Data[0].Username != "admin" {
+		t.Fatalf("expected oldest user on page 2, got %+v", page.Data)
+	}
+}
+
+func TestGetPaginatedUsersSortAndFilter(t *testing.T) {
+	ta := newTestApp(t)
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	ta.register("carol", "carol@example.com")
+	ta.register("bob", "bob@example.com")
+
+	var page struct {
+		Data []models.User `json:"data"`
+	}
+	resp := ta.request("GET", "/api/users/paginated?sort=username", nil, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &page)
+	if len(page.Data) != 3 || page.Data[0].Username != "admin" || page.Data[1].Username != "bob" {
+		t.Fatalf("expected users by username, got %+v", page.Data)
+	}
+
+	resp = ta.request("GET", "/api/users/paginated?sort=createdAt", nil, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &page)
+	if len(page.Data) != 3 || page.Data[0].Username != "admin" || page.Data[2].Username != "bob" {
+		t.Fatalf("expected oldest first, got %+v", page.Data)
+	}
+	for _, u := range page.Data {
+		if u.CreatedAt.IsZero() || u.UpdatedAt.Before(u.CreatedAt) || u.CreatedBy == nil || *u.CreatedBy != u.ID {
+			t.Fatalf("expected audit fields on %s, got %+v", u.Username, u.Audit)
+		}
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	resp = ta.request("GET", "/api/users/paginated?createdAfter="+future, nil, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &page)
+	if len(page.Data) != 0 {
+		t.Fatalf("expected no users created in the future, got %d", len(page.Data))
+	}
+
+	resp = ta.request("GET", "/api/users/paginated?createdBefore="+future, nil, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &page)
+	if len(page.Data) != 3 {
+		t.Fatalf("expected every user, got %d", len(page.Data))
+	}
+
+	resp = ta.request("GET", "/api/users/paginated?sort=password", nil, admin.Token)
+	expectStatus(t, resp, http.StatusBadRequest)
+
+	resp = ta.request("GET", "/api/users/paginated?createdAfter=yesterday", nil, admin.Token)
+	expectStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestGetUserByID(t *testing.T) {
+	ta := newTestApp(t)
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	bob := ta.register("bob", "bob@example.com")
+	carol := ta.register("carol", "carol@example.com")
+
+	resp := ta.request("GET", "/api/user/"+bob.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var got models.User
+	decode(t, resp, &got)
+	if got.Email != bob.Email {
+		t.Fatalf("expected %s, got %s", bob.Email, got.Email)
+	}
+
+	resp = ta.request("GET", "/api/user/"+bob.ID.Hex(), nil, carol.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	resp = ta.request("GET", "/api/user/"+bob.ID.Hex(), nil, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	resp = ta.request("GET", "/api/user/"+primitive.NewObjectID().Hex(), nil, admin.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+
+	resp = ta.request("GET", "/api/user/not-an-id", nil, admin.Token)
+	expectStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestUpdateUser(t *testing.T) {
+	ta := newTestApp(t)
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	bob := ta.register("bob", "bob@example.com")
+
+	resp := ta.request("PUT", "/api/user/"+bob.ID.Hex(), fiber.Map{"username": "robert"}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+	var got models.User
+	decode(t, resp, &got)
+	if got.Username != "robert" {
+		t.Fatalf("expected username to be updated, got %s", got.Username)
+	}
+
+	resp = ta.request("PUT", "/api/user/"+bob.ID.Hex(), fiber.Map{"role": config.RoleAdmin}, bob.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	resp = ta.request("PUT", "/api/user/"+admin.ID.Hex(), fiber.Map{"username": "evil"}, bob.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	resp = ta.request("PUT", "/api/user/"+bob.ID.Hex(), fiber.Map{"role": "superuser"}, admin.Token)
+	expectStatus(t, resp, http.StatusUnprocessableEntity)
+
+	resp = ta.request("PUT", "/api/user/"+bob.ID.Hex(), fiber.Map{"role": config.RoleAdmin}, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	decode(t, resp, &got)
+	if got.Role != config.RoleAdmin {
+		t.Fatalf("expected role admin, got %s", got.Role)
+	}
+
+	// a role change ends the user's sessions, tokens carry the old role
+	resp = ta.request("GET", "/api/user/"+bob.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusUnauthorized)
+	resp = ta.request("POST", "/api/token/refresh", controllers.RefreshInput{RefreshToken: bob.RefreshToken}, "")
+	expectStatus(t, resp, http.StatusUnauthorized)
+	bob = ta.login(bob.User)
+
+	// setting the same role again keeps them
+	resp = ta.request("PUT", "/api/user/"+bob.ID.Hex(), fiber.Map{"role": config.RoleAdmin}, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("GET", "/api/users", nil, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	// a demoted admin loses their permissions right away
+	resp = ta.request("PUT", "/api/user/"+bob.ID.Hex(), fiber.Map{"role": config.RoleUser}, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("GET", "/api/users", nil, bob.Token)
+	expectStatus(t, resp, http.StatusUnauthorized)
+	bob = ta.login(bob.User)
+	resp = ta.request("GET", "/api/users", nil, bob.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	resp = ta.request("PUT", "/api/user/"+bob.ID.Hex(), fiber.Map{}, admin.Token)
+	expectStatus(t, resp, http.StatusBadRequest)
+
+	resp = ta.request("PUT", "/api/user/"+bob.ID.Hex(), fiber.Map{"email": "ADMIN@example.com"}, bob.Token)
+	expectStatus(t, resp, http.StatusConflict)
+
+	resp = ta.request("PUT", "/api/user/"+bob.ID.Hex(), fiber.Map{"username": "Admin"}, bob.Token)
+	expectStatus(t, resp, http.StatusConflict)
+
+	// changing the case of your own username is not a conflict
+	resp = ta.request("PUT", "/api/user/"+bob.ID.Hex(), fiber.Map{"username": "Robert"}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+}
+
+func TestDeleteUser(t *testing.T) {
+	ta := newTestApp(t)
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	bob := ta.register("bob", "bob@example.com")
+
+	resp := ta.request("DELETE", "/api/user/"+admin.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	resp = ta.request("DELETE", "/api/user/"+bob.ID.Hex(), nil, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	// a deleted user's sessions end with them
+	resp = ta.request("GET", "/api/todos/"+bob.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusUnauthorized)
+	resp = ta.request("POST", "/api/token/refresh", controllers.RefreshInput{RefreshToken: bob.RefreshToken}, "")
+	expectStatus(t, resp, http.StatusUnauthorized)
+
+	resp = ta.request("DELETE", "/api/user/"+bob.ID.Hex(), nil, admin.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+}
+
+func TestChangePassword(t *testing.T) {
+	ta := newTestApp(t)
+	bob := ta.register("bob", "bob@example.com")
+	other := ta.login(bob.User)
+
+	resp := ta.request("PUT", "/api/change-password/"+bob.ID.Hex(), fiber.Map{
+		"current_password": "wrong",
+		"new_password":     "NewPassword456!",
+	}, bob.Token)
+	expectStatus(t, resp, http.StatusBadRequest)
+
+	resp = ta.request("PUT", "/api/change-password/"+bob.ID.Hex(), fiber.Map{
+		"current_password": testPassword,
+		"new_password":     "NewPassword456!",
+	}, bob.Token)
+	expectStatus(t, resp, http.StatusOK)
+
+	// every session is signed out, refresh tokens included
+	for _, u := range []testUser{bob, other} {
+		resp = ta.request("POST", "/api/token/refresh", controllers.RefreshInput{RefreshToken: u.RefreshToken}, "")
+		expectStatus(t, resp, http.StatusUnauthorized)
+		resp = ta.request("GET", "/api/user/"+bob.ID.Hex(), nil, u.Token)
+		expectStatus(t, resp, http.StatusUnauthorized)
+	}
+
+	resp = ta.request("POST", "/api/login", controllers.LoginInput{Email: bob.Email, Password: "NewPassword456!"}, "")
+	expectStatus(t, resp, http.StatusOK)
+}
+
+func TestResetPassword(t *testing.T) {
+	ta := newTestApp(t)
+	admin := ta.registerAdmin("admin", "admin@example.com")
+	bob := ta.register("bob", "bob@example.com")
+
+	resp := ta.request("PUT", "/api/reset-password/"+admin.ID.Hex(), fiber.Map{"newPassword": "Hijacked123!"}, bob.Token)
+	expectStatus(t, resp, http.StatusForbidden)
+
+	resp = ta.request("PUT", "/api/reset-password/"+bob.ID.Hex(), fiber.Map{"newPassword": "Reset123456!"}, admin.Token)
+	expectStatus(t, resp, http.StatusOK)
+	resp = ta.request("POST", "/api/token/refresh", controllers.RefreshInput{RefreshToken: bob.RefreshToken}, "")
+	expectStatus(t, resp, http.StatusUnauthorized)
+	resp = ta.request("GET", "/api/user/"+bob.ID.Hex(), nil, bob.Token)
+	expectStatus(t, resp, http.StatusUnauthorized)
+
+	resp = ta.request("POST", "/api/login", controllers.LoginInput{Email: bob.Email, Password: "Reset123456!"}, "")
+	expectStatus(t, resp, http.StatusOK)
+
+	resp = ta.request("PUT", "/api/reset-password/"+primitive.NewObjectID().Hex(), fiber.Map{"newPassword": "Reset123456!"}, admin.Token)
+	expectStatus(t, resp, http.StatusNotFound)
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidSignature is returned for tampered or unsigned URLs
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrExpiredSignature is returned once a signed URL is past its expiry
+	ErrExpiredSignature = errors.New("signature expired")
+)
+
+// Signer creates and checks HMAC signed, expiring URLs for object keys
+type Signer struct {
+	secret []byte
+	base   string
+}
+
+// NewSigner signs URLs under base, e.g. "/api/files"
+func NewSigner(secret, base string) *Signer {
+	return &Signer{secret: []byte(secret), base: strings.TrimSuffix(base, "/")}
+}
+
+func (s *Signer) signature(key string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign returns base/key?expires=<unix>&signature=<hmac>
+func (s *Signer) Sign(key string, expiry time.Duration) string {
+	expires := time.Now().Add(expiry).Unix()
+
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", s.signature(key, expires))
+
+	return s.base + "/" + strings.Join(segments, "/") + "?" + query.Encode()
+}
+
+// Verify checks the expires and signature query values of a signed URL for key
+func (s *Signer) Verify(key, expires, signature string) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || signature == "" {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.signature(key, exp))) {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > exp {
+		return ErrExpiredSignature
+	}
+	return nil
+}
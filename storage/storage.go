@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when no object is stored under the key
+	ErrNotFound = errors.New("object not found")
+	// ErrInvalidKey is returned for keys that could escape the storage root
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// Object describes a stored file
+type Object struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// Storage keeps uploaded files under slash separated keys such as "todos/a.png"
+type Storage interface {
+	// Put stores size bytes read from r, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object, the caller closes the reader
+	Get(ctx context.Context, key string) (io.ReadCloser, Object, error)
+	// Delete removes the object, deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	Stat(ctx context.Context, key string) (Object, error)
+	// List calls fn for every object whose key starts with prefix, in key
+	// order for s3, stopping at the first error fn returns
+	List(ctx context.Context, prefix string, fn func(Object) error) error
+	// SignedURL returns a URL granting read access to the object until expiry,
+	// it does not check that the object exists
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// Config selects and configures the storage backend
+type Config struct {
+	// Driver is "local" (default) or "s3"
+	Driver string
+	// Dir is the root folder of the local driver
+	Dir string
+	// Signer signs the URLs returned by the local driver
+	Signer *Signer
+	S3     S3Config
+}
+
+// New returns the backend selected by cfg.Driver
+func New(ctx context.Context, cfg Config) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocal(cfg.Dir, cfg.Signer)
+	case "s3":
+		return NewS3(ctx, cfg.S3)
+	}
+	return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+}
+
+// ValidateKey rejects empty, absolute and non canonical keys,
+// so a key can never point outside the storage root
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, `\`) ||
+		path.Clean(key) != key || key == "." || key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package storage_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/storage"
+)
+
+// fakeS3 is a minimal in-process stand-in for MinIO, enough for the
+// bucket and object calls the s3 driver makes
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]bool
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modified    time.Time
+}
+
+func newFakeS3(t *testing.T) *httptest.Server {
+	f := &fakeS3{buckets: map[string]bool{}, objects: map[string]fakeObject{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if key == "" {
+		switch r.Method {
+		case http.MethodHead:
+			if !f.buckets[bucket] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			f.buckets[bucket] = true
+		case http.MethodGet:
+			f.list(w, bucket, r.URL.Query().Get("prefix"))
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+		return
+	}
+
+	id := bucket + "/" + key
+	switch r.Method {
+	case http.MethodPut:
+		data, err := readPayload(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.objects[id] = fakeObject{data: data, contentType: r.Header.Get("Content-Type"), modified: time.Now()}
+		w.Header().Set("ETag", etag(data))
+	case http.MethodGet, http.MethodHead:
+		obj, ok := f.objects[id]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				fmt.Fprintf(w, "<Error><Code>NoSuchKey</Code><Message>missing</Message><Key>%s</Key></Error>", key)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.Header().Set("ETag", etag(obj.data))
+		w.Header().Set("Last-Modified", obj.modified.UTC().Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// list answers ListObjectsV2 with every object in one page
+func (f *fakeS3) list(w http.ResponseWriter, bucket, prefix string) {
+	keys := []string{}
+	for id := range f.objects {
+		if key, ok := strings.CutPrefix(id, bucket+"/"); ok && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int
+	}
+	result := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		IsTruncated bool
+		Contents    []content
+	}{Name: bucket, Prefix: prefix, KeyCount: len(keys)}
+	for _, key := range keys {
+		obj := f.objects[bucket+"/"+key]
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			LastModified: obj.modified.UTC().Format(time.RFC3339),
+			ETag:         etag(obj.data),
+			Size:         len(obj.data),
+		})
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// readPayload decodes aws-chunked bodies used by streaming signatures
+func readPayload(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+	var out bytes.Buffer
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return out.Bytes(), nil
+		}
+		if _, err := io.CopyN(&out, br, size); err != nil {
+			return nil, err
+		}
+		br.ReadString('\n')
+	}
+}
+
+func drivers(t *testing.T) map[string]storage.Storage {
+	local, err := storage.NewLocal(t.TempDir(), storage.NewSigner("secret", "/api/files"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newFakeS3(t)
+	s3, err := storage.NewS3(context.Background(), storage.S3Config{
+		Endpoint:  strings.TrimPrefix(srv.URL, "http://"),
+		Region:    "us-east-1",
+		Bucket:    "uploads",
+		AccessKey: "minio",
+		SecretKey: "minio123",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]storage.Storage{"local": local, "s3": s3}
+}
+
+func TestStorageDrivers(t *testing.T) {
+	for name, s := range drivers(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			content := []byte("hello storage")
+
+			if err := s.Put(ctx, "todos/a.txt", bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+				t.Fatal(err)
+			}
+
+			obj, err := s.Stat(ctx, "todos/a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if obj.Size != int64(len(content)) || !strings.HasPrefix(obj.ContentType, "text/plain") || obj.ETag == "" {
+				t.Fatalf("unexpected stat %+v", obj)
+			}
+
+			rc, obj, err := s.Get(ctx, "todos/a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := io.ReadAll(rc)
+			rc.Close()
+			if !bytes.Equal(got, content) || obj.Size != int64(len(content)) {
+				t.Fatalf("expected %q, got %q", content, got)
+			}
+
+			for _, key := range []string{"todos/b.txt", "other/c.txt"} {
+				if err := s.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var listed []string
+			err = s.List(ctx, "todos/", func(obj storage.Object) error {
+				if obj.LastModified.IsZero() {
+					t.Errorf("expected a modification time for %s", obj.Key)
+				}
+				listed = append(listed, obj.Key)
+				return nil
+			})
+			sort.Strings(listed)
+			if err != nil || strings.Join(listed, ",") != "todos/a.txt,todos/b.txt" {
+				t.Fatalf("expected the todos/ keys, got %v (%v)", listed, err)
+			}
+			stop := errors.New("stop")
+			if err := s.List(ctx, "", func(storage.Object) error { return stop }); !errors.Is(err, stop) {
+				t.Fatalf("expected List to return the callback error, got %v", err)
+			}
+
+			signed, err := s.SignedURL(ctx, "todos/a.txt", time.Minute)
+			if err != nil || !strings.Contains(signed, "a.txt") {
+				t.Fatalf("expected a signed URL, got %q (%v)", signed, err)
+			}
+
+			if err := s.Delete(ctx, "todos/a.txt"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Stat(ctx, "todos/a.txt"); !errors.Is(err, storage.ErrNotFound) {
+				t.Fatalf("expected ErrNotFound after delete, got %v", err)
+			}
+			if _, _, err := s.Get(ctx, "todos/a.txt"); !errors.Is(err, storage.ErrNotFound) {
+				t.Fatalf("expected ErrNotFound from Get, got %v", err)
+			}
+			if err := s.Delete(ctx, "todos/a.txt"); err != nil {
+				t.Fatalf("deleting a missing key should succeed, got %v", err)
+			}
+
+			for _, key := range []string{"../etc/passwd", "/abs", "a/../../b", "", `a\b`} {
+				if err := s.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); !errors.Is(err, storage.ErrInvalidKey) {
+					t.Fatalf("expected ErrInvalidKey for %q, got %v", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSigner(t *testing.T) {
+	signer := storage.NewSigner("secret", "/api/files/")
+	signed := signer.Sign("todos/a b.png", time.Minute)
+
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/api/files/todos/a b.png" {
+		t.Fatalf("unexpected path %q", u.Path)
+	}
+	q := u.Query()
+	if err := signer.Verify("todos/a b.png", q.Get("expires"), q.Get("signature")); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+	if err := signer.Verify("todos/other.png", q.Get("expires"), q.Get("signature")); !errors.Is(err, storage.ErrInvalidSignature) {
+		t.Fatalf("expected signature bound to the key, got %v", err)
+	}
+	if err := storage.NewSigner("other", "").Verify("todos/a b.png", q.Get("expires"), q.Get("signature")); !errors.Is(err, storage.ErrInvalidSignature) {
+		t.Fatalf("expected signature bound to the secret, got %v", err)
+	}
+
+	expired, _ := url.Parse(signer.Sign("todos/a b.png", -time.Minute))
+	if err := signer.Verify("todos/a b.png", expired.Query().Get("expires"), expired.Query().Get("signature")); !errors.Is(err, storage.ErrExpiredSignature) {
+		t.Fatalf("expected expired signature, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/clinton-mwachia/go-fiber-api-template/config"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes why a single request field is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// report fields by their json name
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+
+	// role must be declared in config.RolePermissions
+	v.RegisterValidation("role", func(fl validator.FieldLevel) bool {
+		return config.IsValidRole(fl.Field().String())
+	})
+	v.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+		return IsStrongPassword(fl.Field().String())
+	})
+
+	return v
+}
+
+// IsStrongPassword requires at least 8 characters with upper case, lower case and a digit
+func IsStrongPassword(password string) bool {
+	if len(password) < 8 {
+		return false
+	}
+	var upper, lower, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	return upper && lower && digit
+}
+
+// ValidateStruct checks the `validate` tags of s and returns every failing field
+func ValidateStruct(s any) []FieldError {
+	err := validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return []FieldError{{Message: err.Error()}}
+	}
+
+	fields := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		fields = append(fields, FieldError{
+			Field:   fe.Field(),
+			Rule:    rule(fe),
+			Message: fieldMessage(fe),
+		})
+	}
+	return fields
+}
+
+// rule is the failing tag without an "eq=|" alternative, which pointer
+// fields use to accept an empty string that clears the value
+func rule(fe validator.FieldError) string {
+	return strings.TrimPrefix(fe.Tag(), "eq=|")
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch rule(fe) {
+	case "required":
+		return fmt.Sprintf("%s is required", fe.Field())
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", fe.Field())
+	case "min":
+		return fmt.Sprintf("%s must be at least %s", fe.Field(), sizeOf(fe))
+	case "max":
+		return fmt.Sprintf("%s must be at most %s", fe.Field(), sizeOf(fe))
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", fe.Field(), fe.Param())
+	case "mongodb":
+		return fmt.Sprintf("%s must be a valid id", fe.Field())
+	case "role":
+		return fmt.Sprintf("%s is not a known role", fe.Field())
+	case "timezone":
+		return fmt.Sprintf("%s must be an IANA timezone such as Europe/Berlin", fe.Field())
+	case "hexcolor":
+		return fmt.Sprintf("%s must be a hex color such as #3b82f6", fe.Field())
+	case "password":
+		return fmt.Sprintf("%s must be at least 8 characters and contain upper case, lower case and a digit", fe.Field())
+	}
+	return fmt.Sprintf("%s is invalid (%s)", fe.Field(), fe.Tag())
+}
+
+// sizeOf words the min / max limit for the kind of field it applies to
+func sizeOf(fe validator.FieldError) string {
+	switch fe.Kind() {
+	case reflect.String:
+		return fe.Param() + " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return fe.Param() + " items"
+	}
+	return fe.Param()
+}